@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"backend-go/internal/analysis"
 	"backend-go/internal/api"
@@ -13,10 +20,16 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
 )
 
 func main() {
+	// Tracing (exports only when OTEL_EXPORTER_OTLP_ENDPOINT is set)
+	shutdownTracing, err := api.SetupTracing(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize Services
 	llmService := llm.NewService(state.State.OllamaBaseURL, state.State.OllamaModel)
 	ctxService := service.NewContextService()
@@ -26,7 +39,34 @@ func main() {
 	exportService := service.NewExportService()
 
 	// Initialize Handler
-	handler := api.NewHandler(ctxService, qgService, csvService, simService, exportService, llmService)
+	handler, err := api.NewHandler(ctxService, qgService, csvService, simService, exportService, llmService, api.Config{
+		AllowedOrigins: allowedOrigins(),
+		StorageDir:     os.Getenv("STORAGE_DIR"),
+		AnalysisTTL:    analysisTTL(),
+		GraphCacheSize: graphCacheSize(),
+
+		AnalysisWorkers:   analysisWorkers(),
+		WorkerWaitTimeout: workerWaitTimeout(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize handler: %v", err)
+	}
+	handler.Logger = newLogger()
+	csvService.Logger = handler.Logger
+
+	// Audit log of mutating operations (AUDIT_LOG=stdout or a file path)
+	switch auditLog := os.Getenv("AUDIT_LOG"); auditLog {
+	case "":
+	case "stdout":
+		handler.AuditLogger = api.NewStdoutAuditLogger()
+	default:
+		fileLogger, err := api.NewFileAuditLogger(auditLog)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer fileLogger.Close()
+		handler.AuditLogger = fileLogger
+	}
 
 	// Router Setup
 	r := chi.NewRouter()
@@ -34,27 +74,21 @@ func main() {
 	// Middleware
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.RealIP)
-
-	// CORS - Allow frontend
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins: []string{"http://localhost:3000", "http://localhost:3001", "http://localhost:3002", "http://127.0.0.1:3000"},
+	// Only take the client IP from X-Forwarded-For/X-Real-IP behind a proxy
+	// that sets them; otherwise any client could claim any address
+	if os.Getenv("TRUST_PROXY") == "true" {
+		r.Use(middleware.RealIP)
+	}
+	r.Use(api.Tracing)
 
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
+	// Register all API Routes (before any route, as it installs middleware)
+	handler.RegisterRoutes(r)
 
 	// Root endpoint
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Project Euler Go Backend is Running"))
 	})
 
-	// Register all API Routes
-	handler.RegisterRoutes(r)
-
 	// Get port from environment or default
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -62,10 +96,103 @@ func main() {
 	}
 
 	log.Printf("🚀 Starting Go Backend on http://localhost:%s", port)
-	log.Printf("📡 CORS enabled for: http://localhost:3000")
+	log.Printf("📡 CORS enabled for: %s", strings.Join(allowedOrigins(), ", "))
 	log.Printf("📁 Upload directory: ./uploads")
 
-	if err := http.ListenAndServe(":"+port, r); err != nil {
+	server := &http.Server{Addr: ":" + port, Handler: r}
+
+	// Stop accepting connections on SIGINT/SIGTERM and let in-flight requests finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
 		log.Fatalf("Server failed to start: %v", err)
+	case <-ctx.Done():
+	}
+
+	timeout := shutdownTimeout()
+	log.Printf("🛑 Shutting down, waiting up to %s for in-flight requests", timeout)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(drainCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+
+	handler.Shutdown()
+}
+
+// shutdownTimeout returns the drain timeout from SHUTDOWN_TIMEOUT (a Go
+// duration such as "45s"), defaulting to 30 seconds
+func shutdownTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 30 * time.Second
+}
+
+// analysisTTL reads ANALYSIS_TTL_MINUTES; zero leaves the handler default of an hour
+func analysisTTL() time.Duration {
+	if m, err := strconv.Atoi(os.Getenv("ANALYSIS_TTL_MINUTES")); err == nil && m > 0 {
+		return time.Duration(m) * time.Minute
+	}
+	return 0
+}
+
+// graphCacheSize reads GRAPH_CACHE_SIZE; zero leaves the handler default of 50 graphs
+func graphCacheSize() int {
+	if n, err := strconv.Atoi(os.Getenv("GRAPH_CACHE_SIZE")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// analysisWorkers reads ANALYSIS_WORKERS; zero leaves the handler default of 4
+func analysisWorkers() int {
+	if n, err := strconv.Atoi(os.Getenv("ANALYSIS_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// workerWaitTimeout reads ANALYSIS_WAIT_TIMEOUT (a Go duration such as "30s");
+// zero leaves the handler default of 10 seconds
+func workerWaitTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("ANALYSIS_WAIT_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+// newLogger builds the JSON logger used by the API handlers. LOG_LEVEL may be
+// debug, info, warn or error (default info).
+func newLogger() *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+}
+
+// allowedOrigins returns the CORS origins from CORS_ALLOWED_ORIGINS
+// (comma-separated), defaulting to the local frontend dev servers
+func allowedOrigins() []string {
+	raw := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if raw == "" {
+		return []string{"http://localhost:3000", "http://localhost:3001", "http://localhost:3002", "http://127.0.0.1:3000"}
+	}
+
+	origins := []string{}
+	for _, origin := range strings.Split(raw, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
 	}
+	return origins
 }
@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"backend-go/internal/models"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxNumericSampleRows is how many leading rows of each numeric column are
+// kept, row-aligned, for correlations
+const maxNumericSampleRows = 1000
+
+// numericSample returns the first maxNumericSampleRows values of a column,
+// with nil where a row is missing or not a number
+func numericSample(data []map[string]interface{}, colName string) []*float64 {
+	n := min(len(data), maxNumericSampleRows)
+	sample := make([]*float64, n)
+	for i, row := range data[:n] {
+		if row[colName] == nil {
+			continue
+		}
+		val, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(row[colName])), 64)
+		if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+			continue
+		}
+		sample[i] = &val
+	}
+	return sample
+}
+
+// CorrelationMatrix returns the Pearson correlation of every pair of columns
+// that has a numeric sample. Pairs without enough variance or overlapping rows
+// score 0; the diagonal is always 1.
+func CorrelationMatrix(analysis *models.DataAnalysisResult) ([]string, [][]float64) {
+	columns := []string{}
+	samples := [][]*float64{}
+	for _, col := range analysis.ColumnProfiles {
+		if len(col.NumericSample) == 0 {
+			continue
+		}
+		columns = append(columns, col.Name)
+		samples = append(samples, col.NumericSample)
+	}
+
+	matrix := make([][]float64, len(columns))
+	for i := range matrix {
+		matrix[i] = make([]float64, len(columns))
+		matrix[i][i] = 1
+	}
+	for i := range columns {
+		for j := i + 1; j < len(columns); j++ {
+			r := PearsonCorrelation(samples[i], samples[j])
+			matrix[i][j], matrix[j][i] = r, r
+		}
+	}
+	return columns, matrix
+}
+
+// PearsonCorrelation correlates two row-aligned samples over the rows where
+// both are present, returning 0 when it is undefined
+func PearsonCorrelation(x, y []*float64) float64 {
+	var n, sumX, sumY, sumXX, sumYY, sumXY float64
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if x[i] == nil || y[i] == nil {
+			continue
+		}
+		a, b := *x[i], *y[i]
+		n++
+		sumX += a
+		sumY += b
+		sumXX += a * a
+		sumYY += b * b
+		sumXY += a * b
+	}
+	if n < 2 {
+		return 0
+	}
+
+	cov := sumXY - sumX*sumY/n
+	varX := sumXX - sumX*sumX/n
+	varY := sumYY - sumY*sumY/n
+	if varX <= 0 || varY <= 0 {
+		return 0
+	}
+	r := cov / math.Sqrt(varX*varY)
+	return math.Max(-1, math.Min(1, r))
+}
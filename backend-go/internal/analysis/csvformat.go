@@ -0,0 +1,208 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// sniffSize is how much of a file is inspected to detect its delimiter
+const sniffSize = 4096
+
+// candidateDelimiters are tried by DetectDelimiter; on a tie the earlier one wins
+var candidateDelimiters = []rune{',', '\t', '|', ';'}
+
+// DefaultMaxDecompressedBytes caps gzip output when CSVOptions sets no limit
+const DefaultMaxDecompressedBytes = 1 << 30
+
+// CSVOptions controls how AnalyzeFile reads a delimited file
+type CSVOptions struct {
+	Sampling  SamplingConfig
+	Delimiter rune // 0 detects the delimiter from the start of the file
+
+	// MaxDecompressedBytes caps how much a gzip file may expand to, guarding
+	// against gzip bombs; 0 means DefaultMaxDecompressedBytes
+	MaxDecompressedBytes int64
+
+	Progress chan<- ProgressEvent // Optional; receives events as the analysis proceeds
+}
+
+// csvFormat records how a file was decoded
+type csvFormat struct {
+	Delimiter     rune
+	Compressed    bool // The file was gzip-compressed
+	BOMDetected   bool // A UTF-8 or UTF-16 byte-order mark was removed
+	MalformedRows int  // Rows skipped because they could not be parsed
+}
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress wraps r in a gzip reader if it starts with gzipMagic, and reports
+// whether it did. The gzip reader fails once it has produced more than limit
+// bytes.
+func decompress(r *bufio.Reader, limit int64) (io.Reader, bool, error) {
+	start, err := r.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	if !bytes.Equal(start, gzipMagic) {
+		return r, false, nil
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, false, fmt.Errorf("reading gzip header: %w", err)
+	}
+	if limit <= 0 {
+		limit = DefaultMaxDecompressedBytes
+	}
+	return &sizeLimitReader{r: gz, limit: limit, remaining: limit}, true, nil
+}
+
+// sizeLimitReader reads from r until more than limit bytes have been read,
+// then fails with an error rather than truncating like io.LimitReader
+type sizeLimitReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	// Read one byte past the limit so input of exactly limit bytes still
+	// reaches EOF cleanly
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		n = int(l.remaining)
+		l.remaining = 0
+		return n, fmt.Errorf("decompressed size exceeds %d bytes", l.limit)
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// Byte-order marks stripped by stripBOM
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// stripBOM removes a leading byte-order mark from r, decoding UTF-16 input to
+// UTF-8, and reports whether there was one
+func stripBOM(r *bufio.Reader) (io.Reader, bool, error) {
+	start, err := r.Peek(len(bomUTF8))
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+
+	switch {
+	case bytes.HasPrefix(start, bomUTF8):
+		_, err := r.Discard(len(bomUTF8))
+		return r, true, err
+	case bytes.HasPrefix(start, bomUTF16LE):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Reader(r), true, nil
+	case bytes.HasPrefix(start, bomUTF16BE):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Reader(r), true, nil
+	default:
+		return r, false, nil
+	}
+}
+
+// ParseDelimiter validates a delimiter override: one of , | ; or a tab, given
+// as a literal tab, "\t" or "tab". "" means auto-detect and returns 0.
+func ParseDelimiter(raw string) (rune, error) {
+	switch raw {
+	case "":
+		return 0, nil
+	case `\t`, "tab", "TAB":
+		return '\t', nil
+	}
+	for _, d := range candidateDelimiters {
+		if raw == string(d) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported delimiter %q: must be one of , tab | ;", raw)
+}
+
+// DelimiterForFilename returns a tab for .tsv and .tsv.gz files and 0, meaning
+// detect, for anything else
+func DelimiterForFilename(name string) rune {
+	name = strings.TrimSuffix(strings.ToLower(name), ".gz")
+	if filepath.Ext(name) == ".tsv" {
+		return '\t'
+	}
+	return 0
+}
+
+// DetectDelimiter returns whichever of , tab | ; occurs most often in sample,
+// defaulting to a comma
+func DetectDelimiter(sample []byte) rune {
+	best, bestCount := ',', 0
+	for _, d := range candidateDelimiters {
+		if count := bytes.Count(sample, []byte(string(d))); count > bestCount {
+			best, bestCount = d, count
+		}
+	}
+	return best
+}
+
+// openCSVFile opens a delimited file, detecting the delimiter unless
+// opts.Delimiter is set. The caller must close the returned file.
+func openCSVFile(filePath string, opts CSVOptions) (*csv.Reader, io.Closer, csvFormat, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, csvFormat{}, err
+	}
+
+	reader, format, err := newCSVReader(file, opts)
+	if err != nil {
+		file.Close()
+		return nil, nil, csvFormat{}, err
+	}
+	return reader, file, format, nil
+}
+
+// newCSVReader wraps r in a csv.Reader after decompressing gzip input and
+// stripping any byte-order mark. It splits on opts.Delimiter, or on the
+// delimiter detected from the first sniffSize bytes if that is 0. The reader
+// tolerates stray quotes and ragged rows.
+func newCSVReader(r io.Reader, opts CSVOptions) (*csv.Reader, csvFormat, error) {
+	delimiter := opts.Delimiter
+	decompressed, compressed, err := decompress(bufio.NewReader(r), opts.MaxDecompressedBytes)
+	if err != nil {
+		return nil, csvFormat{}, err
+	}
+
+	decoded, bom, err := stripBOM(bufio.NewReader(decompressed))
+	if err != nil {
+		return nil, csvFormat{}, err
+	}
+
+	buffered := bufio.NewReaderSize(decoded, sniffSize)
+	if delimiter == 0 {
+		sample, err := buffered.Peek(sniffSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, csvFormat{}, err
+		}
+		delimiter = DetectDelimiter(sample)
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1 // Allow variable fields
+	reader.LazyQuotes = true    // Allow bare quotes in non-quoted fields
+	return reader, csvFormat{Delimiter: delimiter, Compressed: compressed, BOMDetected: bom}, nil
+}
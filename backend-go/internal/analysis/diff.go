@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"backend-go/internal/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sort"
+)
+
+// nullRateChangeMin is the smallest change in null percentage (in points)
+// reported by SchemaDiff
+const nullRateChangeMin = 1.0
+
+// DiffResult describes how the schema of one analysis differs from another
+type DiffResult struct {
+	AddedColumns    []string         `json:"added_columns"`
+	RemovedColumns  []string         `json:"removed_columns"`
+	TypeChanges     []TypeChange     `json:"type_changes"`
+	NullRateChanges []NullRateChange `json:"null_rate_changes"`
+}
+
+// TypeChange is a column whose detected type differs between analyses
+type TypeChange struct {
+	Column string `json:"column"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// NullRateChange is a column whose null percentage (0-100) moved by at least
+// nullRateChangeMin points
+type NullRateChange struct {
+	Column string  `json:"column"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+}
+
+// SchemaFingerprint hashes the sorted column names with their inferred types,
+// so it changes when the schema does but not when only the values do
+func SchemaFingerprint(columnTypes map[string]string) string {
+	names := make([]string, 0, len(columnTypes))
+	for name := range columnTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name + "\x00" + columnTypes[name] + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SchemaDiff compares analysis a (before) with b (after). Columns are matched
+// by name and listed in the order they appear in their analysis.
+func SchemaDiff(a, b *models.DataAnalysisResult) DiffResult {
+	diff := DiffResult{
+		AddedColumns:    []string{},
+		RemovedColumns:  []string{},
+		TypeChanges:     []TypeChange{},
+		NullRateChanges: []NullRateChange{},
+	}
+
+	for _, name := range a.ColumnNames {
+		if _, ok := b.ColumnTypes[name]; !ok {
+			diff.RemovedColumns = append(diff.RemovedColumns, name)
+		}
+	}
+
+	for _, name := range b.ColumnNames {
+		before, ok := a.ColumnTypes[name]
+		if !ok {
+			diff.AddedColumns = append(diff.AddedColumns, name)
+			continue
+		}
+		if after := b.ColumnTypes[name]; after != before {
+			diff.TypeChanges = append(diff.TypeChanges, TypeChange{Column: name, Before: before, After: after})
+		}
+
+		colA, colB := a.Column(name), b.Column(name)
+		if colA != nil && colB != nil && math.Abs(colB.NullPercent-colA.NullPercent) >= nullRateChangeMin {
+			diff.NullRateChanges = append(diff.NullRateChanges, NullRateChange{
+				Column: name,
+				Before: colA.NullPercent,
+				After:  colB.NullPercent,
+			})
+		}
+	}
+
+	return diff
+}
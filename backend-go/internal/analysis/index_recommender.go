@@ -0,0 +1,170 @@
+package analysis
+
+import (
+	"backend-go/internal/models"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Thresholds used by IndexRecommender
+const (
+	fkMinSimilarity     = 70.0 // Edge similarity (0-100) at which a match is treated as a foreign key
+	highCardinalityMin  = 0.9  // Cardinality from which a column is selective enough to index
+	indexableTextMaxLen = 255  // Longer text is free-form and not worth a B-tree index
+)
+
+// IndexRecommendation suggests an index on one column of an analyzed table
+type IndexRecommendation struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Reason string `json:"reason"`
+	DDL    string `json:"ddl"`
+}
+
+// IndexRecommender suggests indexes from stored analyses and the similarity
+// graph between them. Columns matched to a key of another file are foreign
+// key candidates; columns with high cardinality make selective filters. A
+// table's inferred primary key is skipped since it is indexed already.
+type IndexRecommender struct {
+	Graph    *models.SimilarityGraph // May be nil; only cardinality is used then
+	Analyses map[int]*models.DataAnalysisResult
+}
+
+// Recommend returns one recommendation per indexable column, ordered by file
+// index and then column order
+func (ir *IndexRecommender) Recommend() []IndexRecommendation {
+	fks := ir.foreignKeys()
+
+	indexes := make([]int, 0, len(ir.Analyses))
+	for idx := range ir.Analyses {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	recommendations := []IndexRecommendation{}
+	for _, idx := range indexes {
+		result := ir.Analyses[idx]
+		if result == nil {
+			continue
+		}
+		table := indexTableName(idx, result)
+		for _, col := range result.ColumnProfiles {
+			if col.Name == result.InferredPrimaryKey {
+				continue
+			}
+
+			var reasons []string
+			if ref, ok := fks[idx][col.Name]; ok {
+				reasons = append(reasons, "FK candidate referencing "+ref)
+			}
+			if isSelective(&col) {
+				reasons = append(reasons, fmt.Sprintf("high cardinality %.2f", col.Cardinality))
+			}
+			if len(reasons) == 0 {
+				continue
+			}
+
+			recommendations = append(recommendations, IndexRecommendation{
+				Table:  table,
+				Column: col.Name,
+				Reason: strings.Join(reasons, ", "),
+				DDL:    fmt.Sprintf("CREATE INDEX ON %s(%s);", indexIdent(table), indexIdent(col.Name)),
+			})
+		}
+	}
+	return recommendations
+}
+
+// foreignKeys maps file index and column to the table.column it references,
+// for each graph edge of at least fkMinSimilarity where exactly one end is a
+// key. When neither end is a key the match is still a join column, so both
+// ends reference each other.
+func (ir *IndexRecommender) foreignKeys() map[int]map[string]string {
+	fks := make(map[int]map[string]string)
+	if ir.Graph == nil {
+		return fks
+	}
+	add := func(idx int, col, ref string) {
+		if fks[idx] == nil {
+			fks[idx] = make(map[string]string)
+		}
+		if _, ok := fks[idx][col]; !ok {
+			fks[idx][col] = ref
+		}
+	}
+
+	for _, edge := range ir.Graph.Edges {
+		if edge.Similarity < fkMinSimilarity {
+			continue
+		}
+		srcIdx, srcCol, ok1 := parseNodeID(edge.Source)
+		dstIdx, dstCol, ok2 := parseNodeID(edge.Target)
+		if !ok1 || !ok2 {
+			continue
+		}
+		src, dst := ir.Analyses[srcIdx], ir.Analyses[dstIdx]
+		if src == nil || dst == nil {
+			continue
+		}
+
+		srcRef := indexTableName(srcIdx, src) + "." + srcCol
+		dstRef := indexTableName(dstIdx, dst) + "." + dstCol
+		srcKey, dstKey := src.IsKeyColumn(srcCol), dst.IsKeyColumn(dstCol)
+		switch {
+		case dstKey && !srcKey:
+			add(srcIdx, srcCol, dstRef)
+		case srcKey && !dstKey:
+			add(dstIdx, dstCol, srcRef)
+		case !srcKey && !dstKey:
+			add(srcIdx, srcCol, dstRef)
+			add(dstIdx, dstCol, srcRef)
+		}
+	}
+	return fks
+}
+
+// parseNodeID splits a similarity graph node ID such as "f2_customer_id" into
+// its file index and column name
+func parseNodeID(id string) (int, string, bool) {
+	var idx int
+	if _, err := fmt.Sscanf(id, "f%d_", &idx); err != nil {
+		return 0, "", false
+	}
+	_, col, ok := strings.Cut(id, "_")
+	return idx, col, ok
+}
+
+// isSelective reports whether an index on col would narrow most lookups to
+// a few rows: high cardinality, not a float measure and not long free text
+func isSelective(col *models.ColumnAnalysis) bool {
+	if col.Cardinality < highCardinalityMin || col.IsPrimaryKeyCandidate {
+		return false
+	}
+	if col.InferredType == models.ColumnTypeFloat {
+		return false
+	}
+	return col.MaxLength <= indexableTextMaxLen
+}
+
+// indexTableName names a file's table after its file name without the
+// extension, or file<N> if it has none
+func indexTableName(idx int, result *models.DataAnalysisResult) string {
+	if result.FileName == "" {
+		return fmt.Sprintf("file%d", idx)
+	}
+	return strings.TrimSuffix(result.FileName, filepath.Ext(result.FileName))
+}
+
+// plainIdent matches identifiers that need no quoting in SQL
+var plainIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// indexIdent double-quotes name for SQL unless it is a plain identifier
+func indexIdent(name string) string {
+	if plainIdent.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
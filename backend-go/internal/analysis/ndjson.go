@@ -0,0 +1,207 @@
+package analysis
+
+import (
+	"backend-go/internal/models"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxNDJSONLine bounds the size of a single NDJSON record
+const maxNDJSONLine = 10 << 20
+
+// AnalyzeNDJSON reads a newline-delimited JSON file and returns analysis results
+// for the rows selected by sampling. Columns are the union of keys across all
+// objects, in first-seen order.
+func (s *CSVService) AnalyzeNDJSON(filePath string, sampling SamplingConfig) (models.DataAnalysisResult, error) {
+	total := 0
+	if sampling.Strategy == models.SamplingSystematic {
+		var err error
+		if total, err = countNDJSONRows(filePath); err != nil {
+			return models.DataAnalysisResult{}, err
+		}
+	}
+
+	headers, data, err := readNDJSONFile(filePath, newRowSampler(sampling, total))
+	if err != nil {
+		return models.DataAnalysisResult{}, err
+	}
+
+	return s.analyzeSample(data, headers, sampling)
+}
+
+// IsNDJSONFile reports whether a file looks like newline-delimited JSON, either
+// by extension or because its first non-blank byte opens an object
+func IsNDJSONFile(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json", ".ndjson", ".jsonl":
+		return true
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// readNDJSONFile reads the objects of an NDJSON file as column->value maps,
+// keeping those sampler selects
+func readNDJSONFile(filePath string, sampler *rowSampler) ([]string, []map[string]interface{}, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	return readNDJSON(file, sampler)
+}
+
+// readNDJSON offers each object to sampler. Every line is still read once the
+// sampler is full, so the columns cover the whole file.
+func readNDJSON(r io.Reader, sampler *rowSampler) ([]string, []map[string]interface{}, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+
+	headers := []string{}
+	seen := make(map[string]bool)
+	sampling := true
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		// Keep the object's key order so the union schema follows the file
+		keys, err := objectKeys(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				headers = append(headers, key)
+			}
+		}
+		if !sampling {
+			continue
+		}
+
+		row := make(map[string]interface{}, len(obj))
+		for key, val := range obj {
+			row[key] = normalizeJSONValue(val)
+		}
+		sampling = sampler.add(row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(headers) == 0 {
+		return nil, nil, fmt.Errorf("no JSON objects found")
+	}
+
+	return headers, sampler.result(), nil
+}
+
+// countNDJSONRows counts the non-blank lines of an NDJSON file
+func countNDJSONRows(filePath string) (int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+
+	count := 0
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
+
+// objectKeys returns the top-level keys of a JSON object in document order
+func objectKeys(line []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	keys := []string{}
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tok.(string))
+
+		// Skip the value
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// normalizeJSONValue converts decoded JSON values to the string form the CSV
+// profiler expects; null stays nil so it counts as missing
+func normalizeJSONValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprintf("%t", v)
+	default:
+		// Nested objects and arrays are profiled as their JSON text
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(encoded)
+	}
+}
@@ -0,0 +1,55 @@
+package analysis
+
+import (
+	"backend-go/internal/models"
+	"regexp"
+)
+
+// piiSampleSize is how many non-empty values per column are checked for PII
+const piiSampleSize = 100
+
+// piiPatterns are checked in order; a column is flagged with each name whose
+// pattern matches at least one sampled value
+var piiPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{"phone", regexp.MustCompile(`\b\d{3}[-.\s]\d{3}[-.\s]\d{4}\b`)},
+	{"ssn", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+}
+
+// detectPII returns the names of the PII patterns found in the first
+// piiSampleSize non-empty samples
+func detectPII(samples []string) []string {
+	checked := make([]string, 0, piiSampleSize)
+	for _, val := range samples {
+		if len(checked) == piiSampleSize {
+			break
+		}
+		if val != "" {
+			checked = append(checked, val)
+		}
+	}
+
+	flags := []string{}
+	for _, p := range piiPatterns {
+		for _, val := range checked {
+			if p.pattern.MatchString(val) {
+				flags = append(flags, p.name)
+				break
+			}
+		}
+	}
+	return flags
+}
+
+// containsPII reports whether any column was flagged by detectPII
+func containsPII(cols []models.ColumnAnalysis) bool {
+	for _, col := range cols {
+		if len(col.PIIFlags) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package analysis
+
+// Stages reported by ProgressEvent
+const (
+	StageParsing   = "parsing"   // Reading rows from the file
+	StageProfiling = "profiling" // Analyzing columns
+	StageDone      = "done"      // Analysis finished
+)
+
+// parsedPercent is the progress reported once parsing has finished; the
+// remainder is spread evenly over the columns
+const parsedPercent = 10
+
+// ProgressEvent reports how far an analysis has got
+type ProgressEvent struct {
+	Percent int    `json:"percent"`
+	Stage   string `json:"stage"`
+}
+
+// reportProgress sends an event if progress is set. Sends block, so the
+// receiver must keep draining the channel until the analysis returns.
+func reportProgress(progress chan<- ProgressEvent, percent int, stage string) {
+	if progress != nil {
+		progress <- ProgressEvent{Percent: percent, Stage: stage}
+	}
+}
+
+// columnPercent is the progress after done of total columns have been profiled
+func columnPercent(done, total int) int {
+	if total == 0 {
+		return 100
+	}
+	return parsedPercent + (100-parsedPercent)*done/total
+}
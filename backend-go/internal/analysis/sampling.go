@@ -0,0 +1,179 @@
+package analysis
+
+import (
+	"backend-go/internal/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// DefaultSampleSize is the number of rows random and systematic sampling keep
+// when no size is given
+const DefaultSampleSize = 10000
+
+// samplingSeed makes random samples reproducible, so analyzing the same file
+// twice yields the same profile
+const samplingSeed = 1
+
+// SamplingConfig selects which rows are analyzed. The zero value analyzes
+// every row.
+type SamplingConfig struct {
+	Strategy models.SamplingStrategy
+	Size     int // Rows to keep; 0 means every row for head, DefaultSampleSize otherwise
+}
+
+// ParseSamplingStrategy validates a sampling strategy; "" means head
+func ParseSamplingStrategy(name string) (models.SamplingStrategy, error) {
+	switch strategy := models.SamplingStrategy(strings.ToLower(name)); strategy {
+	case "":
+		return models.SamplingHead, nil
+	case models.SamplingHead, models.SamplingRandom, models.SamplingSystematic:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown sampling %q: must be head, random or systematic", name)
+	}
+}
+
+// sampled reports whether the config can drop rows
+func (c SamplingConfig) sampled() bool {
+	return c.Size > 0 || (c.Strategy != "" && c.Strategy != models.SamplingHead)
+}
+
+// normalized fills in the default strategy and size
+func (c SamplingConfig) normalized() SamplingConfig {
+	if c.Strategy == "" {
+		c.Strategy = models.SamplingHead
+	}
+	if c.Size <= 0 && c.Strategy != models.SamplingHead {
+		c.Size = DefaultSampleSize
+	}
+	return c
+}
+
+// rowSampler picks rows as they are read, so a random or systematic sample
+// never holds more than its size in memory
+type rowSampler struct {
+	strategy models.SamplingStrategy
+	size     int // 0 keeps every row (head only)
+	interval int // Systematic: keep every interval-th row
+	rng      *rand.Rand
+	seen     int
+	rows     []sampledRow
+}
+
+// sampledRow remembers a row's position so samples keep the input order
+type sampledRow struct {
+	index int
+	row   map[string]interface{}
+}
+
+// newRowSampler creates a sampler for cfg. total is the number of rows that
+// will be offered, needed only to space out systematic samples.
+func newRowSampler(cfg SamplingConfig, total int) *rowSampler {
+	cfg = cfg.normalized()
+	s := &rowSampler{strategy: cfg.Strategy, size: cfg.Size, interval: 1}
+
+	switch s.strategy {
+	case models.SamplingRandom:
+		s.rng = rand.New(rand.NewSource(samplingSeed))
+	case models.SamplingSystematic:
+		if total > s.size {
+			s.interval = (total + s.size - 1) / s.size
+		}
+	}
+	return s
+}
+
+// add offers the next row and reports whether more rows are wanted; only a
+// full head sample stops early
+func (s *rowSampler) add(row map[string]interface{}) bool {
+	defer func() { s.seen++ }()
+
+	switch s.strategy {
+	case models.SamplingRandom:
+		// Algorithm R: the i-th row replaces a random slot with probability size/i
+		if len(s.rows) < s.size {
+			s.rows = append(s.rows, sampledRow{s.seen, row})
+		} else if j := s.rng.Intn(s.seen + 1); j < s.size {
+			s.rows[j] = sampledRow{s.seen, row}
+		}
+		return true
+	case models.SamplingSystematic:
+		if s.seen%s.interval == 0 && len(s.rows) < s.size {
+			s.rows = append(s.rows, sampledRow{s.seen, row})
+		}
+		return true
+	default:
+		s.rows = append(s.rows, sampledRow{s.seen, row})
+		return s.size == 0 || len(s.rows) < s.size
+	}
+}
+
+// result returns the sampled rows in input order
+func (s *rowSampler) result() []map[string]interface{} {
+	sort.Slice(s.rows, func(i, j int) bool { return s.rows[i].index < s.rows[j].index })
+
+	data := make([]map[string]interface{}, len(s.rows))
+	for i, r := range s.rows {
+		data[i] = r.row
+	}
+	return data
+}
+
+// SampleRows applies cfg to rows that are already in memory
+func SampleRows(data []map[string]interface{}, cfg SamplingConfig) []map[string]interface{} {
+	if !cfg.sampled() {
+		return data
+	}
+
+	sampler := newRowSampler(cfg, len(data))
+	for _, row := range data {
+		if !sampler.add(row) {
+			break
+		}
+	}
+	return sampler.result()
+}
+
+// analyzeSample analyzes rows already selected by sampling, recording the
+// strategy on the result
+func (s *CSVService) analyzeSample(data []map[string]interface{}, headers []string, sampling SamplingConfig) (models.DataAnalysisResult, error) {
+	result, err := s.AnalyzeData(data, headers)
+	if err != nil {
+		return result, err
+	}
+	if sampling.sampled() {
+		result.Sampling = sampling.normalized().Strategy
+	}
+	return result, nil
+}
+
+// countCSVRows counts the data records of a delimited file, excluding the header
+func countCSVRows(filePath string, opts CSVOptions) (int, error) {
+	reader, file, _, err := openCSVFile(filePath, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader.ReuseRecord = true
+	count := -1 // The header
+	for {
+		_, err := reader.Read()
+		if err == io.EOF {
+			return max(count, 0), nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			continue // Skipped by readRecords too
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
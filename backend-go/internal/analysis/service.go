@@ -2,135 +2,476 @@ package analysis
 
 import (
 	"backend-go/internal/models"
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type CSVService struct{}
+var tracer = otel.Tracer("analysis")
+
+type CSVService struct {
+	Logger *slog.Logger // Receives debug logs of malformed rows
+}
 
 func NewCSVService() *CSVService {
-	return &CSVService{}
+	return &CSVService{Logger: slog.Default()}
+}
+
+// logger returns s.Logger, or the default logger if none is set
+func (s *CSVService) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
+	}
+	return s.Logger
 }
 
 // AnalyzeData performs analysis on generic data (from CSV or DB)
 func (s *CSVService) AnalyzeData(data []map[string]interface{}, columns []string) (models.DataAnalysisResult, error) {
+	return s.AnalyzeDataContext(context.Background(), data, columns)
+}
+
+// AnalyzeDataContext is AnalyzeData with its span parented to ctx
+func (s *CSVService) AnalyzeDataContext(ctx context.Context, data []map[string]interface{}, columns []string) (models.DataAnalysisResult, error) {
+	_, span := tracer.Start(ctx, "CSVService.AnalyzeData")
+	defer span.End()
+	span.SetAttributes(attribute.Int("rows", len(data)), attribute.Int("columns", len(columns)))
+	return analyzeData(data, columns, nil), nil
+}
+
+// analyzeData is AnalyzeData, reporting to progress (if set) as each column
+// is profiled
+func analyzeData(data []map[string]interface{}, columns []string, progress chan<- ProgressEvent) models.DataAnalysisResult {
 	result := models.DataAnalysisResult{
 		ColumnNames:      columns,
 		ColumnTypes:      make(map[string]string),
 		PotentialIDs:     []string{},
 		PotentialDates:   []string{},
 		PotentialAmounts: []string{},
+		ColumnProfiles:   []models.ColumnAnalysis{},
 		NumRows:          len(data),
 		NumColumns:       len(columns),
 	}
 
-	// Infer types
-	for _, colName := range columns {
-		colType := "string" // default
+	for i, colName := range columns {
+		reportProgress(progress, columnPercent(i, len(columns)), StageProfiling)
+		addColumn(&result, analyzeColumn(data, colName))
+	}
+	result.InferredPrimaryKey = inferPrimaryKey(result.ColumnProfiles)
+	result.ContainsPII = containsPII(result.ColumnProfiles)
+	result.SchemaFingerprint = SchemaFingerprint(result.ColumnTypes)
+
+	return result
+}
+
+// analyzeColumn profiles a single column of generic data
+func analyzeColumn(data []map[string]interface{}, colName string) models.ColumnAnalysis {
+	colType := "string" // default
+
+	nullCount, emptyCount := 0, 0
+	for _, row := range data {
+		switch val := row[colName]; val {
+		case nil:
+			nullCount++
+		case "":
+			emptyCount++
+		}
+	}
+
+	// Check first non-nil value to guess type
+	// For robustness we should check a sample, but simplistic for now
+	foundType := false
+	for _, row := range data {
+		val := row[colName]
+		if val == nil {
+			continue
+		}
 
-		// Check first non-nil value to guess type
-		// For robustness we should check a sample, but simplistic for now
-		foundType := false
-		for _, row := range data {
-			val := row[colName]
-			if val == nil {
+		// If it's a string, we try to infer underlying type
+		if strVal, ok := val.(string); ok {
+			if strVal == "" {
 				continue
 			}
-
-			// If it's a string, we try to infer underlying type
-			if strVal, ok := val.(string); ok {
-				if strVal == "" {
-					continue
-				}
-				// Use the string inference logic
-				// We need a helper that takes a single string, or we reuse inferColumnType logic?
-				// Let's create a specialized helper for mixed inputs
-				colType = inferTypeFromValue(val)
-			} else {
-				// It's already typed (from DB)
-				switch val.(type) {
-				case int, int32, int64, float32, float64:
-					colType = "float" // Treat all numbers as float for simplicity in analysis or separate?
-					// Project Euler logic distinguished int/float.
-					// Let's refine.
-					if reflect.TypeOf(val).Kind() == reflect.Int || reflect.TypeOf(val).Kind() == reflect.Int64 {
-						colType = "int"
-					} else {
-						colType = "float"
-					}
-				case time.Time:
-					colType = "date"
-				default:
-					colType = "string"
+			// Use the string inference logic
+			// We need a helper that takes a single string, or we reuse inferColumnType logic?
+			// Let's create a specialized helper for mixed inputs
+			colType = inferTypeFromValue(val)
+		} else {
+			// It's already typed (from DB)
+			switch val.(type) {
+			case int, int32, int64, float32, float64:
+				colType = "float" // Treat all numbers as float for simplicity in analysis or separate?
+				// Project Euler logic distinguished int/float.
+				// Let's refine.
+				if reflect.TypeOf(val).Kind() == reflect.Int || reflect.TypeOf(val).Kind() == reflect.Int64 {
+					colType = "int"
+				} else {
+					colType = "float"
 				}
+			case time.Time:
+				colType = "date"
+			default:
+				colType = "string"
 			}
-			foundType = true
-			break
 		}
+		foundType = true
+		break
+	}
 
-		if !foundType {
-			colType = "string" // All nulls or empty
+	if !foundType {
+		colType = "string" // All nulls or empty
+	}
+
+	samples := columnSamples(data, colName)
+	col := models.ColumnAnalysis{
+		Name:             colName,
+		Type:             colType,
+		InferredType:     InferType(samples),
+		Nullable:         nullCount+emptyCount > 0,
+		NullCount:        nullCount,
+		EmptyStringCount: emptyCount,
+	}
+	if len(data) > 0 {
+		col.NullPercent = float64(nullCount+emptyCount) / float64(len(data)) * 100
+	}
+
+	if colType == "string" {
+		col.MinLength, col.MaxLength = stringLengthRange(data, colName)
+	}
+	counts, order := valueCounts(data, colName)
+	col.DistinctCount = len(counts)
+	if len(data) > 0 {
+		col.Cardinality = float64(col.DistinctCount) / float64(len(data))
+	}
+	if len(order) > maxSampleValues {
+		order = order[:maxSampleValues]
+	}
+	col.SampleValues = order
+	col.IsConstant = col.DistinctCount == 1
+	col.DominantValuePercent = dominantValuePercent(counts)
+	if col.DistinctCount > 0 && col.Cardinality < categoricalCardinality {
+		col.TopValues = topValues(counts, maxTopValues)
+	}
+	col.IsPrimaryKeyCandidate = isPrimaryKeyCandidate(col, samples)
+	numericStats(&col, samples)
+	if col.Mean != nil {
+		col.NumericSample = numericSample(data, colName)
+	}
+	col.PIIFlags = detectPII(samples)
+	if col.InferredType == models.ColumnTypeDate {
+		col.DateFormat = DetectDateFormat(samples)
+	}
+
+	return col
+}
+
+// isPrimaryKeyCandidate reports whether a column is complete, unique and of a
+// key-like type (integer or UUID)
+func isPrimaryKeyCandidate(col models.ColumnAnalysis, samples []string) bool {
+	if col.DistinctCount == 0 || col.Nullable || col.Cardinality < 1.0 {
+		return false
+	}
+	if col.InferredType == models.ColumnTypeInteger {
+		return true
+	}
+	for _, val := range samples {
+		if !uuidPattern.MatchString(strings.TrimSpace(val)) {
+			return false
 		}
+	}
+	return true
+}
 
-		result.ColumnTypes[colName] = colType
-		colLower := strings.ToLower(colName)
+var uuidPattern = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
 
-		if colType == "int" || colType == "float" {
-			result.HasNumeric = true
-			if containsAny(colLower, []string{"id", "number", "code", "key"}) {
-				result.PotentialIDs = append(result.PotentialIDs, colName)
-			}
-			if containsAny(colLower, []string{"amount", "price", "cost", "revenue", "salary"}) {
-				result.PotentialAmounts = append(result.PotentialAmounts, colName)
-			}
-		} else if colType == "date" {
-			result.HasDates = true
+// inferPrimaryKey picks the most likely primary key among the candidate
+// columns, preferring a column named "id", then one ending in "id"
+func inferPrimaryKey(cols []models.ColumnAnalysis) string {
+	best, bestRank := "", 0
+	for _, col := range cols {
+		if !col.IsPrimaryKeyCandidate {
+			continue
+		}
+		name := strings.ToLower(col.Name)
+		rank := 1
+		switch {
+		case name == "id":
+			rank = 3
+		case strings.HasSuffix(name, "id"):
+			rank = 2
+		}
+		if rank > bestRank {
+			best, bestRank = col.Name, rank
+		}
+	}
+	return best
+}
+
+const (
+	maxSampleValues        = 200  // Distinct values kept per column
+	maxTopValues           = 20   // Most frequent values kept for categorical columns
+	categoricalCardinality = 0.05 // Below this distinct/rows ratio a column is treated as categorical
+)
+
+// valueCounts counts the non-empty values of a column, also returning the
+// distinct values in first-seen order
+func valueCounts(data []map[string]interface{}, colName string) (map[string]int, []string) {
+	counts := make(map[string]int)
+	order := []string{}
+	for _, row := range data {
+		val := row[colName]
+		if val == nil || val == "" {
+			continue
+		}
+		strVal := fmt.Sprint(val)
+		if counts[strVal] == 0 {
+			order = append(order, strVal)
+		}
+		counts[strVal]++
+	}
+	return counts, order
+}
+
+// dominantValuePercent returns the share of values, 0-100, held by the most
+// common one
+func dominantValuePercent(counts map[string]int) float64 {
+	total, top := 0, 0
+	for _, count := range counts {
+		total += count
+		top = max(top, count)
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(top) / float64(total) * 100
+}
+
+// topValues returns the n most frequent values, ties broken alphabetically
+func topValues(counts map[string]int, n int) []models.ValueCount {
+	top := make([]models.ValueCount, 0, len(counts))
+	for val, count := range counts {
+		top = append(top, models.ValueCount{Value: val, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Value < top[j].Value
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// stringLengthRange returns the min and max character length of the non-empty string values
+func stringLengthRange(data []map[string]interface{}, colName string) (int, int) {
+	minLen, maxLen := -1, 0
+	for _, row := range data {
+		strVal, ok := row[colName].(string)
+		if !ok || strVal == "" {
+			continue
+		}
+		n := utf8.RuneCountInString(strVal)
+		if minLen == -1 || n < minLen {
+			minLen = n
+		}
+		if n > maxLen {
+			maxLen = n
+		}
+	}
+	if minLen == -1 {
+		return 0, 0
+	}
+	return minLen, maxLen
+}
+
+// addColumn records a column profile and updates the table-level flags
+func addColumn(result *models.DataAnalysisResult, col models.ColumnAnalysis) {
+	colName := col.Name
+	colType := col.Type
+
+	result.ColumnProfiles = append(result.ColumnProfiles, col)
+	result.ColumnTypes[colName] = colType
+	if col.OutlierCount > 0 {
+		result.HasOutliers = true
+	}
+	colLower := strings.ToLower(colName)
+
+	if colType == "int" || colType == "float" {
+		result.HasNumeric = true
+		if containsAny(colLower, []string{"id", "number", "code", "key"}) {
+			result.PotentialIDs = append(result.PotentialIDs, colName)
+		}
+		if containsAny(colLower, []string{"amount", "price", "cost", "revenue", "salary"}) {
+			result.PotentialAmounts = append(result.PotentialAmounts, colName)
+		}
+	} else if colType == "date" {
+		result.HasDates = true
+		result.PotentialDates = append(result.PotentialDates, colName)
+	} else {
+		result.HasText = true
+		// Check if name implies date even if data didn't parse easily
+		if containsAny(colLower, []string{"date", "time", "timestamp"}) {
 			result.PotentialDates = append(result.PotentialDates, colName)
-		} else {
-			result.HasText = true
-			// Check if name implies date even if data didn't parse easily
-			if containsAny(colLower, []string{"date", "time", "timestamp"}) {
-				result.PotentialDates = append(result.PotentialDates, colName)
-				result.HasDates = true
-			}
+			result.HasDates = true
 		}
 	}
+}
 
-	return result, nil
+// AnalyzeFile reads a delimited file and returns analysis results for the rows
+// selected by opts.Sampling
+func (s *CSVService) AnalyzeFile(filePath string, opts CSVOptions) (models.DataAnalysisResult, error) {
+	return s.AnalyzeFileContext(context.Background(), filePath, opts)
 }
 
-// AnalyzeFile reads a CSV file and returns analysis results
-func (s *CSVService) AnalyzeFile(filePath string) (models.DataAnalysisResult, error) {
+// AnalyzeFileContext is AnalyzeFile with tracing and cancellation from ctx
+func (s *CSVService) AnalyzeFileContext(ctx context.Context, filePath string, opts CSVOptions) (models.DataAnalysisResult, error) {
+	ctx, span := tracer.Start(ctx, "CSVService.AnalyzeFile")
+	defer span.End()
+	reportProgress(opts.Progress, 0, StageParsing)
+
+	// Systematic sampling spaces rows over the whole file, so count them first
+	total := 0
+	if opts.Sampling.Strategy == models.SamplingSystematic {
+		var err error
+		if total, err = countCSVRows(filePath, opts); err != nil {
+			span.RecordError(err)
+			return models.DataAnalysisResult{}, err
+		}
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
+		span.RecordError(err)
 		return models.DataAnalysisResult{}, err
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	return s.analyzeCSV(ctx, file, opts, total)
+}
+
+// ErrSystematicStream is returned by AnalyzeReader for systematic sampling,
+// which needs the row count before it can pick rows
+var ErrSystematicStream = errors.New("systematic sampling needs the whole file and cannot be used on a stream")
+
+// AnalyzeReader is AnalyzeFileContext for delimited data read once from r,
+// such as an upload that is analyzed as it arrives instead of being saved to
+// disk first. Reading stops early once head sampling has its rows.
+func (s *CSVService) AnalyzeReader(ctx context.Context, r io.Reader, opts CSVOptions) (models.DataAnalysisResult, error) {
+	ctx, span := tracer.Start(ctx, "CSVService.AnalyzeReader")
+	defer span.End()
 
+	if opts.Sampling.Strategy == models.SamplingSystematic {
+		return models.DataAnalysisResult{}, ErrSystematicStream
+	}
+	reportProgress(opts.Progress, 0, StageParsing)
+	return s.analyzeCSV(ctx, r, opts, 0)
+}
+
+// analyzeCSV reads delimited data from r and analyzes the rows picked by
+// opts.Sampling; total is the row count for systematic sampling. Attributes
+// are recorded on the span in ctx.
+func (s *CSVService) analyzeCSV(ctx context.Context, r io.Reader, opts CSVOptions, total int) (models.DataAnalysisResult, error) {
+	span := trace.SpanFromContext(ctx)
+	sampling := opts.Sampling
+
+	headers, data, format, err := readCSVSampled(r, opts, newRowSampler(sampling, total), s.logger())
+	if err != nil {
+		span.RecordError(err)
+		return models.DataAnalysisResult{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return models.DataAnalysisResult{}, err
+	}
+	span.SetAttributes(
+		attribute.Int("rows", len(data)),
+		attribute.Int("columns", len(headers)),
+		attribute.String("sampling", string(sampling.Strategy)),
+		attribute.String("delimiter", string(format.Delimiter)),
+		attribute.Bool("compressed", format.Compressed),
+		attribute.Int("malformed_rows", format.MalformedRows),
+	)
+
+	result := analyzeData(data, headers, opts.Progress)
+	if sampling.sampled() {
+		result.Sampling = sampling.normalized().Strategy
+	}
+	result.DetectedDelimiter = string(format.Delimiter)
+	result.WasCompressed = format.Compressed
+	result.BOMDetected = format.BOMDetected
+	result.MalformedRowCount = format.MalformedRows
+	reportProgress(opts.Progress, 100, StageDone)
+	return result, nil
+}
+
+// AnalyzeStream reads delimited data from r and sends each column's analysis
+// to out as soon as it is computed. out is closed when the analysis finishes.
+func (s *CSVService) AnalyzeStream(r io.Reader, opts CSVOptions, out chan<- models.ColumnAnalysis) error {
+	defer close(out)
+
+	headers, data, _, err := readCSVSampled(r, opts, newRowSampler(SamplingConfig{}, 0), s.logger())
+	if err != nil {
+		return err
+	}
+
+	for _, colName := range headers {
+		out <- analyzeColumn(data, colName)
+	}
+	return nil
+}
+
+// readCSVSampled reads the header and the rows sampler keeps from delimited
+// data, reporting how the data was decoded
+func readCSVSampled(r io.Reader, opts CSVOptions, sampler *rowSampler, logger *slog.Logger) ([]string, []map[string]interface{}, csvFormat, error) {
+	reader, format, err := newCSVReader(r, opts)
+	if err != nil {
+		return nil, nil, csvFormat{}, err
+	}
+
+	headers, data, malformed, err := readRecords(reader, sampler, logger)
+	format.MalformedRows = malformed
+	return headers, data, format, err
+}
+
+// readRecords reads the header and offers each row to sampler, stopping once
+// it wants no more. Rows that fail to parse are logged, counted and skipped.
+func readRecords(reader *csv.Reader, sampler *rowSampler, logger *slog.Logger) ([]string, []map[string]interface{}, int, error) {
 	// Read header
 	headers, err := reader.Read()
 	if err != nil {
-		return models.DataAnalysisResult{}, err
+		return nil, nil, 0, err
 	}
 
-	// Read all rows and convert to map
-	var data []map[string]interface{}
+	// Read rows and convert to map
+	malformed := 0
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			malformed++
+			logger.Debug("skipping malformed CSV row", "line", parseErr.StartLine, "err", parseErr.Err)
+			continue
+		}
 		if err != nil {
-			return models.DataAnalysisResult{}, err
+			return nil, nil, malformed, err
 		}
 
 		rowMap := make(map[string]interface{})
@@ -139,10 +480,12 @@ func (s *CSVService) AnalyzeFile(filePath string) (models.DataAnalysisResult, er
 				rowMap[headers[i]] = val
 			}
 		}
-		data = append(data, rowMap)
+		if !sampler.add(rowMap) {
+			break
+		}
 	}
 
-	return s.AnalyzeData(data, headers)
+	return headers, sampler.result(), malformed, nil
 }
 
 func inferTypeFromValue(v interface{}) string {
@@ -204,14 +547,7 @@ func inferColumnType(rows [][]string, colIndex int) string {
 }
 
 func isDateString(val string) bool {
-	formats := []string{
-		time.RFC3339,
-		"2006-01-02",
-		"02/01/2006",
-		"01/02/2006",
-		"2006/01/02",
-	}
-	for _, f := range formats {
+	for _, f := range dateLayouts {
 		if _, err := time.Parse(f, val); err == nil {
 			return true
 		}
@@ -0,0 +1,146 @@
+package analysis
+
+import (
+	"backend-go/internal/models"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// medianReservoirSize bounds the values held in memory to estimate the median
+// and build the histogram
+const medianReservoirSize = 10000
+
+// histogramBuckets is the number of equal-width buckets in a column histogram
+const histogramBuckets = 20
+
+// maxOutlierExamples caps the outlying values reported per column
+const maxOutlierExamples = 5
+
+// numericStats fills Min, Max, Mean, StdDev, Median, Histogram and the outlier
+// fields for integer and float columns. Mean and standard deviation are exact
+// (Welford's algorithm); the median, histogram and quartiles come from a
+// reservoir sample of up to medianReservoirSize values.
+func numericStats(col *models.ColumnAnalysis, samples []string) {
+	if col.InferredType != models.ColumnTypeInteger && col.InferredType != models.ColumnTypeFloat {
+		return
+	}
+
+	// Fixed seed so the same file always yields the same median
+	rng := rand.New(rand.NewSource(1))
+	reservoir := make([]float64, 0, min(len(samples), medianReservoirSize))
+
+	var n int
+	var minVal, maxVal, mean, m2 float64
+	for _, raw := range samples {
+		val, ok := parseFinite(raw)
+		if !ok {
+			continue
+		}
+		n++
+		if n == 1 || val < minVal {
+			minVal = val
+		}
+		if n == 1 || val > maxVal {
+			maxVal = val
+		}
+		delta := val - mean
+		mean += delta / float64(n)
+		m2 += delta * (val - mean)
+
+		if len(reservoir) < medianReservoirSize {
+			reservoir = append(reservoir, val)
+		} else if j := rng.Intn(n); j < medianReservoirSize {
+			reservoir[j] = val
+		}
+	}
+	if n == 0 {
+		return
+	}
+
+	stdDev := 0.0
+	if n > 1 {
+		stdDev = math.Sqrt(m2 / float64(n-1))
+	}
+	median := medianOf(reservoir)
+
+	col.Min, col.Max, col.Mean, col.StdDev, col.Median = &minVal, &maxVal, &mean, &stdDev, &median
+	col.Histogram = histogram(reservoir, minVal, maxVal)
+	col.OutlierCount, col.OutlierExamples = outliers(samples, reservoir)
+}
+
+// parseFinite parses a numeric value, rejecting NaN and infinities
+func parseFinite(raw string) (float64, bool) {
+	val, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+		return 0, false
+	}
+	return val, true
+}
+
+// outliers counts the values outside [Q1 - 1.5*IQR, Q3 + 1.5*IQR], taking the
+// quartiles from sorted, and returns the first few of them as examples
+func outliers(samples []string, sorted []float64) (int, []float64) {
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	iqr := q3 - q1
+	low, high := q1-1.5*iqr, q3+1.5*iqr
+
+	count := 0
+	var examples []float64
+	for _, raw := range samples {
+		val, ok := parseFinite(raw)
+		if !ok || (val >= low && val <= high) {
+			continue
+		}
+		count++
+		if len(examples) < maxOutlierExamples {
+			examples = append(examples, val)
+		}
+	}
+	return count, examples
+}
+
+// quantile returns the q-th quantile of sorted values, interpolating linearly
+// between the closest ranks
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lower := int(pos)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// histogram counts values into histogramBuckets equal-width buckets spanning
+// [minVal, maxVal]. A constant column gets a single bucket.
+func histogram(values []float64, minVal, maxVal float64) []models.HistogramBucket {
+	if maxVal == minVal {
+		return []models.HistogramBucket{{LowerBound: minVal, UpperBound: maxVal, Count: len(values)}}
+	}
+
+	width := (maxVal - minVal) / histogramBuckets
+	buckets := make([]models.HistogramBucket, histogramBuckets)
+	for i := range buckets {
+		buckets[i].LowerBound = minVal + float64(i)*width
+		buckets[i].UpperBound = minVal + float64(i+1)*width
+	}
+	buckets[histogramBuckets-1].UpperBound = maxVal
+
+	for _, val := range values {
+		i := min(int((val-minVal)/width), histogramBuckets-1)
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// medianOf sorts values in place and returns their median
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	"backend-go/internal/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// inferTypeMinShare is the share of non-null values that must parse as a type
+// for the column to be given that type
+const inferTypeMinShare = 0.95
+
+// booleanValues are the spellings accepted as booleans (compared lower case)
+var booleanValues = map[string]bool{
+	"true": true, "false": true, "t": true, "f": true,
+	"yes": true, "no": true, "y": true, "n": true,
+}
+
+// InferType picks the column type that at least 95% of the non-empty samples
+// parse as, trying integer, float, date and boolean in that order and falling
+// back to text
+func InferType(samples []string) models.ColumnType {
+	var total, ints, floats, dates, bools int
+	for _, raw := range samples {
+		val := strings.TrimSpace(raw)
+		if val == "" {
+			continue
+		}
+		total++
+
+		if _, err := strconv.ParseInt(val, 10, 64); err == nil {
+			ints++
+		}
+		if _, err := strconv.ParseFloat(val, 64); err == nil {
+			floats++
+		}
+		if isDateString(val) {
+			dates++
+		}
+		if booleanValues[strings.ToLower(val)] {
+			bools++
+		}
+	}
+
+	if total == 0 {
+		return models.ColumnTypeText
+	}
+
+	matches := func(n int) bool { return float64(n) >= inferTypeMinShare*float64(total) }
+	switch {
+	case matches(ints):
+		return models.ColumnTypeInteger
+	case matches(floats):
+		return models.ColumnTypeFloat
+	case matches(dates):
+		return models.ColumnTypeDate
+	case matches(bools):
+		return models.ColumnTypeBoolean
+	default:
+		return models.ColumnTypeText
+	}
+}
+
+// dateLayouts are the time layouts recognised as dates, in priority order.
+// Month-first US dates win over day-first ones when both parse.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"01/02/2006",
+	"02/01/2006",
+	"2006/01/02",
+	"02-Jan-2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+}
+
+// DetectDateFormat returns the first layout in dateLayouts that at least 95%
+// of the non-empty samples parse with, or "" if none does
+func DetectDateFormat(samples []string) string {
+	values := make([]string, 0, len(samples))
+	for _, raw := range samples {
+		if val := strings.TrimSpace(raw); val != "" {
+			values = append(values, val)
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+
+	for _, layout := range dateLayouts {
+		parsed := 0
+		for _, val := range values {
+			if _, err := time.Parse(layout, val); err == nil {
+				parsed++
+			}
+		}
+		if float64(parsed) >= inferTypeMinShare*float64(len(values)) {
+			return layout
+		}
+	}
+	return ""
+}
+
+// columnSamples returns the non-null values of a column as strings
+func columnSamples(data []map[string]interface{}, colName string) []string {
+	samples := make([]string, 0, len(data))
+	for _, row := range data {
+		switch val := row[colName].(type) {
+		case nil:
+			continue
+		case string:
+			samples = append(samples, val)
+		case time.Time:
+			samples = append(samples, val.Format(time.RFC3339))
+		default:
+			samples = append(samples, fmt.Sprint(val))
+		}
+	}
+	return samples
+}
@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"backend-go/internal/models"
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// AnalyzeXLSX analyzes the rows of the first sheet of an Excel workbook that
+// are selected by sampling. Rows are streamed from the sheet, so only the
+// sample is held in memory.
+func (s *CSVService) AnalyzeXLSX(filePath string, sampling SamplingConfig) (models.DataAnalysisResult, error) {
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return models.DataAnalysisResult{}, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return models.DataAnalysisResult{}, fmt.Errorf("workbook has no sheets")
+	}
+
+	total := 0
+	if sampling.Strategy == models.SamplingSystematic {
+		err := eachXLSXRow(f, sheets[0], func([]string) bool {
+			total++
+			return true
+		})
+		if err != nil {
+			return models.DataAnalysisResult{}, err
+		}
+		total = max(total-1, 0) // The header
+	}
+
+	var headers []string
+	sampler := newRowSampler(sampling, total)
+	err = eachXLSXRow(f, sheets[0], func(row []string) bool {
+		if headers == nil {
+			headers = row
+			return true
+		}
+
+		// Rows omit trailing empty cells, so pad every row to the header width
+		rowMap := make(map[string]interface{}, len(headers))
+		for i, header := range headers {
+			val := ""
+			if i < len(row) {
+				val = row[i]
+			}
+			rowMap[header] = val
+		}
+		return sampler.add(rowMap)
+	})
+	if err != nil {
+		return models.DataAnalysisResult{}, err
+	}
+	if headers == nil {
+		return models.DataAnalysisResult{}, fmt.Errorf("sheet %q is empty", sheets[0])
+	}
+
+	return s.analyzeSample(sampler.result(), headers, sampling)
+}
+
+// eachXLSXRow streams the rows of a sheet to fn until it returns false
+func eachXLSXRow(f *excelize.File, sheet string, fn func(row []string) bool) error {
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		if !fn(row) {
+			break
+		}
+	}
+	return rows.Error()
+}
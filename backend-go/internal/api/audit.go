@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Operations recorded in the audit log
+const (
+	AuditUpload     = "upload"     // A file was uploaded and analyzed
+	AuditAnalyze    = "analyze"    // A table or query was analyzed
+	AuditConnect    = "connect"    // A database connection was opened
+	AuditDisconnect = "disconnect" // The database connection was closed
+	AuditExport     = "export"     // Code or a report was generated from stored analyses
+	AuditContext    = "context"    // Business context was stored
+	AuditAnnotate   = "annotate"   // A column annotation was stored
+	AuditDelete     = "delete"     // A stored analysis or context was removed
+	AuditConfig     = "config"     // Server configuration was changed
+	AuditFeedback   = "feedback"   // Match feedback was submitted
+)
+
+// AuditEntry records one mutating operation: who did what, when and to which
+// file or table
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Operation string    `json:"operation"`
+	FileIndex int       `json:"file_index,omitempty"`
+	FileName  string    `json:"file_name,omitempty"`
+	TableName string    `json:"table_name,omitempty"`
+	Detail    string    `json:"detail,omitempty"` // e.g. the export format or database type
+	ClientIP  string    `json:"client_ip"`
+	RequestID string    `json:"request_id,omitempty"`
+	APIKey    string    `json:"api_key,omitempty"` // Namespace of the API key, never the key itself
+}
+
+// AuditLogger stores audit entries
+type AuditLogger interface {
+	Log(entry AuditEntry) error
+}
+
+// NopAuditLogger discards every entry; it is the Handler default
+type NopAuditLogger struct{}
+
+func (NopAuditLogger) Log(AuditEntry) error { return nil }
+
+// jsonAuditLogger writes entries as JSON lines, one write per entry
+type jsonAuditLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *jsonAuditLogger) Log(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(line, '\n'))
+	return err
+}
+
+// StdoutAuditLogger writes entries to stdout as JSON lines
+type StdoutAuditLogger struct {
+	jsonAuditLogger
+}
+
+func NewStdoutAuditLogger() *StdoutAuditLogger {
+	return &StdoutAuditLogger{jsonAuditLogger{w: os.Stdout}}
+}
+
+// FileAuditLogger appends entries to a file as JSON lines
+type FileAuditLogger struct {
+	jsonAuditLogger
+	file *os.File
+}
+
+// NewFileAuditLogger opens path for appending, creating it if needed
+func NewFileAuditLogger(path string) (*FileAuditLogger, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditLogger{jsonAuditLogger{w: file}, file}, nil
+}
+
+func (l *FileAuditLogger) Close() error {
+	return l.file.Close()
+}
+
+// audit fills in the request details of entry and logs it. A failing audit
+// log is reported but does not fail the request.
+func (h *Handler) audit(r *http.Request, entry AuditEntry) {
+	entry.Timestamp = time.Now().UTC()
+	entry.ClientIP = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		entry.ClientIP = host
+	}
+	entry.RequestID = GetRequestID(r.Context())
+	entry.APIKey = apiKeyIdentity(r.Context())
+
+	if err := h.AuditLogger.Log(entry); err != nil {
+		h.Logger.Error("writing audit log", "err", err, "operation", entry.Operation, "request_id", entry.RequestID)
+	}
+}
+
+// auditExport wraps an export handler, logging an export of format once the
+// handler has answered successfully
+func (h *Handler) auditExport(format string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next(ww, r)
+
+		if ww.Status() < http.StatusBadRequest {
+			fileIndex, _ := strconv.Atoi(chi.URLParam(r, "fileIndex"))
+			h.audit(r, AuditEntry{Operation: AuditExport, FileIndex: fileIndex, Detail: format})
+		}
+	}
+}
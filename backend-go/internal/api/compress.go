@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressMinSize is the smallest response worth compressing, about
+// one TCP segment
+const DefaultCompressMinSize = 1400
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(io.Discard) },
+}
+
+// Compress gzips responses for clients that accept it. Output is buffered
+// until minSize bytes have been written, so small responses go out as-is.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	if minSize <= 0 {
+		minSize = DefaultCompressMinSize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+			defer gw.Close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether Accept-Encoding lists gzip with a non-zero q
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc, params, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(enc) != "gzip" {
+			continue
+		}
+		return qValue(params) > 0
+	}
+	return false
+}
+
+// qValue returns the q parameter of an Accept-Encoding entry's parameters,
+// 1 when there is none and 0 when it is malformed
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
+
+// gzipResponseWriter buffers the start of a response and switches to gzip once
+// it grows past minSize or the handler flushes
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status      int
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+	passthrough bool // decided not to compress
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.status == 0 {
+		g.status = code
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+
+	switch {
+	case g.gz != nil:
+		return g.gz.Write(p)
+	case g.passthrough:
+		return g.ResponseWriter.Write(p)
+	case !g.compressible():
+		g.startPassthrough()
+		return g.ResponseWriter.Write(p)
+	}
+
+	g.buf.Write(p)
+	if g.buf.Len() >= g.minSize {
+		if err := g.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush starts compressing straight away so streamed output is not held back
+func (g *gzipResponseWriter) Flush() {
+	if g.gz == nil && !g.passthrough {
+		if g.status == 0 {
+			g.status = http.StatusOK
+		}
+		if g.compressible() {
+			g.startGzip()
+		} else {
+			g.startPassthrough()
+			g.ResponseWriter.Write(g.buf.Bytes())
+			g.buf.Reset()
+		}
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes any buffered output and finishes the gzip stream
+func (g *gzipResponseWriter) Close() error {
+	if g.gz != nil {
+		err := g.gz.Close()
+		gzipWriterPool.Put(g.gz)
+		g.gz = nil
+		return err
+	}
+	if g.passthrough {
+		return nil
+	}
+
+	// Too small to compress (or nothing written at all)
+	g.passthrough = true
+	if g.status != 0 {
+		g.ResponseWriter.WriteHeader(g.status)
+	}
+	_, err := g.ResponseWriter.Write(g.buf.Bytes())
+	return err
+}
+
+// compressible reports whether the response may be gzipped
+func (g *gzipResponseWriter) compressible() bool {
+	h := g.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false // already encoded, e.g. by promhttp
+	}
+	if strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		return false
+	}
+	switch g.status {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+func (g *gzipResponseWriter) startPassthrough() {
+	g.passthrough = true
+	g.ResponseWriter.WriteHeader(g.status)
+}
+
+func (g *gzipResponseWriter) startGzip() error {
+	h := g.Header()
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	g.ResponseWriter.WriteHeader(g.status)
+
+	g.gz = gzipWriterPool.Get().(*gzip.Writer)
+	g.gz.Reset(g.ResponseWriter)
+
+	_, err := g.gz.Write(g.buf.Bytes())
+	g.buf.Reset()
+	return err
+}
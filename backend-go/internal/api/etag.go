@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// ETag buffers a GET handler's response and tags it with a strong ETag, the
+// base64 SHA-256 of the body. A request whose If-None-Match lists the same tag
+// gets 304 Not Modified with no body, so polling clients only download
+// changes. Only 200 responses are tagged. A handler whose body holds fields
+// that should not change the tag sets the ETag header itself, usually with
+// bodyETag over the stable part.
+func ETag(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ew := &etagResponseWriter{header: make(http.Header)}
+		next(ew, r)
+
+		for key, values := range ew.header {
+			w.Header()[key] = values
+		}
+		if ew.status == 0 {
+			ew.status = http.StatusOK
+		}
+		if ew.status != http.StatusOK {
+			w.WriteHeader(ew.status)
+			w.Write(ew.body.Bytes())
+			return
+		}
+
+		tag := w.Header().Get("ETag")
+		if tag == "" {
+			tag = bodyETag(ew.body.Bytes())
+			w.Header().Set("ETag", tag)
+		}
+
+		if etagMatches(r.Header.Get("If-None-Match"), tag) {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(ew.body.Bytes())
+	}
+}
+
+// bodyETag returns the strong ETag of a response body
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists tag or is "*".
+// Weak tags match their strong form, as RFC 9110 requires for If-None-Match.
+func etagMatches(ifNoneMatch, tag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagResponseWriter holds a response in memory until its ETag is known
+type etagResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (e *etagResponseWriter) Header() http.Header { return e.header }
+
+func (e *etagResponseWriter) WriteHeader(code int) {
+	if e.status == 0 {
+		e.status = code
+	}
+}
+
+func (e *etagResponseWriter) Write(p []byte) (int, error) {
+	if e.status == 0 {
+		e.status = http.StatusOK
+	}
+	return e.body.Write(p)
+}
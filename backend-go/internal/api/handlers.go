@@ -6,21 +6,32 @@ import (
 	"backend-go/internal/models"
 	"backend-go/internal/service"
 	"backend-go/internal/state"
+	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -29,7 +40,7 @@ const (
 )
 
 type Handler struct {
-	ContextService            *service.ContextService
+	Contexts                  *service.NamespacedContextService // Analysis stores per namespace; handlers use store(r)
 	QuestionGenerator         *service.QuestionGenerator
 	CSVService                *analysis.CSVService
 	SimilarityService         *service.SimilarityService
@@ -37,12 +48,116 @@ type Handler struct {
 	EnhancedSimilarityService *service.EnhancedSimilarityService
 	AISemanticMatcher         *service.AISemanticMatcher
 	LLMService                *llm.Service
-	CurrentDB                 service.DataSource // Active DB connection
+	MaxWorkers                int                 // Concurrent analyses for batch uploads
+	MaxUploadBytes            int64               // Request body limit for uploads (MAX_UPLOAD_MB)
+	JobStore                  *service.JobStore   // Background analysis jobs
+	GraphCache                *service.GraphCache // Recently generated similarity graphs
+	Logger                    *slog.Logger
+	AuditLogger               AuditLogger       // Records mutating operations; NopAuditLogger by default
+	APIKeys                   []APIKey          // Accepted X-API-Key values and their namespaces; empty disables auth
+	AdminAPIKey               string            // X-Admin-Key value for admin routes (ADMIN_API_KEY); empty disables them
+	AllowedOrigins            []string          // CORS origins
+	ExtraHeaders              map[string]string // Set on every response (EXTRA_HEADERS)
+	RateLimitRPS              float64
+	RateLimitBurst            int
+	WebhookSecret             string // HMAC key for webhook signatures (WEBHOOK_SECRET)
+
+	// AnalyzeFile and AnalyzeTable hold a workerPool slot while analyzing; a
+	// request that waits longer than WorkerWaitTimeout for one gets a 503
+	WorkerWaitTimeout time.Duration
+	workerPool        chan struct{}
+
+	// Running totals since startup, also exported as Prometheus counters
+	TotalBytesAnalyzed atomic.Int64
+	TotalAnalysesRun   atomic.Int64
+
+	// Active DB connection of each namespace and the sampling chosen when it
+	// was connected; read them with currentDB and replace them with swapDB
+	dbMu sync.RWMutex
+	dbs  map[string]dbConn
+
+	// Legacy upload dataframes and contexts of namespaces other than the
+	// default one, which keeps using state.State; see legacyState
+	legacyMu sync.Mutex
+	legacy   map[string]*state.AppState
+
+	// Background work started by the handler; cancelled by Shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+	jobs   sync.WaitGroup
 }
 
-func NewHandler(ctx *service.ContextService, qg *service.QuestionGenerator, csv *analysis.CSVService, sim *service.SimilarityService, export *service.ExportService, llmSvc *llm.Service) *Handler {
+// Config holds the HTTP-level settings for a Handler
+type Config struct {
+	AllowedOrigins []string      // CORS origins; "*" allows any
+	RateLimitRPS   float64       // Per-IP limit on the analyze endpoints (default DefaultRateLimitRPS)
+	RateLimitBurst int           // Default DefaultRateLimitBurst
+	StorageDir     string        // Persist analyses and contexts here; empty keeps them in memory only
+	AnalysisTTL    time.Duration // Stored analyses go stale after this long (default service.DefaultAnalysisTTL)
+	GraphCacheSize int           // Similarity graphs kept in GraphCache (default service.DefaultGraphCacheSize)
+
+	AnalysisWorkers   int           // Concurrent AnalyzeFile and AnalyzeTable analyses (default DefaultAnalysisWorkers)
+	WorkerWaitTimeout time.Duration // Wait for a free worker before answering 503 (default DefaultWorkerWaitTimeout)
+}
+
+// NewHandler wires the services into a Handler. When cfg.StorageDir is set,
+// analyses and contexts previously written there are loaded into ctx.
+func NewHandler(ctx *service.ContextService, qg *service.QuestionGenerator, csv *analysis.CSVService, sim *service.SimilarityService, export *service.ExportService, llmSvc *llm.Service, cfg Config) (*Handler, error) {
+	// The default namespace keeps ctx and the top of StorageDir; every other
+	// namespace gets its own memory store and StorageDir/namespaces/<name>
+	contexts := service.NewNamespacedContextService(func(namespace string) (*service.AnalysisCache, error) {
+		inner, dir := ctx, cfg.StorageDir
+		if namespace != service.DefaultNamespace {
+			inner = service.NewContextService()
+			if dir != "" {
+				dir = filepath.Join(dir, "namespaces", namespace)
+			}
+		}
+
+		var store service.ContextStore = inner
+		if dir != "" {
+			persistent, err := service.NewPersistentContextService(inner, dir)
+			if err != nil {
+				return nil, err
+			}
+			store = persistent
+		}
+		return service.NewAnalysisCache(store, cfg.AnalysisTTL), nil
+	})
+
+	extraHeaders, err := parseExtraHeaders(os.Getenv("EXTRA_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Load what is on disk now, so a bad storage dir fails at startup
+	apiKeys := parseAPIKeys(os.Getenv("API_KEYS"))
+	if _, err := contexts.For(service.DefaultNamespace); err != nil {
+		return nil, err
+	}
+	for _, key := range apiKeys {
+		if _, err := contexts.For(key.Namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.RateLimitRPS <= 0 {
+		cfg.RateLimitRPS = DefaultRateLimitRPS
+	}
+	if cfg.RateLimitBurst <= 0 {
+		cfg.RateLimitBurst = DefaultRateLimitBurst
+	}
+	if cfg.AnalysisWorkers <= 0 {
+		cfg.AnalysisWorkers = DefaultAnalysisWorkers
+	}
+	if cfg.WorkerWaitTimeout <= 0 {
+		cfg.WorkerWaitTimeout = DefaultWorkerWaitTimeout
+	}
+
+	bgCtx, cancel := context.WithCancel(context.Background())
+
 	return &Handler{
-		ContextService:            ctx,
+		Contexts:                  contexts,
 		QuestionGenerator:         qg,
 		CSVService:                csv,
 		SimilarityService:         sim,
@@ -50,1752 +165,3631 @@ func NewHandler(ctx *service.ContextService, qg *service.QuestionGenerator, csv
 		EnhancedSimilarityService: service.NewEnhancedSimilarityService(ctx),
 		AISemanticMatcher:         service.NewAISemanticMatcher(llmSvc, ctx),
 		LLMService:                llmSvc,
+		MaxWorkers:                4,
+		MaxUploadBytes:            maxUploadBytes(),
+		JobStore:                  service.NewJobStore(time.Hour),
+		GraphCache:                service.NewGraphCache(cfg.GraphCacheSize),
+		Logger:                    slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		AuditLogger:               NopAuditLogger{},
+		APIKeys:                   apiKeys,
+		AdminAPIKey:               os.Getenv("ADMIN_API_KEY"),
+		AllowedOrigins:            cfg.AllowedOrigins,
+		ExtraHeaders:              extraHeaders,
+		RateLimitRPS:              cfg.RateLimitRPS,
+		RateLimitBurst:            cfg.RateLimitBurst,
+		WebhookSecret:             os.Getenv("WEBHOOK_SECRET"),
+		WorkerWaitTimeout:         cfg.WorkerWaitTimeout,
+		workerPool:                make(chan struct{}, cfg.AnalysisWorkers),
+		ctx:                       bgCtx,
+		cancel:                    cancel,
+	}, nil
+}
+
+// maxUploadBytes reads MAX_UPLOAD_MB, defaulting to 10 MB
+func maxUploadBytes() int64 {
+	if mb, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_MB"), 10, 64); err == nil && mb > 0 {
+		return mb << 20
+	}
+	return 10 << 20
+}
+
+// Shutdown cancels background analysis jobs, waits for them to exit and
+// closes every database connection. Call it after the HTTP server has drained.
+func (h *Handler) Shutdown() {
+	h.cancel()
+	h.jobs.Wait()
+	h.JobStore.Close()
+
+	h.dbMu.Lock()
+	dbs := h.dbs
+	h.dbs = nil
+	h.dbMu.Unlock()
+	dbConnected.Set(0)
+
+	for namespace, conn := range dbs {
+		if err := conn.ds.Close(); err != nil {
+			h.Logger.Error("closing database connection", "err", err, "namespace", namespace)
+		}
+	}
+}
+
+// dbConn is a namespace's DB connection and the sampling it was connected with
+type dbConn struct {
+	ds       service.DataSource
+	sampling models.SamplingStrategy
+}
+
+// currentDB returns the active DB connection of the request's namespace, nil
+// if there is none, and the sampling strategy it was connected with. Handlers
+// take one copy up front so a concurrent disconnect cannot swap it out
+// between the nil check and use.
+func (h *Handler) currentDB(r *http.Request) (service.DataSource, models.SamplingStrategy) {
+	h.dbMu.RLock()
+	defer h.dbMu.RUnlock()
+
+	conn := h.dbs[GetNamespace(r.Context())]
+	return conn.ds, conn.sampling
+}
+
+// swapDB makes ds the active DB connection of the request's namespace (nil
+// removes it) and returns the previous one, which the caller closes outside
+// the lock
+func (h *Handler) swapDB(r *http.Request, ds service.DataSource, sampling models.SamplingStrategy) service.DataSource {
+	namespace := GetNamespace(r.Context())
+
+	h.dbMu.Lock()
+	defer h.dbMu.Unlock()
+
+	old := h.dbs[namespace].ds
+	if ds == nil {
+		delete(h.dbs, namespace)
+	} else {
+		if h.dbs == nil {
+			h.dbs = make(map[string]dbConn)
+		}
+		h.dbs[namespace] = dbConn{ds: ds, sampling: sampling}
+	}
+	dbConnected.Set(float64(len(h.dbs)))
+	return old
+}
+
+// legacyState returns the legacy upload state of the request's namespace. The
+// default namespace uses the global state.State, which also carries the
+// Ollama config.
+func (h *Handler) legacyState(r *http.Request) *state.AppState {
+	namespace := GetNamespace(r.Context())
+	if namespace == service.DefaultNamespace {
+		return state.State
+	}
+
+	h.legacyMu.Lock()
+	defer h.legacyMu.Unlock()
+
+	if h.legacy == nil {
+		h.legacy = make(map[string]*state.AppState)
+	}
+	st, ok := h.legacy[namespace]
+	if !ok {
+		st = &state.AppState{}
+		h.legacy[namespace] = st
 	}
+	return st
 }
 
 func (h *Handler) RegisterRoutes(r chi.Router) {
+	r.Use(StaticHeadersMiddleware(h.ExtraHeaders))
+	r.Use(CORSMiddleware(h.AllowedOrigins))
+	r.Use(RequestID)
+	r.Use(h.logRequest)
+	r.Use(Compress(DefaultCompressMinSize))
+
 	// API V2 Routes (My Migration)
 	r.Get("/health", h.HealthCheck)
-	r.Post("/api/analyze-file", h.AnalyzeFile)
-	r.Post("/api/context/{fileIndex}", h.StoreContext)
-	r.Get("/api/questions/{fileIndex}", h.GetQuestions)
-	r.Get("/api/similarity/graph", h.GetSimilarityGraph)
-	r.Post("/api/export/sql", h.ExportSQL)
-	r.Post("/api/export/python", h.ExportPython)
-	r.Get("/api/status", h.GetAnalysisStatus)
-	r.Get("/api/context/status", h.GetAnalysisContextStatus)
-
-	// DB Routes
-	r.Post("/api/db/connect", h.ConnectDB)
-	r.Get("/api/db/tables", h.ListTables)
-	r.Post("/api/db/analyze", h.AnalyzeTable)
-
-	// Upstream/Legacy Routes
-	r.Post("/upload", h.Upload)
-	r.Get("/status", h.GetStatus)
-	r.Get("/preview", h.GetPreview)
-	r.Get("/column-types", h.GetColumnTypes)
-	r.Get("/kpis", h.GetKPIs)
-
-	r.Get("/column-similarity", h.GetColumnSimilarity)
-	r.Get("/correlation", h.GetCorrelation)
-	r.Post("/filter", h.FilterData)
-	r.Post("/query", h.Query)
-
-	r.Post("/context/questions", h.GenerateContextQuestions)
-	r.Post("/context/submit", h.SubmitContext)
-	r.Get("/context/{fileIndex}", h.GetContext)
-	r.Delete("/context/{fileIndex}", h.DeleteContext)
-	r.Get("/context/status", h.GetContextStatus)
-
-	r.Get("/config/ollama", h.GetOllamaConfig)
-	r.Post("/config/ollama", h.SaveOllamaConfig)
-
-	r.Post("/feedback/match", h.SubmitMatchFeedback)
-	r.Get("/feedback/stats", h.GetFeedbackStats)
+	r.Handle("/metrics", promhttp.Handler())
+
+	// Admin routes take the admin key instead of a regular API key
+	r.With(h.adminOnly).Post("/api/warmup", h.Warmup)
+
+	// Uploads and analysis are the expensive part, so every route that runs
+	// them shares one per-IP limit
+	limit := h.rateLimitMiddleware(h.RateLimitRPS, h.RateLimitBurst)
+
+	r.Route("/api", func(r chi.Router) {
+		r.Use(AuthMiddleware(h.APIKeys))
+		r.Use(h.namespaceStore)
+
+		r.Get("/openapi.json", h.OpenAPISpec)
+
+		r.With(limit).Post("/analyze-file", instrument("analyze_file", h.AnalyzeFile))
+		r.With(limit).Post("/analyze-file/stream", h.AnalyzeFileStream)
+		r.With(limit).Post("/analyze-files", h.AnalyzeFiles)
+		r.Get("/jobs/{id}", h.GetJob)
+		r.Get("/jobs/{id}/stream", h.StreamJob)
+		r.Post("/context/{fileIndex}", h.StoreContext)
+		r.Post("/context/templates", h.StoreContextTemplate)
+		r.Post("/context/{fileIndex}/from-template/{templateName}", h.ApplyContextTemplate)
+		r.Get("/context/{fileIndex}/validate", h.ValidateStoredContext)
+		r.Delete("/context/{fileIndex}", h.DeleteAnalysisContext)
+		r.Delete("/analysis/{fileIndex}", h.DeleteAnalysis)
+		r.Post("/analysis/{fileIndex}/invalidate", h.InvalidateAnalysis)
+		r.Get("/analysis/{fileIndex}/correlations", h.GetCorrelations)
+		r.Get("/analysis/{fileIndex}/fingerprint", h.GetSchemaFingerprint)
+		r.Get("/analysis/{fileIndex}/column/{columnName}/topvalues", h.GetTopValues)
+		r.Get("/analysis/{fileIndex}/export", h.auditExport("analysis", h.ExportAnalysis))
+		r.Get("/analysis/{fileIndex}/arrow", h.auditExport("arrow", h.ExportAnalysisArrow))
+		r.Get("/analysis/diff", h.GetAnalysisDiff)
+		r.Post("/analysis/{fileIndex}/annotate", h.AnnotateColumn)
+		r.Get("/questions/{fileIndex}", instrument("get_questions", ETag(h.GetQuestions)))
+		r.Get("/questions/{fileIndex}/export", h.auditExport("questions", h.ExportQuestions))
+		r.Get("/similarity/graph", instrument("similarity_graph", ETag(h.GetSimilarityGraph)))
+		r.Post("/similarity/graph/invalidate", h.InvalidateGraphCache)
+		r.Get("/similarity/fk-candidates", h.GetFKCandidates)
+		r.Post("/export/sql", h.auditExport("sql", h.ExportSQL))
+		r.Post("/export/python", h.auditExport("python", h.ExportPython))
+		r.Post("/export/pyspark", h.auditExport("pyspark", h.ExportPySpark))
+		r.Post("/export/sqlalchemy", h.auditExport("sqlalchemy", h.ExportSQLAlchemy))
+		r.Post("/export/r", h.auditExport("r", h.ExportR))
+		r.Post("/export/notebook", h.auditExport("notebook", h.ExportNotebook))
+		r.Post("/export/gorm", h.auditExport("gorm", h.ExportGORM))
+		r.Post("/export/dbt", h.auditExport("dbt", h.ExportDBT))
+		r.Get("/export/jsonschema/{fileIndex}", h.auditExport("jsonschema", h.ExportJSONSchema))
+		r.Get("/export/typescript/{fileIndex}", h.auditExport("typescript", h.ExportTypeScript))
+		r.Get("/export/avro/{fileIndex}", h.auditExport("avro", h.ExportAvro))
+		r.Get("/export/proto/{fileIndex}", h.auditExport("proto", h.ExportProto))
+		r.Get("/status", ETag(h.GetAnalysisStatus))
+		r.Get("/analyses", h.ListAnalyses)
+		r.Get("/contexts", h.ListContexts)
+		r.Delete("/data/all", h.WipeAllData)
+		r.Get("/context/status", h.GetAnalysisContextStatus)
+
+		// DB Routes
+		r.Post("/db/connect", instrument("connect_db", h.ConnectDB))
+		r.Get("/db/tables", h.ListTables)
+		r.Get("/db/views", h.ListViews)
+		r.Get("/db/schema", h.auditExport("ddl", h.ExportDBSchema))
+		r.Get("/db/index-recommendations", h.GetIndexRecommendations)
+		r.With(limit).Post("/db/analyze", instrument("analyze_table", h.AnalyzeTable))
+		r.Get("/db/preview", h.PreviewTable)
+		r.With(limit).Post("/db/query", instrument("query_db", h.QueryDB))
+		r.Get("/db/ping", h.PingDB)
+		r.Delete("/db/disconnect", h.DisconnectDB)
+	})
+
+	// Upstream/Legacy Routes. They keep their unprefixed paths but take API
+	// keys like /api, so each namespace gets its own legacy state.
+	r.Group(func(r chi.Router) {
+		r.Use(AuthMiddleware(h.APIKeys))
+		r.Use(h.namespaceStore)
+
+		r.With(limit).Post("/upload", h.Upload)
+		r.Get("/status", ETag(h.GetStatus))
+		r.Get("/preview", h.GetPreview)
+		r.Get("/column-types", h.GetColumnTypes)
+		r.Get("/kpis", h.GetKPIs)
+
+		r.Get("/column-similarity", h.GetColumnSimilarity)
+		r.Get("/correlation", h.GetCorrelation)
+		r.Post("/filter", h.FilterData)
+		r.Post("/query", h.Query)
+
+		r.Post("/context/questions", h.GenerateContextQuestions)
+		r.Post("/context/submit", h.SubmitContext)
+		r.Get("/context/{fileIndex}", h.GetContext)
+		r.Delete("/context/{fileIndex}", h.DeleteContext)
+		r.Get("/context/status", h.GetContextStatus)
+
+		r.Get("/config/ollama", h.GetOllamaConfig)
+		r.Post("/config/ollama", h.SaveOllamaConfig)
+
+		r.Post("/feedback/match", h.SubmitMatchFeedback)
+		r.Get("/feedback/stats", h.GetFeedbackStats)
+	})
 }
 
 // ============================================================================
 // Health
 // ============================================================================
 
+// Version is the build version, set with
+// -ldflags "-X backend-go/internal/api.Version=1.2.3"
+var Version = "dev"
+
+// HealthCheck reports service status and pings the active database, answering
+// 503 when the connection is broken
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("OK"))
+	db, _ := h.currentDB(r)
+	resp := map[string]interface{}{
+		"status":          "ok",
+		"db_connected":    db != nil,
+		"analyses_loaded": len(h.store(r).AnalysisIndices()),
+		"version":         Version,
+	}
+	status := http.StatusOK
+
+	if db != nil {
+		if err := db.Ping(); err != nil {
+			resp["status"] = "degraded"
+			resp["db_error"] = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
 }
 
 // ConnectDB establishes a database connection
 func (h *Handler) ConnectDB(w http.ResponseWriter, r *http.Request) {
 	var config service.DataSourceConfig
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	// Currently only Postgres supported
-	if config.Type != "postgres" {
-		http.Error(w, "Only postgres is supported currently", http.StatusBadRequest)
+	ds, err := service.NewDataSource(config.Type)
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sampling, err := analysis.ParseSamplingStrategy(string(config.Sampling))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ds := &service.PostgresDataSource{}
 	if err := ds.Connect(config); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to connect: %v", err), http.StatusInternalServerError)
+		h.httpError(w, r, fmt.Sprintf("Failed to connect: %v", err), http.StatusInternalServerError, "err", err, "db_type", config.Type)
 		return
 	}
 
 	// Close previous if exists
-	if h.CurrentDB != nil {
-		h.CurrentDB.Close()
+	if old := h.swapDB(r, ds, sampling); old != nil {
+		old.Close()
 	}
-	h.CurrentDB = ds
+	h.audit(r, AuditEntry{Operation: AuditConnect, Detail: config.Type})
 
 	json.NewEncoder(w).Encode(map[string]string{"status": "connected"})
 }
 
-// ListTables returns tables from connected DB
-func (h *Handler) ListTables(w http.ResponseWriter, r *http.Request) {
-	if h.CurrentDB == nil {
-		http.Error(w, "No database connection", http.StatusBadRequest)
+// DisconnectDB closes the active database connection
+func (h *Handler) DisconnectDB(w http.ResponseWriter, r *http.Request) {
+	db := h.swapDB(r, nil, "")
+	if db == nil {
+		h.httpError(w, r, "No database connection", http.StatusNotFound)
 		return
 	}
 
-	tables, err := h.CurrentDB.ListTables()
+	err := db.Close()
+	h.audit(r, AuditEntry{Operation: AuditDisconnect})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error listing tables: %v", err), http.StatusInternalServerError)
+		h.httpError(w, r, fmt.Sprintf("Error closing connection: %v", err), http.StatusInternalServerError, "err", err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{"tables": tables})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "disconnected"})
 }
 
-// AnalyzeTable fetches data from a table and analyzes it
-func (h *Handler) AnalyzeTable(w http.ResponseWriter, r *http.Request) {
-	if h.CurrentDB == nil {
-		http.Error(w, "No database connection", http.StatusBadRequest)
+// PingDB reports whether the database connection is still live. It always
+// answers 200 so the frontend can poll it for a connection indicator.
+func (h *Handler) PingDB(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{"alive": true}
+	if db, _ := h.currentDB(r); db == nil {
+		resp["alive"] = false
+		resp["error"] = "No database connection"
+	} else if err := db.Ping(); err != nil {
+		resp["alive"] = false
+		resp["error"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListTables returns tables from connected DB
+func (h *Handler) ListTables(w http.ResponseWriter, r *http.Request) {
+	db, _ := h.currentDB(r)
+	if db == nil {
+		h.httpError(w, r, "No database connection", http.StatusBadRequest)
 		return
 	}
 
-	var req struct {
-		TableName string `json:"table_name"`
-		FileIndex int    `json:"file_index"`
+	// limit=0 (the default) returns every table
+	limit := getIntParam(r, "limit", 0)
+	offset := getIntParam(r, "offset", 0)
+	if limit < 0 || offset < 0 {
+		h.httpError(w, r, "limit and offset must be non-negative", http.StatusBadRequest)
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+
+	detail := r.URL.Query().Get("detail") == "true"
+	includeViews := r.URL.Query().Get("include_views") == "true"
+
+	// The detailed and merged listings are assembled and paged in memory
+	if detail || includeViews {
+		relations, err := listRelations(db, detail, includeViews)
+		if err != nil {
+			h.httpError(w, r, fmt.Sprintf("Error listing tables: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tables": pageOf(relations, limit, offset),
+			"total":  len(relations),
+			"limit":  limit,
+			"offset": offset,
+		})
 		return
 	}
 
-	// Fetch data (preview limit 1000 rows for analysis)
-	data, err := h.CurrentDB.PreviewData(req.TableName, 1000)
+	tables, total, err := db.ListTablesPaged(limit, offset)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching data: %v", err), http.StatusInternalServerError)
+		h.httpError(w, r, fmt.Sprintf("Error listing tables: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Analyze
-	if len(data) == 0 {
-		http.Error(w, "Table is empty", http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tables": tables,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// maxPreviewRows caps the number of rows returned by PreviewTable
+const maxPreviewRows = 500
+
+// PreviewTable returns sample rows from a table in columnar form without analyzing them
+func (h *Handler) PreviewTable(w http.ResponseWriter, r *http.Request) {
+	db, _ := h.currentDB(r)
+	if db == nil {
+		h.httpError(w, r, "No database connection", http.StatusBadRequest)
 		return
 	}
 
-	var columns []string
-	for k := range data[0] {
-		columns = append(columns, k)
+	table := r.URL.Query().Get("table")
+	if table == "" {
+		h.httpError(w, r, "table parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := getIntParam(r, "limit", 50)
+	if limit <= 0 {
+		h.httpError(w, r, "limit must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	if limit > maxPreviewRows {
+		limit = maxPreviewRows
 	}
 
-	analysisResult, err := h.CSVService.AnalyzeData(data, columns)
+	// Only preview relations the database lists, so the name is never
+	// arbitrary SQL
+	known, err := isRelation(db, table)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error analyzing data: %v", err), http.StatusInternalServerError)
+		h.httpError(w, r, fmt.Sprintf("Error listing tables: %v", err), http.StatusInternalServerError, "err", err)
+		return
+	}
+	if !known {
+		h.httpError(w, r, fmt.Sprintf("Unknown table %q", table), http.StatusNotFound)
 		return
 	}
 
-	// Store result
-	if req.FileIndex != 0 {
-		h.ContextService.StoreAnalysis(req.FileIndex, &analysisResult)
+	columns, data, err := db.PreviewData(table, limit)
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error fetching data: %v", err), http.StatusInternalServerError, "err", err, "table", table)
+		return
+	}
+	if len(columns) == 0 && len(data) > 0 {
+		columns = sortedKeys(data[0])
+	}
+
+	rows := make([][]interface{}, 0, len(data))
+	for _, row := range data {
+		values := make([]interface{}, len(columns))
+		for i, col := range columns {
+			values[i] = row[col]
+		}
+		rows = append(rows, values)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(analysisResult)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"columns": columns,
+		"rows":    rows,
+	})
 }
 
-// GetAnalysisStatus returns the status of loaded files (My V2 impl)
-func (h *Handler) GetAnalysisStatus(w http.ResponseWriter, r *http.Request) {
-	analysis1 := h.ContextService.GetAnalysis(1)
-	analysis2 := h.ContextService.GetAnalysis(2)
+// dbRelation is a table or view name tagged with its kind
+type dbRelation struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
 
-	status := map[string]interface{}{
-		"loaded":        analysis1 != nil || analysis2 != nil,
-		"file1_loaded":  analysis1 != nil,
-		"file2_loaded":  analysis2 != nil,
-		"file1_context": h.ContextService.GetContext(1) != nil,
-		"file2_context": h.ContextService.GetContext(2) != nil,
-		"file1":         analysis1,
-		"file2":         analysis2,
+// isRelation reports whether name is one of the connected database's tables
+// or views
+func isRelation(db service.DataSource, name string) (bool, error) {
+	tables, err := db.ListTables()
+	if err != nil {
+		return false, err
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
+	if slices.Contains(tables, name) {
+		return true, nil
+	}
+	views, err := db.ListViews()
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(views, name), nil
 }
 
-// GetAnalysisContextStatus returns context status (My V2 impl)
-func (h *Handler) GetAnalysisContextStatus(w http.ResponseWriter, r *http.Request) {
-	// This structure matches Python backend likely
-	status := map[string]map[string]bool{
-		"file1": {"has_context": h.ContextService.GetContext(1) != nil},
-		"file2": {"has_context": h.ContextService.GetContext(2) != nil},
+// listRelations returns the connected database's tables, as TableInfo when
+// detail is set, followed by its views when includeViews is set. Entries carry
+// a "table" or "view" type only when views are included.
+func listRelations(db service.DataSource, detail, includeViews bool) ([]interface{}, error) {
+	relations := []interface{}{}
+
+	if detail {
+		infos, err := db.ListTablesWithSchema()
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if includeViews {
+				info.Type = "table"
+			}
+			relations = append(relations, info)
+		}
+	} else {
+		tables, err := db.ListTables()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range tables {
+			relations = append(relations, dbRelation{Name: name, Type: "table"})
+		}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
-}
 
-// AnalyzeFile handles file upload and analysis (My V2 impl)
-func (h *Handler) AnalyzeFile(w http.ResponseWriter, r *http.Request) {
-	// Limit upload size (e.g., 10MB)
-	r.ParseMultipartForm(10 << 20)
+	if !includeViews {
+		return relations, nil
+	}
 
-	file, header, err := r.FormFile("file")
+	views, err := db.ListViews()
 	if err != nil {
-		http.Error(w, "Error retrieving file", http.StatusBadRequest)
+		return nil, err
+	}
+	for _, name := range views {
+		if detail {
+			relations = append(relations, service.TableInfo{Name: name, Type: "view"})
+		} else {
+			relations = append(relations, dbRelation{Name: name, Type: "view"})
+		}
+	}
+	return relations, nil
+}
+
+// ExportDBSchema dumps the schema of the connected database as CREATE TABLE
+// statements, or with ?format=json as a service.DatabaseSchema
+func (h *Handler) ExportDBSchema(w http.ResponseWriter, r *http.Request) {
+	db, _ := h.currentDB(r)
+	if db == nil {
+		h.httpError(w, r, "No database connection", http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
 
-	// Create a temp file
-	tempDir := os.TempDir()
-	tempFilePath := filepath.Join(tempDir, header.Filename)
-	tempFile, err := os.Create(tempFilePath)
-	if err != nil {
-		http.Error(w, "Error creating temp file", http.StatusInternalServerError)
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "sql" && format != "json" {
+		h.httpError(w, r, "format must be sql or json", http.StatusBadRequest)
 		return
 	}
-	defer os.Remove(tempFilePath) // Clean up
-	defer tempFile.Close()
 
-	if _, err := io.Copy(tempFile, file); err != nil {
-		http.Error(w, "Error saving file", http.StatusInternalServerError)
+	if format == "json" {
+		schema, err := db.DescribeSchema()
+		if err != nil {
+			h.httpError(w, r, fmt.Sprintf("Error reading schema: %v", err), http.StatusInternalServerError, "err", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schema)
 		return
 	}
 
-	// Analyze the file
-	analysisResult, err := h.CSVService.AnalyzeFile(tempFilePath)
+	ddl, err := db.ExportSchema()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error analyzing file: %v", err), http.StatusInternalServerError)
+		h.httpError(w, r, fmt.Sprintf("Error reading schema: %v", err), http.StatusInternalServerError, "err", err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/sql")
+	w.Write([]byte(ddl))
+}
 
-	// Store analysis result if fileIndex is provided
-	fileIndexStr := r.FormValue("fileIndex")
-	if fileIndexStr == "" {
-		fileIndexStr = r.FormValue("file_index")
+// ListViews returns views from the connected DB. Views can be passed to
+// AnalyzeTable and PreviewTable like tables.
+func (h *Handler) ListViews(w http.ResponseWriter, r *http.Request) {
+	db, _ := h.currentDB(r)
+	if db == nil {
+		h.httpError(w, r, "No database connection", http.StatusBadRequest)
+		return
 	}
 
-	if fileIndexStr != "" {
-		if fileIndex, err := strconv.Atoi(fileIndexStr); err == nil {
-			h.ContextService.StoreAnalysis(fileIndex, &analysisResult)
-		}
+	limit := getIntParam(r, "limit", 0)
+	offset := getIntParam(r, "offset", 0)
+	if limit < 0 || offset < 0 {
+		h.httpError(w, r, "limit and offset must be non-negative", http.StatusBadRequest)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(analysisResult)
+	views, err := db.ListViews()
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error listing views: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"views":  pageOf(views, limit, offset),
+		"total":  len(views),
+		"limit":  limit,
+		"offset": offset,
+	})
 }
 
-func (h *Handler) Upload(w http.ResponseWriter, r *http.Request) {
-	// Parse multipart form (max 100MB)
-	if err := r.ParseMultipartForm(MaxFileSize); err != nil {
-		http.Error(w, "File too large", http.StatusBadRequest)
+// pageOf returns items[offset:offset+limit], clamped to the slice; a limit of
+// 0 returns everything from offset onwards
+func pageOf[T any](items []T, limit, offset int) []T {
+	items = items[min(offset, len(items)):]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// Row limits for table analysis
+const (
+	defaultAnalyzeRowLimit = 1000
+	maxAnalyzeRowLimit     = 100000
+)
+
+// AnalyzeTable fetches data from a table or view and analyzes it
+func (h *Handler) AnalyzeTable(w http.ResponseWriter, r *http.Request) {
+	db, dbSampling := h.currentDB(r)
+	if db == nil {
+		h.httpError(w, r, "No database connection", http.StatusBadRequest)
 		return
 	}
 
-	// Get file_index parameter
-	fileIndexStr := r.FormValue("file_index")
-	if fileIndexStr == "" {
-		fileIndexStr = "1"
+	var req struct {
+		TableName string `json:"table_name"`
+		FileIndex int    `json:"file_index"`
+		RowLimit  int    `json:"row_limit"`
 	}
-	fileIndex, err := strconv.Atoi(fileIndexStr)
-	if err != nil || (fileIndex != 1 && fileIndex != 2) {
-		http.Error(w, "file_index must be 1 or 2", http.StatusBadRequest)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	// Get file from form
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "No file uploaded", http.StatusBadRequest)
-		return
+	// file_index is optional; 0 means "don't store"
+	if req.FileIndex != 0 {
+		if err := service.ValidateFileIndex(req.FileIndex); err != nil {
+			h.httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
-	defer file.Close()
 
-	// Validate file extension
-	if !strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
-		http.Error(w, "Only CSV files are allowed", http.StatusBadRequest)
+	if req.RowLimit == 0 {
+		req.RowLimit = defaultAnalyzeRowLimit
+	}
+	if req.RowLimit < 0 || req.RowLimit > maxAnalyzeRowLimit {
+		h.httpError(w, r, fmt.Sprintf("row_limit must be between 1 and %d", maxAnalyzeRowLimit), http.StatusBadRequest)
 		return
 	}
 
-	// Create upload directory
-	os.MkdirAll(UploadDir, 0755)
+	release, ok := h.acquireWorker(w, r)
+	if !ok {
+		return
+	}
+	defer release()
 
-	// Save file
-	filename := fmt.Sprintf("file%d_%s", fileIndex, filepath.Base(header.Filename))
-	filePath := filepath.Join(UploadDir, filename)
+	ctx, span := tracer.Start(r.Context(), "AnalyzeTable")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.table", req.TableName),
+		attribute.Int("row_limit", req.RowLimit),
+	)
 
-	dst, err := os.Create(filePath)
+	// Fetch the sample rows used for analysis. Random sampling runs in SQL
+	// where the source supports it. Otherwise random and systematic sampling
+	// apply to the first maxAnalyzeRowLimit rows, not the whole table.
+	var columns []string
+	var data []map[string]interface{}
+	var err error
+	_, fetchSpan := tracer.Start(ctx, "DataSource.FetchRows")
+	if sampler, ok := db.(service.RandomSampler); ok && dbSampling == models.SamplingRandom {
+		columns, data, err = sampler.SampleData(req.TableName, req.RowLimit)
+	} else {
+		fetchLimit := req.RowLimit
+		if dbSampling != models.SamplingHead {
+			fetchLimit = maxAnalyzeRowLimit
+		}
+		columns, data, err = db.PreviewData(req.TableName, fetchLimit)
+		data = analysis.SampleRows(data, analysis.SamplingConfig{Strategy: dbSampling, Size: req.RowLimit})
+	}
+	fetchSpan.End()
 	if err != nil {
-		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		h.httpError(w, r, fmt.Sprintf("Error fetching data: %v", err), http.StatusInternalServerError, "err", err, "table", req.TableName)
 		return
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
-		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+	// Analyze
+	if len(data) == 0 {
+		h.httpError(w, r, "Table is empty", http.StatusBadRequest)
 		return
 	}
 
-	// Parse CSV
-	df, err := parseCSVFile(filePath)
+	// Keep the table's column order; fall back to a stable sorted order
+	if len(columns) == 0 {
+		columns = sortedKeys(data[0])
+	}
+
+	analysisResult, err := h.CSVService.AnalyzeDataContext(ctx, data, columns)
 	if err != nil {
-		os.Remove(filePath)
-		http.Error(w, fmt.Sprintf("Failed to parse CSV: %v", err), http.StatusBadRequest)
+		h.httpError(w, r, fmt.Sprintf("Error analyzing data: %v", err), http.StatusInternalServerError, "err", err, "table", req.TableName)
 		return
 	}
-	df.FileName = header.Filename
-	df.FilePath = filePath
-
-	// Store in state
-	state.State.SetDataFrame(fileIndex, df)
+	analysisResult.FileName = req.TableName
+	if dbSampling != models.SamplingHead {
+		analysisResult.Sampling = dbSampling
+	}
+	h.recordAnalysis(estimateRowBytes(data))
 
-	// Return response
-	resp := models.UploadResponse{
-		Message:     fmt.Sprintf("File '%s' uploaded successfully", header.Filename),
-		Rows:        len(df.Rows),
-		Columns:     len(df.Headers),
-		ColumnNames: df.Headers,
+	// Store result
+	if req.FileIndex != 0 {
+		h.storeAnalysis(h.store(r), req.FileIndex, &analysisResult)
 	}
+	h.audit(r, AuditEntry{Operation: AuditAnalyze, FileIndex: req.FileIndex, TableName: req.TableName})
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(analysisResult)
 }
 
-func parseCSVFile(filePath string) (*state.DataFrame, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+// QueryDB runs a read-only SELECT against the connected database and analyzes
+// the result set the same way AnalyzeTable analyzes a table
+func (h *Handler) QueryDB(w http.ResponseWriter, r *http.Request) {
+	db, _ := h.currentDB(r)
+	if db == nil {
+		h.httpError(w, r, "No database connection", http.StatusBadRequest)
+		return
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1 // Allow variable fields
-	reader.LazyQuotes = true    // Allow bare quotes in non-quoted fields
-	reader.TrimLeadingSpace = true
+	var req struct {
+		SQL       string `json:"sql"`
+		FileIndex int    `json:"file_index"`
+		RowLimit  int    `json:"row_limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
 
-	// Try to read headers
-	headers, err := reader.Read()
+	query, err := selectOnly(req.SQL)
 	if err != nil {
-		// Try with semicolon separator
-		file.Seek(0, 0)
-		reader = csv.NewReader(file)
-		reader.Comma = ';'
-		reader.FieldsPerRecord = -1
-		reader.LazyQuotes = true
-		reader.TrimLeadingSpace = true
-		headers, err = reader.Read()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read headers: %v", err)
-		}
-	}
-
-	// Clean headers
-	for i, h := range headers {
-		headers[i] = strings.TrimSpace(h)
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Read all rows
-	rows := [][]string{}
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			// Try to continue on malformed rows
-			continue
+	// file_index is optional; 0 means "don't store"
+	if req.FileIndex != 0 {
+		if err := service.ValidateFileIndex(req.FileIndex); err != nil {
+			h.httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
 		}
-		rows = append(rows, record)
 	}
 
-	return &state.DataFrame{
-		Headers:  headers,
-		Rows:     rows,
-		FilePath: filePath,
-	}, nil
-}
+	if req.RowLimit == 0 {
+		req.RowLimit = defaultAnalyzeRowLimit
+	}
+	if req.RowLimit < 0 || req.RowLimit > maxAnalyzeRowLimit {
+		h.httpError(w, r, fmt.Sprintf("row_limit must be between 1 and %d", maxAnalyzeRowLimit), http.StatusBadRequest)
+		return
+	}
 
-// ============================================================================
-// Status
-// ============================================================================
+	ctx, span := tracer.Start(r.Context(), "QueryDB")
+	defer span.End()
+	span.SetAttributes(attribute.Int("row_limit", req.RowLimit))
 
-func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
-	df1 := state.State.GetDataFrame(1)
-	df2 := state.State.GetDataFrame(2)
+	_, querySpan := tracer.Start(ctx, "DataSource.QueryRaw")
+	columns, data, err := db.QueryRaw(query, req.RowLimit)
+	querySpan.End()
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error running query: %v", err), http.StatusBadRequest, "err", err)
+		return
+	}
 
-	resp := models.StatusResponse{
-		File1Loaded: df1 != nil,
-		File2Loaded: df2 != nil,
-		File1: models.FileStatus{
-			Loaded: df1 != nil,
-		},
-		File2: models.FileStatus{
-			Loaded: df2 != nil,
-		},
+	if len(data) == 0 {
+		h.httpError(w, r, "Query returned no rows", http.StatusBadRequest)
+		return
+	}
+	if len(columns) == 0 {
+		columns = sortedKeys(data[0])
 	}
 
-	if df1 != nil {
-		resp.File1.Rows = len(df1.Rows)
-		resp.File1.Columns = len(df1.Headers)
-		resp.File1.Filename = df1.FileName
+	analysisResult, err := h.CSVService.AnalyzeDataContext(ctx, data, columns)
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error analyzing data: %v", err), http.StatusInternalServerError, "err", err)
+		return
 	}
-	if df2 != nil {
-		resp.File2.Rows = len(df2.Rows)
-		resp.File2.Columns = len(df2.Headers)
-		resp.File2.Filename = df2.FileName
+	analysisResult.FileName = "query"
+	h.recordAnalysis(estimateRowBytes(data))
+
+	if req.FileIndex != 0 {
+		h.storeAnalysis(h.store(r), req.FileIndex, &analysisResult)
 	}
+	h.audit(r, AuditEntry{Operation: AuditAnalyze, FileIndex: req.FileIndex, Detail: "query"})
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(analysisResult)
 }
 
-// ============================================================================
-// Preview
-// ============================================================================
-
-func (h *Handler) GetPreview(w http.ResponseWriter, r *http.Request) {
-	fileIndex := getIntParam(r, "file_index", 1)
-	rows := getIntParam(r, "rows", 10)
-
-	df := state.State.GetDataFrame(fileIndex)
-	if df == nil {
-		http.Error(w, fmt.Sprintf("File %d not loaded", fileIndex), http.StatusBadRequest)
-		return
+// selectOnly trims a raw query and accepts it only if it is a single SELECT
+// statement; a trailing semicolon is dropped. This only gives an early, clear
+// error: the data source's read-only transaction is what enforces it.
+func selectOnly(raw string) (string, error) {
+	query := strings.TrimSuffix(strings.TrimSpace(raw), ";")
+	if !strings.HasPrefix(strings.ToUpper(query), "SELECT") {
+		return "", fmt.Errorf("only SELECT queries are allowed")
 	}
-
-	// Convert rows to []map[string]interface{}
-	limit := rows
-	if limit > len(df.Rows) {
-		limit = len(df.Rows)
+	if strings.Contains(query, ";") {
+		return "", fmt.Errorf("only a single statement is allowed")
 	}
+	return query, nil
+}
 
-	data := make([]map[string]interface{}, limit)
-	for i := 0; i < limit; i++ {
-		row := make(map[string]interface{})
-		for j, header := range df.Headers {
-			if j < len(df.Rows[i]) {
-				row[header] = df.Rows[i][j]
-			} else {
-				row[header] = ""
-			}
+// GetAnalysisStatus returns the status of loaded files (My V2 impl)
+func (h *Handler) GetAnalysisStatus(w http.ResponseWriter, r *http.Request) {
+	store := h.store(r)
+	analysis1 := store.GetAnalysis(1)
+	analysis2 := store.GetAnalysis(2)
+
+	// Every stored analysis, keyed by file index
+	indices := store.AnalysisIndices()
+	files := make(map[string]interface{}, len(indices))
+	for _, idx := range indices {
+		stored, stale := store.Lookup(idx)
+		entry := map[string]interface{}{
+			"has_context": store.GetContext(idx) != nil,
+			"analysis":    stored,
 		}
-		data[i] = row
+		if stored != nil {
+			entry["schema_fingerprint"] = schemaFingerprint(stored)
+		}
+		if stale {
+			entry["stale"] = true
+		}
+		files[strconv.Itoa(idx)] = entry
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
-}
+	status := map[string]interface{}{
+		"loaded":        len(indices) > 0,
+		"file1_loaded":  analysis1 != nil,
+		"file2_loaded":  analysis2 != nil,
+		"file1_context": store.GetContext(1) != nil,
+		"file2_context": store.GetContext(2) != nil,
+		"file1":         analysis1,
+		"file2":         analysis2,
+		"file_indices":  indices,
+		"files":         files,
 
-// ============================================================================
-// Column Types
-// ============================================================================
+		"total_bytes_analyzed": h.TotalBytesAnalyzed.Load(),
+		"total_analyses_run":   h.TotalAnalysesRun.Load(),
+	}
 
-func (h *Handler) GetColumnTypes(w http.ResponseWriter, r *http.Request) {
-	fileIndex := getIntParam(r, "file_index", 1)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
 
-	df := state.State.GetDataFrame(fileIndex)
-	if df == nil {
-		http.Error(w, fmt.Sprintf("File %d not loaded", fileIndex), http.StatusBadRequest)
-		return
-	}
+// ListAnalyses returns every stored analysis keyed by file index
+func (h *Handler) ListAnalyses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"analyses": h.store(r).GetAllAnalyses(),
+	})
+}
 
-	types := make(map[string]string)
-	numericCols := df.GetNumericColumnIndices()
+// ListContexts returns every stored context keyed by file index
+func (h *Handler) ListContexts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"contexts": h.store(r).GetAllContexts(),
+	})
+}
 
-	for i, header := range df.Headers {
-		if numericCols[i] {
-			types[header] = "numeric"
-		} else if isDateColumn(df, i) {
-			types[header] = "datetime"
-		} else {
-			types[header] = "categorical"
-		}
+// GetAnalysisContextStatus returns context status (My V2 impl)
+func (h *Handler) GetAnalysisContextStatus(w http.ResponseWriter, r *http.Request) {
+	store := h.store(r)
+	// This structure matches Python backend likely
+	status := map[string]map[string]bool{
+		"file1": {"has_context": store.GetContext(1) != nil},
+		"file2": {"has_context": store.GetContext(2) != nil},
 	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(types)
+	json.NewEncoder(w).Encode(status)
 }
 
-func isDateColumn(df *state.DataFrame, colIdx int) bool {
-	dateFormats := []string{
-		time.RFC3339, "2006-01-02", "02/01/2006", "01/02/2006",
-		"2006/01/02", "Jan 2, 2006", "January 2, 2006",
+// AnalyzeFile handles file upload and analysis (My V2 impl). With
+// ?stream=true a multipart upload is analyzed without a temp file; see
+// analyzeFileStreaming.
+func (h *Handler) AnalyzeFile(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		h.analyzeS3File(w, r)
+		return
+	}
+	if r.URL.Query().Get("stream") == "true" && strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		h.analyzeFileStreaming(w, r)
+		return
+	}
+	if !h.parseUploadForm(w, r) {
+		return
 	}
 
-	checkRows := 5
-	if len(df.Rows) < checkRows {
-		checkRows = len(df.Rows)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		h.httpError(w, r, "Error retrieving file", http.StatusBadRequest)
+		return
 	}
+	defer file.Close()
+	uploadBytes.Observe(float64(header.Size))
 
-	for i := 0; i < checkRows; i++ {
-		if colIdx >= len(df.Rows[i]) {
-			continue
-		}
-		val := df.Rows[i][colIdx]
-		if val == "" {
-			continue
-		}
-		parsed := false
-		for _, format := range dateFormats {
-			if _, err := time.Parse(format, val); err == nil {
-				parsed = true
-				break
-			}
-		}
-		if parsed {
-			return true
-		}
+	params, err := parseUploadParams(r.FormValue)
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
-	return false
-}
 
-// ============================================================================
-// KPIs
-// ============================================================================
+	if r.URL.Query().Get("async") == "true" {
+		h.analyzeFileAsync(w, r, file, header, params.FileIndex, params.StoreResult, params.WebhookURL, params.Options)
+		return
+	}
 
-func (h *Handler) GetKPIs(w http.ResponseWriter, r *http.Request) {
-	fileIndex := getIntParam(r, "file_index", 1)
+	release, ok := h.acquireWorker(w, r)
+	if !ok {
+		return
+	}
+	defer release()
 
-	df := state.State.GetDataFrame(fileIndex)
-	if df == nil {
-		http.Error(w, fmt.Sprintf("File %d not loaded", fileIndex), http.StatusBadRequest)
+	benchmark := r.URL.Query().Get("benchmark") == "true"
+	var start time.Time
+	if benchmark {
+		start = time.Now()
+	}
+
+	analysisResult, err := h.analyzeUpload(r.Context(), file, header, params.Options)
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error analyzing file: %v", err), http.StatusInternalServerError, "err", err, "file", header.Filename)
 		return
 	}
 
-	kpis := []models.KPI{}
-	numericCols := df.GetNumericColumnIndices()
+	var bench *analysisBenchmark
+	if benchmark {
+		bench = newAnalysisBenchmark(analysisResult.NumRows, header.Size, time.Since(start))
+	}
+	h.finishUpload(w, r, analysisResult, header.Filename, params, bench)
+}
 
-	for colIdx, isNumeric := range numericCols {
-		if !isNumeric || colIdx >= len(df.Headers) {
-			continue
-		}
+// uploadParams are the form fields of an AnalyzeFile upload besides the file
+type uploadParams struct {
+	FileIndex   int
+	StoreResult bool   // A file index was given
+	WebhookURL  string // Optional URL to POST the result to once analysis completes
+	Options     analysis.CSVOptions
+}
 
-		colName := df.Headers[colIdx]
-		values := []float64{}
+// parseUploadParams reads and validates the AnalyzeFile form fields through
+// value, which returns "" for a missing field
+func parseUploadParams(value func(string) string) (uploadParams, error) {
+	var params uploadParams
 
-		for _, row := range df.Rows {
-			if colIdx >= len(row) {
-				continue
-			}
-			if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
-				values = append(values, val)
-			}
+	fileIndexStr := value("fileIndex")
+	if fileIndexStr == "" {
+		fileIndexStr = value("file_index")
+	}
+	if fileIndexStr != "" {
+		fileIndex, err := parseFileIndex(fileIndexStr)
+		if err != nil {
+			return params, err
 		}
+		params.FileIndex, params.StoreResult = fileIndex, true
+	}
 
-		if len(values) == 0 {
-			continue
+	params.WebhookURL = value("webhook_url")
+	if params.WebhookURL != "" {
+		if err := validateWebhookURL(params.WebhookURL); err != nil {
+			return params, err
 		}
+	}
 
-		sum := 0.0
-		for _, v := range values {
-			sum += v
-		}
+	// Optional row sampling for large files, and a delimiter for when
+	// detection picks the wrong one
+	var err error
+	if params.Options.Sampling, err = parseSampling(value("sampling"), value("sample_size")); err != nil {
+		return params, err
+	}
+	if params.Options.Delimiter, err = analysis.ParseDelimiter(value("delimiter")); err != nil {
+		return params, err
+	}
+	return params, nil
+}
 
-		kpis = append(kpis, models.KPI{
-			Name:  colName,
-			Value: sum,
-			Avg:   sum / float64(len(values)),
-			Type:  "sum",
-		})
+// finishUpload stores, audits and reports an analyzed upload and writes it
+// as the response, with the benchmark if there is one
+func (h *Handler) finishUpload(w http.ResponseWriter, r *http.Request, analysisResult models.DataAnalysisResult, fileName string, params uploadParams, bench *analysisBenchmark) {
+	if params.StoreResult {
+		h.storeAnalysis(h.store(r), params.FileIndex, &analysisResult)
 	}
+	h.audit(r, AuditEntry{Operation: AuditUpload, FileIndex: params.FileIndex, FileName: fileName})
+	h.notifyWebhook(params.WebhookURL, analysisResult)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(kpis)
+	if bench != nil {
+		json.NewEncoder(w).Encode(struct {
+			models.DataAnalysisResult
+			Benchmark *analysisBenchmark `json:"benchmark"`
+		}{analysisResult, bench})
+		return
+	}
+	json.NewEncoder(w).Encode(analysisResult)
 }
 
-// ============================================================================
-// Column Similarity
-// ============================================================================
+// analysisBenchmark reports how fast an upload was analyzed, for
+// ?benchmark=true
+type analysisBenchmark struct {
+	RowsPerSecond  float64 `json:"rows_per_second"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	ElapsedMs      int64   `json:"elapsed_ms"`
+}
 
-func (h *Handler) GetColumnSimilarity(w http.ResponseWriter, r *http.Request) {
-	df1 := state.State.GetDataFrame(1)
-	df2 := state.State.GetDataFrame(2)
+func newAnalysisBenchmark(rows int, size int64, elapsed time.Duration) *analysisBenchmark {
+	b := &analysisBenchmark{ElapsedMs: elapsed.Milliseconds()}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		b.RowsPerSecond = math.Round(float64(rows) / seconds)
+		b.BytesPerSecond = math.Round(float64(size) / seconds)
+	}
+	return b
+}
 
-	if df1 == nil || df2 == nil {
-		http.Error(w, "Both files must be loaded to calculate similarity", http.StatusBadRequest)
+// analyzeS3File handles the JSON form of AnalyzeFile,
+// {"s3_url":"s3://bucket/key","file_index":1,"webhook_url":"..."}: the object is downloaded to a
+// temp file and analyzed exactly like an upload
+func (h *Handler) analyzeS3File(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		S3URL      string `json:"s3_url"`
+		FileIndex  *int   `json:"file_index"`
+		WebhookURL string `json:"webhook_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-
-	ctx1 := state.State.GetContext(1)
-	ctx2 := state.State.GetContext(2)
-
-	// Build nodes for graph
-	nodes := []map[string]interface{}{}
-	for _, col := range df1.Headers {
-		nodes = append(nodes, map[string]interface{}{
-			"id":    fmt.Sprintf("file1_%s", col),
-			"label": col,
-			"group": "file1",
-		})
+	bucket, key, err := service.ParseS3URL(req.S3URL)
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
-	for _, col := range df2.Headers {
-		nodes = append(nodes, map[string]interface{}{
-			"id":    fmt.Sprintf("file2_%s", col),
-			"label": col,
-			"group": "file2",
-		})
+	if req.FileIndex != nil {
+		if err := service.ValidateFileIndex(*req.FileIndex); err != nil {
+			h.httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
-
-	// Check if AI matching is requested
-	useAI := r.URL.Query().Get("use_ai") == "true"
-
-	// Convert to response format
-	type SimilarityItem struct {
-		File1Column            string  `json:"file1_column"`
-		File2Column            string  `json:"file2_column"`
-		Similarity             float64 `json:"similarity"`
-		Confidence             float64 `json:"confidence"`
-		Type                   string  `json:"type"`
-		DataSimilarity         float64 `json:"data_similarity"`
-		NameSimilarity         float64 `json:"name_similarity"`
-		DistributionSimilarity float64 `json:"distribution_similarity"`
-		JSONConfidence         float64 `json:"json_confidence"`
-		LLMSemanticScore       float64 `json:"llm_semantic_score"`
-		Reason                 string  `json:"reason,omitempty"`
-		TokenSimilarity        float64 `json:"token_similarity,omitempty"`
-		SynonymMatch           bool    `json:"synonym_match,omitempty"`
-		PatternMatch           string  `json:"pattern_match,omitempty"`
-		ValueOverlap           float64 `json:"value_overlap,omitempty"`
-		AIExplanation          string  `json:"ai_explanation,omitempty"`
+	if req.WebhookURL != "" {
+		if err := validateWebhookURL(req.WebhookURL); err != nil {
+			h.httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	similarities := []SimilarityItem{}
+	header := &multipart.FileHeader{Filename: path.Base(key)}
+	tempFile, err := os.CreateTemp("", "upload-*-"+header.Filename)
+	if err != nil {
+		h.httpError(w, r, "Error saving file", http.StatusInternalServerError, "err", err)
+		return
+	}
+	defer os.Remove(tempFile.Name()) // Clean up
 
-	if useAI && h.AISemanticMatcher != nil {
-		// Use AI-powered matching
-		log.Println("[API] Using AI-powered semantic matching via Ollama")
-		aiResults := h.AISemanticMatcher.MatchColumns(df1, df2, ctx1, ctx2)
-		for _, r := range aiResults {
-			similarities = append(similarities, SimilarityItem{
-				File1Column:            r.File1Column,
-				File2Column:            r.File2Column,
-				Similarity:             r.Confidence / 100,
-				Confidence:             r.Confidence,
-				Type:                   r.MatchType,
-				DataSimilarity:         r.DataSimilarity,
-				NameSimilarity:         r.NameSimilarity,
-				DistributionSimilarity: r.DistributionSimilarity,
-				LLMSemanticScore:       r.SemanticScore,
-				Reason:                 r.Reason,
-				ValueOverlap:           r.ValueOverlap,
-				AIExplanation:          r.AIExplanation,
-			})
-		}
-	} else {
-		// Use Enhanced heuristic matching (default)
-		enhancedResults := h.EnhancedSimilarityService.CalculateEnhancedSimilarity(df1, df2, ctx1, ctx2)
-		for _, r := range enhancedResults {
-			similarities = append(similarities, SimilarityItem{
-				File1Column:            r.File1Column,
-				File2Column:            r.File2Column,
-				Similarity:             r.Similarity,
-				Confidence:             r.Confidence,
-				Type:                   r.Type,
-				DataSimilarity:         r.DataSimilarity,
-				NameSimilarity:         r.NameSimilarity,
-				DistributionSimilarity: r.DistributionSimilarity,
-				JSONConfidence:         r.JSONConfidence,
-				LLMSemanticScore:       r.LLMSemanticScore,
-				Reason:                 r.Reason,
-				TokenSimilarity:        r.TokenSimilarity,
-				SynonymMatch:           r.SynonymMatch,
-				PatternMatch:           r.PatternMatch,
-				ValueOverlap:           r.ValueOverlap,
-			})
+	err = service.DownloadS3Object(r.Context(), bucket, key, tempFile, h.MaxUploadBytes)
+	tempFile.Close()
+	if err != nil {
+		if errors.Is(err, service.ErrObjectTooLarge) {
+			h.httpError(w, r, fmt.Sprintf("Object exceeds the %d MB limit", h.MaxUploadBytes>>20), http.StatusRequestEntityTooLarge)
+			return
 		}
+		h.httpError(w, r, fmt.Sprintf("Error downloading file: %v", err), http.StatusBadGateway, "err", err)
+		return
+	}
+	if info, err := os.Stat(tempFile.Name()); err == nil {
+		uploadBytes.Observe(float64(info.Size()))
 	}
 
-	totalRelationships := len(similarities)
+	release, ok := h.acquireWorker(w, r)
+	if !ok {
+		return
+	}
+	defer release()
 
-	// Limit to top 15 for display
-	if len(similarities) > 15 {
-		similarities = similarities[:15]
+	analysisResult, err := h.analyzeSavedFile(r.Context(), tempFile.Name(), header, analysis.CSVOptions{})
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error analyzing file: %v", err), http.StatusInternalServerError, "err", err, "file", req.S3URL)
+		return
 	}
 
-	// Build edges from top similarities
-	edges := []map[string]interface{}{}
-	for _, sim := range similarities {
-		edges = append(edges, map[string]interface{}{
-			"source":     fmt.Sprintf("file1_%s", sim.File1Column),
-			"target":     fmt.Sprintf("file2_%s", sim.File2Column),
-			"value":      sim.Confidence,
-			"similarity": sim.Similarity,
-			"type":       sim.Type,
-			"label":      fmt.Sprintf("%d%%", int(sim.Confidence)),
-		})
+	entry := AuditEntry{Operation: AuditUpload, FileName: req.S3URL}
+	if req.FileIndex != nil {
+		h.storeAnalysis(h.store(r), *req.FileIndex, &analysisResult)
+		entry.FileIndex = *req.FileIndex
 	}
+	h.audit(r, entry)
+	h.notifyWebhook(req.WebhookURL, analysisResult)
 
-	// Calculate correlations for ALL numeric column pairs (not just name-matched)
-	type CorrelationItem struct {
-		File1Column         string  `json:"file1_column"`
-		File2Column         string  `json:"file2_column"`
-		PearsonCorrelation  float64 `json:"pearson_correlation"`
-		SpearmanCorrelation float64 `json:"spearman_correlation"`
-		Strength            string  `json:"strength"`
-		SampleSize          int     `json:"sample_size"`
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analysisResult)
+}
+
+// analyzeFileAsync saves the upload, queues its analysis as a background job
+// and responds with the job ID straight away
+func (h *Handler) analyzeFileAsync(w http.ResponseWriter, r *http.Request, file io.Reader, header *multipart.FileHeader, fileIndex int, storeResult bool, webhookURL string, opts analysis.CSVOptions) {
+	// The request body is gone once we return, so keep a copy on disk for the job
+	tempFilePath, err := saveTempUpload(file, header.Filename)
+	if err != nil {
+		h.httpError(w, r, "Error saving file", http.StatusInternalServerError)
+		return
 	}
 
-	correlations := []CorrelationItem{}
-	numericCols1 := df1.GetNumericColumnIndices()
-	numericCols2 := df2.GetNumericColumnIndices()
+	store := h.store(r)
+	jobID := h.JobStore.Create(GetNamespace(r.Context()))
+	h.audit(r, AuditEntry{Operation: AuditUpload, FileIndex: fileIndex, FileName: header.Filename, Detail: "job " + jobID})
+	h.jobs.Add(1)
+	go func() {
+		defer h.jobs.Done()
+		defer os.Remove(tempFilePath) // Clean up
 
-	// Calculate correlations for ALL numeric column pairs
-	for col1Idx, isNumeric1 := range numericCols1 {
-		if !isNumeric1 || col1Idx >= len(df1.Headers) {
-			continue
+		// Stay queued until a worker is free
+		release, err := h.waitForWorker(h.ctx)
+		if err != nil {
+			h.JobStore.Fail(jobID, err)
+			return
 		}
-		col1Name := df1.Headers[col1Idx]
+		defer release()
 
-		for col2Idx, isNumeric2 := range numericCols2 {
-			if !isNumeric2 || col2Idx >= len(df2.Headers) {
-				continue
+		h.JobStore.SetRunning(jobID)
+
+		// Relay progress to the job store, where /jobs/{id}/stream picks it up
+		progress := make(chan analysis.ProgressEvent)
+		relayed := make(chan struct{})
+		go func() {
+			defer close(relayed)
+			for event := range progress {
+				h.JobStore.SetProgress(jobID, event.Percent, event.Stage)
 			}
-			col2Name := df2.Headers[col2Idx]
+		}()
+		opts.Progress = progress
 
-			// Get values
-			vals1 := getNumericValues(df1, col1Idx)
-			vals2 := getNumericValues(df2, col2Idx)
+		// The job outlives the request, so it is tied to the handler instead
+		analysisResult, err := h.analyzeSavedFile(h.ctx, tempFilePath, header, opts)
+		close(progress)
+		<-relayed
+		if err != nil {
+			h.Logger.Error("analysis job failed", "err", err, "job_id", jobID, "file", header.Filename)
+			h.JobStore.Fail(jobID, err)
+			return
+		}
+		if storeResult {
+			h.storeAnalysis(store, fileIndex, &analysisResult)
+		}
+		h.JobStore.Complete(jobID, analysisResult)
+		if webhookURL != "" {
+			h.deliverWebhook(webhookURL, analysisResult)
+		}
+	}()
 
-			if len(vals1) == 0 || len(vals2) == 0 {
-				continue
-			}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"job_id": jobID,
+		"status": string(service.JobQueued),
+	})
+}
 
-			// Use minimum length
-			minLen := len(vals1)
-			if len(vals2) < minLen {
-				minLen = len(vals2)
-			}
-			if minLen < 2 {
-				continue
-			}
+// GetJob returns the status of a background job
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.JobStore.Get(GetNamespace(r.Context()), chi.URLParam(r, "id"))
+	if !ok {
+		h.httpError(w, r, "Job not found", http.StatusNotFound)
+		return
+	}
 
-			vals1 = vals1[:minLen]
-			vals2 = vals2[:minLen]
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
 
-			pearson := pearsonCorrelation(vals1, vals2)
-			spearman := spearmanCorrelation(vals1, vals2)
+// StreamJob reports a background job's progress as server-sent events. Each
+// update is sent as data: {"percent":N,"stage":"..."}; the stream ends after
+// the "done" event, or an "error" event if the job failed.
+func (h *Handler) StreamJob(w http.ResponseWriter, r *http.Request) {
+	current, updates, cancel, ok := h.JobStore.Subscribe(GetNamespace(r.Context()), chi.URLParam(r, "id"))
+	if !ok {
+		h.httpError(w, r, "Job not found", http.StatusNotFound)
+		return
+	}
+	defer cancel()
 
-			// Skip if correlation is very weak (less than 0.1)
-			if math.Abs(pearson) < 0.1 {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.httpError(w, r, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	last := analysis.ProgressEvent{Percent: -1}
+	send := func(job service.Job) {
+		event := analysis.ProgressEvent{Percent: job.Percent, Stage: job.Stage}
+		if event.Stage == "" || event == last {
+			return
+		}
+		last = event
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	send(current)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case job, open := <-updates:
+			if open {
+				send(job)
 				continue
 			}
-
-			// Determine strength
-			absPearson := math.Abs(pearson)
-			strength := "None"
-			if absPearson >= 0.7 {
-				strength = "Strong"
-			} else if absPearson >= 0.4 {
-				strength = "Moderate"
-			} else if absPearson >= 0.2 {
-				strength = "Weak"
+			// Finished; updates may have been dropped, so report the final state
+			final, _ := h.JobStore.Get(current.Namespace, current.ID)
+			if final.Status == service.JobFailed {
+				data, _ := json.Marshal(map[string]string{"error": final.Error})
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			} else {
+				send(final)
 			}
-
-			correlations = append(correlations, CorrelationItem{
-				File1Column:         col1Name,
-				File2Column:         col2Name,
-				PearsonCorrelation:  pearson,
-				SpearmanCorrelation: spearman,
-				Strength:            strength,
-				SampleSize:          minLen,
-			})
+			flusher.Flush()
+			return
 		}
 	}
+}
+
+// analyzeUpload saves an uploaded file to disk and analyzes it according to its format
+func (h *Handler) analyzeUpload(ctx context.Context, file io.Reader, header *multipart.FileHeader, opts analysis.CSVOptions) (models.DataAnalysisResult, error) {
+	tempFilePath, err := saveTempUpload(file, header.Filename)
+	if err != nil {
+		return models.DataAnalysisResult{}, fmt.Errorf("saving file: %w", err)
+	}
+	defer os.Remove(tempFilePath) // Clean up
 
-	// Sort correlations by absolute Pearson value
-	sort.Slice(correlations, func(i, j int) bool {
-		return math.Abs(correlations[i].PearsonCorrelation) > math.Abs(correlations[j].PearsonCorrelation)
-	})
+	return h.analyzeSavedFile(ctx, tempFilePath, header, opts)
+}
 
-	// Limit to top 20 correlations
-	if len(correlations) > 20 {
-		correlations = correlations[:20]
+// maxDecompressionRatio bounds how far a gzip upload may expand relative to
+// MaxUploadBytes
+const maxDecompressionRatio = 10
+
+// maxDecompressedBytes is the cap on a gzip upload's decompressed size
+func (h *Handler) maxDecompressedBytes() int64 {
+	return h.MaxUploadBytes * maxDecompressionRatio
+}
+
+// analyzeSavedFile analyzes an upload already written to disk
+func (h *Handler) analyzeSavedFile(ctx context.Context, tempFilePath string, header *multipart.FileHeader, opts analysis.CSVOptions) (models.DataAnalysisResult, error) {
+	ctx, span := tracer.Start(ctx, "analyzeUpload")
+	defer span.End()
+	span.SetAttributes(attribute.String("file.name", header.Filename))
+
+	// Detection would usually find the tabs too, but the name is more reliable
+	if opts.Delimiter == 0 {
+		opts.Delimiter = analysis.DelimiterForFilename(header.Filename)
+	}
+	opts.MaxDecompressedBytes = h.maxDecompressedBytes()
+
+	var (
+		analysisResult models.DataAnalysisResult
+		err            error
+	)
+	switch {
+	case isXLSXUpload(header):
+		analysisResult, err = h.CSVService.AnalyzeXLSX(tempFilePath, opts.Sampling)
+	case analysis.IsNDJSONFile(tempFilePath):
+		analysisResult, err = h.CSVService.AnalyzeNDJSON(tempFilePath, opts.Sampling)
+	default:
+		analysisResult, err = h.CSVService.AnalyzeFileContext(ctx, tempFilePath, opts)
+	}
+	if err != nil {
+		return models.DataAnalysisResult{}, err
 	}
+	analysisResult.FileName = header.Filename
 
-	// Return response in Python backend format
-	resp := map[string]interface{}{
-		"nodes":               nodes,
-		"edges":               edges,
-		"similarities":        similarities,
-		"total_relationships": totalRelationships,
-		"correlations":        correlations,
+	if info, err := os.Stat(tempFilePath); err == nil {
+		h.recordAnalysis(info.Size())
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	return analysisResult, nil
 }
 
-func getColumnIndex(headers []string, col string) int {
-	for i, h := range headers {
-		if h == col {
-			return i
+// AnalyzeFiles analyzes several uploaded files concurrently. Each "file" part
+// may be paired with a "file_index[]" value at the same position to store the
+// result; failures are reported per file without aborting the batch. Each
+// file takes its own slot of the analysis worker pool.
+func (h *Handler) AnalyzeFiles(w http.ResponseWriter, r *http.Request) {
+	if !h.parseUploadForm(w, r) {
+		return
+	}
+
+	headers := r.MultipartForm.File["file"]
+	if len(headers) == 0 {
+		h.httpError(w, r, "No files provided", http.StatusBadRequest)
+		return
+	}
+	fileIndices := make([]int, 0, len(r.MultipartForm.Value["file_index[]"]))
+	for _, raw := range r.MultipartForm.Value["file_index[]"] {
+		fileIndex, err := parseFileIndex(raw)
+		if err != nil {
+			h.httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
 		}
+		fileIndices = append(fileIndices, fileIndex)
 	}
-	return -1
-}
 
-func getPatternScore(col1, col2 string) float64 {
-	patterns := map[string][]string{
-		"email":   {"email", "e-mail", "mail"},
-		"id":      {"id", "identifier", "key", "code", "number"},
-		"date":    {"date", "time", "year", "month", "day", "created", "updated", "timestamp"},
-		"name":    {"name", "first", "last", "full", "surname"},
-		"phone":   {"phone", "mobile", "cell", "contact", "tel"},
-		"address": {"address", "city", "state", "zip", "postal", "country", "location"},
-		"price":   {"price", "cost", "amount", "value", "total", "revenue", "fee"},
-		"status":  {"status", "state", "condition", "flag"},
+	workers := h.MaxWorkers
+	if workers <= 0 {
+		workers = 1
 	}
 
-	c1Lower := strings.ToLower(col1)
-	c2Lower := strings.ToLower(col2)
+	results := make([]interface{}, len(headers))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
 
-	for _, keywords := range patterns {
-		match1, match2 := false, false
-		for _, kw := range keywords {
-			if strings.Contains(c1Lower, kw) {
-				match1 = true
+	for i, header := range headers {
+		wg.Add(1)
+		go func(i int, header *multipart.FileHeader) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// Each file takes a slot of the shared pool, like a single upload
+			release, err := h.tryWorker(r.Context())
+			if err != nil {
+				results[i] = map[string]string{"error": fmt.Sprintf("Error analyzing file: %v", err)}
+				return
 			}
-			if strings.Contains(c2Lower, kw) {
-				match2 = true
+			defer release()
+
+			file, err := header.Open()
+			if err != nil {
+				results[i] = map[string]string{"error": fmt.Sprintf("Error opening file: %v", err)}
+				return
+			}
+			defer file.Close()
+			uploadBytes.Observe(float64(header.Size))
+
+			analysisResult, err := h.analyzeUpload(r.Context(), file, header, analysis.CSVOptions{})
+			if err != nil {
+				results[i] = map[string]string{"error": fmt.Sprintf("Error analyzing file: %v", err)}
+				return
+			}
+
+			entry := AuditEntry{Operation: AuditUpload, FileName: header.Filename}
+			if i < len(fileIndices) {
+				h.storeAnalysis(h.store(r), fileIndices[i], &analysisResult)
+				entry.FileIndex = fileIndices[i]
 			}
+			h.audit(r, entry)
+			results[i] = analysisResult
+		}(i, header)
+	}
+	wg.Wait()
+
+	// Key results by filename, disambiguating repeated names
+	byName := make(map[string]interface{}, len(headers))
+	for i, header := range headers {
+		name := header.Filename
+		for n := 2; byName[name] != nil; n++ {
+			name = fmt.Sprintf("%s (%d)", header.Filename, n)
 		}
-		if match1 && match2 {
-			return 0.9
-		}
+		byName[name] = results[i]
 	}
-	return 0.0
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": byName})
 }
 
-func estimateDataSimilarity(df1, df2 *state.DataFrame, col1Idx, col2Idx int) float64 {
-	// Quick heuristic: compare types and sample values
-	if len(df1.Rows) == 0 || len(df2.Rows) == 0 {
-		return 0.0
+// AnalyzeFileStream analyzes an uploaded delimited file and streams the
+// per-column results back as a JSON array, flushing after each column. The
+// file part is read straight from the request rather than buffered first.
+func (h *Handler) AnalyzeFileStream(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		h.httpError(w, r, "Error parsing form", http.StatusBadRequest, "err", err)
+		return
 	}
 
-	// Check if both are numeric
-	num1 := df1.GetNumericColumnIndices()
-	num2 := df2.GetNumericColumnIndices()
-
-	isNum1 := num1[col1Idx]
-	isNum2 := num2[col2Idx]
-
-	if isNum1 && isNum2 {
-		// Both numeric - assume some similarity
-		return 0.4
-	} else if !isNum1 && !isNum2 {
-		// Both string - check Jaccard of unique values
-		set1 := make(map[string]bool)
-		set2 := make(map[string]bool)
-
-		limit := 100
-		if len(df1.Rows) < limit {
-			limit = len(df1.Rows)
+	var part *multipart.Part
+	for {
+		part, err = mr.NextPart()
+		if err == io.EOF {
+			h.httpError(w, r, "Error retrieving file", http.StatusBadRequest)
+			return
 		}
-		for i := 0; i < limit; i++ {
-			if col1Idx < len(df1.Rows[i]) {
-				set1[df1.Rows[i][col1Idx]] = true
-			}
+		if err != nil {
+			h.streamUploadError(w, r, err)
+			return
 		}
+		if part.FormName() == "file" {
+			break
+		}
+	}
+	header := &multipart.FileHeader{Filename: part.FileName(), Header: part.Header}
 
-		limit = 100
-		if len(df2.Rows) < limit {
-			limit = len(df2.Rows)
+	opts := analysis.CSVOptions{
+		Delimiter:            analysis.DelimiterForFilename(header.Filename),
+		MaxDecompressedBytes: h.maxDecompressedBytes(),
+	}
+	body := &countingReader{r: part}
+
+	release, ok := h.acquireWorker(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	columns := make(chan models.ColumnAnalysis)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.CSVService.AnalyzeStream(body, opts, columns)
+	}()
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	started := false
+
+	for col := range columns {
+		if !started {
+			// Headers are only sent once the first column is ready so that
+			// parse failures can still be reported with a proper status code
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Transfer-Encoding", "chunked")
+			w.Write([]byte("["))
+			started = true
+		} else {
+			w.Write([]byte(","))
 		}
-		for i := 0; i < limit; i++ {
-			if col2Idx < len(df2.Rows[i]) {
-				set2[df2.Rows[i][col2Idx]] = true
-			}
+		enc.Encode(col)
+		if flusher != nil {
+			flusher.Flush()
 		}
+	}
 
-		// Calculate Jaccard
-		intersection := 0
-		for k := range set1 {
-			if set2[k] {
-				intersection++
+	if err := <-errCh; err != nil {
+		if !started {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				h.streamUploadError(w, r, err)
+				return
 			}
+			h.httpError(w, r, fmt.Sprintf("Error analyzing file: %v", err), http.StatusInternalServerError, "err", err, "file", header.Filename)
+			return
 		}
+		h.Logger.Error("streaming analysis failed", "err", err, "file", header.Filename, "request_id", GetRequestID(r.Context()))
+		return
+	}
+	h.recordAnalysis(body.n)
+	h.audit(r, AuditEntry{Operation: AuditUpload, FileName: header.Filename, Detail: "stream"})
 
-		union := len(set1) + len(set2) - intersection
-		if union > 0 {
-			return float64(intersection) / float64(union)
+	if !started {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("["))
+	}
+	w.Write([]byte("]"))
+}
+
+// parseUploadForm caps the request body at MaxUploadBytes and parses the
+// multipart form, writing a 413 or 400 response on failure
+func (h *Handler) parseUploadForm(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)
+
+	if err := r.ParseMultipartForm(h.MaxUploadBytes); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			h.httpError(w, r, fmt.Sprintf("Upload exceeds the %d MB limit", h.MaxUploadBytes>>20), http.StatusRequestEntityTooLarge)
+			return false
 		}
+		h.httpError(w, r, "Error parsing form", http.StatusBadRequest, "err", err)
+		return false
 	}
+	return true
+}
 
-	return 0.0
+// isXLSXUpload reports whether an uploaded file is an Excel workbook
+func isXLSXUpload(header *multipart.FileHeader) bool {
+	if header.Header.Get("Content-Type") == "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" {
+		return true
+	}
+	return strings.EqualFold(filepath.Ext(header.Filename), ".xlsx")
 }
 
-// ============================================================================
-// Correlation
-// ============================================================================
+// saveTempUpload copies an uploaded file into a temp file, keeping the original
+// extension, and returns its path. The caller is responsible for removing it.
+func saveTempUpload(src io.Reader, filename string) (string, error) {
+	tempFile, err := os.CreateTemp("", "upload-*-"+filepath.Base(filename))
+	if err != nil {
+		return "", err
+	}
+	defer tempFile.Close()
 
-func (h *Handler) GetCorrelation(w http.ResponseWriter, r *http.Request) {
-	col1 := r.URL.Query().Get("col1")
-	col2 := r.URL.Query().Get("col2")
+	if _, err := io.Copy(tempFile, src); err != nil {
+		os.Remove(tempFile.Name())
+		return "", err
+	}
+	return tempFile.Name(), nil
+}
 
-	// If no params provided, return correlations for all matched column pairs
-	if col1 == "" && col2 == "" {
-		h.GetAllCorrelations(w, r)
+func (h *Handler) Upload(w http.ResponseWriter, r *http.Request) {
+	// Parse multipart form (max 100MB)
+	if err := r.ParseMultipartForm(MaxFileSize); err != nil {
+		h.httpError(w, r, "File too large", http.StatusBadRequest)
 		return
 	}
 
-	fileIndex := getIntParam(r, "file_index", 1)
-
-	df := state.State.GetDataFrame(fileIndex)
-	if df == nil {
-		http.Error(w, fmt.Sprintf("File %d not loaded", fileIndex), http.StatusBadRequest)
+	// Get file_index parameter
+	fileIndexStr := r.FormValue("file_index")
+	if fileIndexStr == "" {
+		fileIndexStr = "1"
+	}
+	fileIndex, err := strconv.Atoi(fileIndexStr)
+	if err != nil || (fileIndex != 1 && fileIndex != 2) {
+		h.httpError(w, r, "file_index must be 1 or 2", http.StatusBadRequest)
 		return
 	}
 
-	// Find column indices
-	col1Idx, col2Idx := -1, -1
-	for i, h := range df.Headers {
-		if h == col1 {
-			col1Idx = i
-		}
-		if h == col2 {
-			col2Idx = i
-		}
+	// Get file from form
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		h.httpError(w, r, "No file uploaded", http.StatusBadRequest)
+		return
 	}
+	defer file.Close()
 
-	if col1Idx == -1 || col2Idx == -1 {
-		http.Error(w, "Column not found", http.StatusNotFound)
+	// Validate file extension
+	if !strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+		h.httpError(w, r, "Only CSV files are allowed", http.StatusBadRequest)
 		return
 	}
 
-	// Calculate correlation
-	vals1, vals2 := []float64{}, []float64{}
-	for _, row := range df.Rows {
-		if col1Idx >= len(row) || col2Idx >= len(row) {
-			continue
-		}
-		v1, err1 := strconv.ParseFloat(row[col1Idx], 64)
-		v2, err2 := strconv.ParseFloat(row[col2Idx], 64)
-		if err1 == nil && err2 == nil {
-			vals1 = append(vals1, v1)
-			vals2 = append(vals2, v2)
-		}
+	// Create upload directory; each namespace gets its own, so tenants
+	// uploading the same file name cannot overwrite each other
+	uploadDir := filepath.Join(UploadDir, GetNamespace(r.Context()))
+	os.MkdirAll(uploadDir, 0755)
+
+	// Save file
+	filename := fmt.Sprintf("file%d_%s", fileIndex, filepath.Base(header.Filename))
+	filePath := filepath.Join(uploadDir, filename)
+
+	dst, err := os.Create(filePath)
+	if err != nil {
+		h.httpError(w, r, "Failed to save file", http.StatusInternalServerError)
+		return
 	}
+	defer dst.Close()
 
-	if len(vals1) < 2 {
-		http.Error(w, "Not enough numeric values for correlation", http.StatusBadRequest)
+	if _, err := io.Copy(dst, file); err != nil {
+		h.httpError(w, r, "Failed to save file", http.StatusInternalServerError)
 		return
 	}
 
-	corr := pearsonCorrelation(vals1, vals2)
-	interpretation := "Weak/None"
-	if corr > 0.7 {
-		interpretation = "Strong positive"
-	} else if corr < -0.7 {
-		interpretation = "Strong negative"
-	} else if corr > 0.3 {
-		interpretation = "Moderate positive"
-	} else if corr < -0.3 {
-		interpretation = "Moderate negative"
+	// Parse CSV
+	df, err := parseCSVFile(filePath)
+	if err != nil {
+		os.Remove(filePath)
+		h.httpError(w, r, fmt.Sprintf("Failed to parse CSV: %v", err), http.StatusBadRequest)
+		return
 	}
+	df.FileName = header.Filename
+	df.FilePath = filePath
 
-	resp := models.CorrelationResult{
-		Column1:        col1,
-		Column2:        col2,
-		Correlation:    corr,
-		Interpretation: interpretation,
+	// Store in state
+	h.legacyState(r).SetDataFrame(fileIndex, df)
+	h.audit(r, AuditEntry{Operation: AuditUpload, FileIndex: fileIndex, FileName: header.Filename})
+
+	// Return response
+	resp := models.UploadResponse{
+		Message:     fmt.Sprintf("File '%s' uploaded successfully", header.Filename),
+		Rows:        len(df.Rows),
+		Columns:     len(df.Headers),
+		ColumnNames: df.Headers,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-// GetAllCorrelations returns correlations for all numeric column pairs between two files
-func (h *Handler) GetAllCorrelations(w http.ResponseWriter, r *http.Request) {
-	df1 := state.State.GetDataFrame(1)
-	df2 := state.State.GetDataFrame(2)
-
-	if df1 == nil || df2 == nil {
-		http.Error(w, "Both files must be loaded to calculate correlations", http.StatusBadRequest)
-		return
+func parseCSVFile(filePath string) (*state.DataFrame, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	// Get numeric columns from both files
-	numericCols1 := df1.GetNumericColumnIndices()
-	numericCols2 := df2.GetNumericColumnIndices()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // Allow variable fields
+	reader.LazyQuotes = true    // Allow bare quotes in non-quoted fields
+	reader.TrimLeadingSpace = true
 
-	type CorrelationItem struct {
-		File1Column         string  `json:"file1_column"`
-		File2Column         string  `json:"file2_column"`
-		Correlation         float64 `json:"correlation"`
-		PearsonCorrelation  float64 `json:"pearson_correlation"`
-		SpearmanCorrelation float64 `json:"spearman_correlation"`
-		Strength            string  `json:"strength"`
-		SampleSize          int     `json:"sample_size"`
-		File1Rows           int     `json:"file1_rows"`
-		File2Rows           int     `json:"file2_rows"`
+	// Try to read headers
+	headers, err := reader.Read()
+	if err != nil {
+		// Try with semicolon separator
+		file.Seek(0, 0)
+		reader = csv.NewReader(file)
+		reader.Comma = ';'
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		reader.TrimLeadingSpace = true
+		headers, err = reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read headers: %v", err)
+		}
 	}
 
-	correlations := []CorrelationItem{}
+	// Clean headers
+	for i, h := range headers {
+		headers[i] = strings.TrimSpace(h)
+	}
 
-	// Calculate correlations for matching numeric columns
-	for col1Idx := range numericCols1 {
-		if col1Idx >= len(df1.Headers) {
+	// Read all rows
+	rows := [][]string{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Try to continue on malformed rows
 			continue
 		}
-		col1Name := df1.Headers[col1Idx]
-
-		for col2Idx := range numericCols2 {
-			if col2Idx >= len(df2.Headers) {
-				continue
-			}
-			col2Name := df2.Headers[col2Idx]
+		rows = append(rows, record)
+	}
 
-			// Get values
-			vals1 := getNumericValues(df1, col1Idx)
-			vals2 := getNumericValues(df2, col2Idx)
+	return &state.DataFrame{
+		Headers:  headers,
+		Rows:     rows,
+		FilePath: filePath,
+	}, nil
+}
 
-			if len(vals1) == 0 || len(vals2) == 0 {
-				continue
-			}
+// ============================================================================
+// Status
+// ============================================================================
 
-			// Use minimum length
-			minLen := len(vals1)
-			if len(vals2) < minLen {
-				minLen = len(vals2)
-			}
-			if minLen < 2 {
-				continue
-			}
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	df1 := h.legacyState(r).GetDataFrame(1)
+	df2 := h.legacyState(r).GetDataFrame(2)
+
+	resp := models.StatusResponse{
+		File1Loaded: df1 != nil,
+		File2Loaded: df2 != nil,
+		File1: models.FileStatus{
+			Loaded: df1 != nil,
+		},
+		File2: models.FileStatus{
+			Loaded: df2 != nil,
+		},
+	}
+
+	if df1 != nil {
+		resp.File1.Rows = len(df1.Rows)
+		resp.File1.Columns = len(df1.Headers)
+		resp.File1.Filename = df1.FileName
+	}
+	if df2 != nil {
+		resp.File2.Rows = len(df2.Rows)
+		resp.File2.Columns = len(df2.Headers)
+		resp.File2.Filename = df2.FileName
+	}
+	_, resp.File1.Stale = h.store(r).Lookup(1)
+	_, resp.File2.Stale = h.store(r).Lookup(2)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ============================================================================
+// Preview
+// ============================================================================
+
+func (h *Handler) GetPreview(w http.ResponseWriter, r *http.Request) {
+	fileIndex := getIntParam(r, "file_index", 1)
+	rows := getIntParam(r, "rows", 10)
+
+	df := h.legacyState(r).GetDataFrame(fileIndex)
+	if df == nil {
+		h.httpError(w, r, fmt.Sprintf("File %d not loaded", fileIndex), http.StatusBadRequest)
+		return
+	}
+
+	// Convert rows to []map[string]interface{}
+	limit := rows
+	if limit > len(df.Rows) {
+		limit = len(df.Rows)
+	}
+
+	data := make([]map[string]interface{}, limit)
+	for i := 0; i < limit; i++ {
+		row := make(map[string]interface{})
+		for j, header := range df.Headers {
+			if j < len(df.Rows[i]) {
+				row[header] = df.Rows[i][j]
+			} else {
+				row[header] = ""
+			}
+		}
+		data[i] = row
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// ============================================================================
+// Column Types
+// ============================================================================
+
+func (h *Handler) GetColumnTypes(w http.ResponseWriter, r *http.Request) {
+	fileIndex := getIntParam(r, "file_index", 1)
+
+	df := h.legacyState(r).GetDataFrame(fileIndex)
+	if df == nil {
+		h.httpError(w, r, fmt.Sprintf("File %d not loaded", fileIndex), http.StatusBadRequest)
+		return
+	}
+
+	types := make(map[string]string)
+	numericCols := df.GetNumericColumnIndices()
+
+	for i, header := range df.Headers {
+		if numericCols[i] {
+			types[header] = "numeric"
+		} else if isDateColumn(df, i) {
+			types[header] = "datetime"
+		} else {
+			types[header] = "categorical"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types)
+}
+
+func isDateColumn(df *state.DataFrame, colIdx int) bool {
+	dateFormats := []string{
+		time.RFC3339, "2006-01-02", "02/01/2006", "01/02/2006",
+		"2006/01/02", "Jan 2, 2006", "January 2, 2006",
+	}
+
+	checkRows := 5
+	if len(df.Rows) < checkRows {
+		checkRows = len(df.Rows)
+	}
+
+	for i := 0; i < checkRows; i++ {
+		if colIdx >= len(df.Rows[i]) {
+			continue
+		}
+		val := df.Rows[i][colIdx]
+		if val == "" {
+			continue
+		}
+		parsed := false
+		for _, format := range dateFormats {
+			if _, err := time.Parse(format, val); err == nil {
+				parsed = true
+				break
+			}
+		}
+		if parsed {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================================
+// KPIs
+// ============================================================================
+
+func (h *Handler) GetKPIs(w http.ResponseWriter, r *http.Request) {
+	fileIndex := getIntParam(r, "file_index", 1)
+
+	df := h.legacyState(r).GetDataFrame(fileIndex)
+	if df == nil {
+		h.httpError(w, r, fmt.Sprintf("File %d not loaded", fileIndex), http.StatusBadRequest)
+		return
+	}
+
+	kpis := []models.KPI{}
+	numericCols := df.GetNumericColumnIndices()
+
+	for colIdx, isNumeric := range numericCols {
+		if !isNumeric || colIdx >= len(df.Headers) {
+			continue
+		}
+
+		colName := df.Headers[colIdx]
+		values := []float64{}
+
+		for _, row := range df.Rows {
+			if colIdx >= len(row) {
+				continue
+			}
+			if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
+				values = append(values, val)
+			}
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+
+		kpis = append(kpis, models.KPI{
+			Name:  colName,
+			Value: sum,
+			Avg:   sum / float64(len(values)),
+			Type:  "sum",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(kpis)
+}
+
+// ============================================================================
+// Column Similarity
+// ============================================================================
+
+func (h *Handler) GetColumnSimilarity(w http.ResponseWriter, r *http.Request) {
+	df1 := h.legacyState(r).GetDataFrame(1)
+	df2 := h.legacyState(r).GetDataFrame(2)
+
+	if df1 == nil || df2 == nil {
+		h.httpError(w, r, "Both files must be loaded to calculate similarity", http.StatusBadRequest)
+		return
+	}
+
+	ctx1 := h.legacyState(r).GetContext(1)
+	ctx2 := h.legacyState(r).GetContext(2)
+
+	// Build nodes for graph
+	nodes := []map[string]interface{}{}
+	for _, col := range df1.Headers {
+		nodes = append(nodes, map[string]interface{}{
+			"id":    fmt.Sprintf("file1_%s", col),
+			"label": col,
+			"group": "file1",
+		})
+	}
+	for _, col := range df2.Headers {
+		nodes = append(nodes, map[string]interface{}{
+			"id":    fmt.Sprintf("file2_%s", col),
+			"label": col,
+			"group": "file2",
+		})
+	}
+
+	// Check if AI matching is requested
+	useAI := r.URL.Query().Get("use_ai") == "true"
+
+	// Convert to response format
+	type SimilarityItem struct {
+		File1Column            string  `json:"file1_column"`
+		File2Column            string  `json:"file2_column"`
+		Similarity             float64 `json:"similarity"`
+		Confidence             float64 `json:"confidence"`
+		Type                   string  `json:"type"`
+		DataSimilarity         float64 `json:"data_similarity"`
+		NameSimilarity         float64 `json:"name_similarity"`
+		DistributionSimilarity float64 `json:"distribution_similarity"`
+		JSONConfidence         float64 `json:"json_confidence"`
+		LLMSemanticScore       float64 `json:"llm_semantic_score"`
+		Reason                 string  `json:"reason,omitempty"`
+		TokenSimilarity        float64 `json:"token_similarity,omitempty"`
+		SynonymMatch           bool    `json:"synonym_match,omitempty"`
+		PatternMatch           string  `json:"pattern_match,omitempty"`
+		ValueOverlap           float64 `json:"value_overlap,omitempty"`
+		AIExplanation          string  `json:"ai_explanation,omitempty"`
+	}
+
+	similarities := []SimilarityItem{}
+
+	if useAI && h.AISemanticMatcher != nil {
+		// Use AI-powered matching
+		h.Logger.Info("using AI-powered semantic matching via Ollama")
+		aiResults := h.AISemanticMatcher.MatchColumns(df1, df2, ctx1, ctx2)
+		for _, r := range aiResults {
+			similarities = append(similarities, SimilarityItem{
+				File1Column:            r.File1Column,
+				File2Column:            r.File2Column,
+				Similarity:             r.Confidence / 100,
+				Confidence:             r.Confidence,
+				Type:                   r.MatchType,
+				DataSimilarity:         r.DataSimilarity,
+				NameSimilarity:         r.NameSimilarity,
+				DistributionSimilarity: r.DistributionSimilarity,
+				LLMSemanticScore:       r.SemanticScore,
+				Reason:                 r.Reason,
+				ValueOverlap:           r.ValueOverlap,
+				AIExplanation:          r.AIExplanation,
+			})
+		}
+	} else {
+		// Use Enhanced heuristic matching (default)
+		enhancedResults := h.EnhancedSimilarityService.CalculateEnhancedSimilarity(df1, df2, ctx1, ctx2)
+		for _, r := range enhancedResults {
+			similarities = append(similarities, SimilarityItem{
+				File1Column:            r.File1Column,
+				File2Column:            r.File2Column,
+				Similarity:             r.Similarity,
+				Confidence:             r.Confidence,
+				Type:                   r.Type,
+				DataSimilarity:         r.DataSimilarity,
+				NameSimilarity:         r.NameSimilarity,
+				DistributionSimilarity: r.DistributionSimilarity,
+				JSONConfidence:         r.JSONConfidence,
+				LLMSemanticScore:       r.LLMSemanticScore,
+				Reason:                 r.Reason,
+				TokenSimilarity:        r.TokenSimilarity,
+				SynonymMatch:           r.SynonymMatch,
+				PatternMatch:           r.PatternMatch,
+				ValueOverlap:           r.ValueOverlap,
+			})
+		}
+	}
+
+	totalRelationships := len(similarities)
+
+	// Limit to top 15 for display
+	if len(similarities) > 15 {
+		similarities = similarities[:15]
+	}
+
+	// Build edges from top similarities
+	edges := []map[string]interface{}{}
+	for _, sim := range similarities {
+		edges = append(edges, map[string]interface{}{
+			"source":     fmt.Sprintf("file1_%s", sim.File1Column),
+			"target":     fmt.Sprintf("file2_%s", sim.File2Column),
+			"value":      sim.Confidence,
+			"similarity": sim.Similarity,
+			"type":       sim.Type,
+			"label":      fmt.Sprintf("%d%%", int(sim.Confidence)),
+		})
+	}
+
+	// Calculate correlations for ALL numeric column pairs (not just name-matched)
+	type CorrelationItem struct {
+		File1Column         string  `json:"file1_column"`
+		File2Column         string  `json:"file2_column"`
+		PearsonCorrelation  float64 `json:"pearson_correlation"`
+		SpearmanCorrelation float64 `json:"spearman_correlation"`
+		Strength            string  `json:"strength"`
+		SampleSize          int     `json:"sample_size"`
+	}
+
+	correlations := []CorrelationItem{}
+	numericCols1 := df1.GetNumericColumnIndices()
+	numericCols2 := df2.GetNumericColumnIndices()
+
+	// Calculate correlations for ALL numeric column pairs
+	for col1Idx, isNumeric1 := range numericCols1 {
+		if !isNumeric1 || col1Idx >= len(df1.Headers) {
+			continue
+		}
+		col1Name := df1.Headers[col1Idx]
+
+		for col2Idx, isNumeric2 := range numericCols2 {
+			if !isNumeric2 || col2Idx >= len(df2.Headers) {
+				continue
+			}
+			col2Name := df2.Headers[col2Idx]
+
+			// Get values
+			vals1 := getNumericValues(df1, col1Idx)
+			vals2 := getNumericValues(df2, col2Idx)
+
+			if len(vals1) == 0 || len(vals2) == 0 {
+				continue
+			}
+
+			// Use minimum length
+			minLen := len(vals1)
+			if len(vals2) < minLen {
+				minLen = len(vals2)
+			}
+			if minLen < 2 {
+				continue
+			}
+
+			vals1 = vals1[:minLen]
+			vals2 = vals2[:minLen]
+
+			pearson := pearsonCorrelation(vals1, vals2)
+			spearman := spearmanCorrelation(vals1, vals2)
+
+			// Skip if correlation is very weak (less than 0.1)
+			if math.Abs(pearson) < 0.1 {
+				continue
+			}
+
+			// Determine strength
+			absPearson := math.Abs(pearson)
+			strength := "None"
+			if absPearson >= 0.7 {
+				strength = "Strong"
+			} else if absPearson >= 0.4 {
+				strength = "Moderate"
+			} else if absPearson >= 0.2 {
+				strength = "Weak"
+			}
+
+			correlations = append(correlations, CorrelationItem{
+				File1Column:         col1Name,
+				File2Column:         col2Name,
+				PearsonCorrelation:  pearson,
+				SpearmanCorrelation: spearman,
+				Strength:            strength,
+				SampleSize:          minLen,
+			})
+		}
+	}
+
+	// Sort correlations by absolute Pearson value
+	sort.Slice(correlations, func(i, j int) bool {
+		return math.Abs(correlations[i].PearsonCorrelation) > math.Abs(correlations[j].PearsonCorrelation)
+	})
+
+	// Limit to top 20 correlations
+	if len(correlations) > 20 {
+		correlations = correlations[:20]
+	}
+
+	// Return response in Python backend format
+	resp := map[string]interface{}{
+		"nodes":               nodes,
+		"edges":               edges,
+		"similarities":        similarities,
+		"total_relationships": totalRelationships,
+		"correlations":        correlations,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func getColumnIndex(headers []string, col string) int {
+	for i, h := range headers {
+		if h == col {
+			return i
+		}
+	}
+	return -1
+}
+
+func getPatternScore(col1, col2 string) float64 {
+	patterns := map[string][]string{
+		"email":   {"email", "e-mail", "mail"},
+		"id":      {"id", "identifier", "key", "code", "number"},
+		"date":    {"date", "time", "year", "month", "day", "created", "updated", "timestamp"},
+		"name":    {"name", "first", "last", "full", "surname"},
+		"phone":   {"phone", "mobile", "cell", "contact", "tel"},
+		"address": {"address", "city", "state", "zip", "postal", "country", "location"},
+		"price":   {"price", "cost", "amount", "value", "total", "revenue", "fee"},
+		"status":  {"status", "state", "condition", "flag"},
+	}
+
+	c1Lower := strings.ToLower(col1)
+	c2Lower := strings.ToLower(col2)
+
+	for _, keywords := range patterns {
+		match1, match2 := false, false
+		for _, kw := range keywords {
+			if strings.Contains(c1Lower, kw) {
+				match1 = true
+			}
+			if strings.Contains(c2Lower, kw) {
+				match2 = true
+			}
+		}
+		if match1 && match2 {
+			return 0.9
+		}
+	}
+	return 0.0
+}
+
+func estimateDataSimilarity(df1, df2 *state.DataFrame, col1Idx, col2Idx int) float64 {
+	// Quick heuristic: compare types and sample values
+	if len(df1.Rows) == 0 || len(df2.Rows) == 0 {
+		return 0.0
+	}
+
+	// Check if both are numeric
+	num1 := df1.GetNumericColumnIndices()
+	num2 := df2.GetNumericColumnIndices()
+
+	isNum1 := num1[col1Idx]
+	isNum2 := num2[col2Idx]
+
+	if isNum1 && isNum2 {
+		// Both numeric - assume some similarity
+		return 0.4
+	} else if !isNum1 && !isNum2 {
+		// Both string - check Jaccard of unique values
+		set1 := make(map[string]bool)
+		set2 := make(map[string]bool)
+
+		limit := 100
+		if len(df1.Rows) < limit {
+			limit = len(df1.Rows)
+		}
+		for i := 0; i < limit; i++ {
+			if col1Idx < len(df1.Rows[i]) {
+				set1[df1.Rows[i][col1Idx]] = true
+			}
+		}
+
+		limit = 100
+		if len(df2.Rows) < limit {
+			limit = len(df2.Rows)
+		}
+		for i := 0; i < limit; i++ {
+			if col2Idx < len(df2.Rows[i]) {
+				set2[df2.Rows[i][col2Idx]] = true
+			}
+		}
+
+		// Calculate Jaccard
+		intersection := 0
+		for k := range set1 {
+			if set2[k] {
+				intersection++
+			}
+		}
+
+		union := len(set1) + len(set2) - intersection
+		if union > 0 {
+			return float64(intersection) / float64(union)
+		}
+	}
+
+	return 0.0
+}
+
+// ============================================================================
+// Correlation
+// ============================================================================
+
+func (h *Handler) GetCorrelation(w http.ResponseWriter, r *http.Request) {
+	col1 := r.URL.Query().Get("col1")
+	col2 := r.URL.Query().Get("col2")
+
+	// If no params provided, return correlations for all matched column pairs
+	if col1 == "" && col2 == "" {
+		h.GetAllCorrelations(w, r)
+		return
+	}
+
+	fileIndex := getIntParam(r, "file_index", 1)
+
+	df := h.legacyState(r).GetDataFrame(fileIndex)
+	if df == nil {
+		h.httpError(w, r, fmt.Sprintf("File %d not loaded", fileIndex), http.StatusBadRequest)
+		return
+	}
+
+	// Find column indices
+	col1Idx, col2Idx := -1, -1
+	for i, h := range df.Headers {
+		if h == col1 {
+			col1Idx = i
+		}
+		if h == col2 {
+			col2Idx = i
+		}
+	}
+
+	if col1Idx == -1 || col2Idx == -1 {
+		h.httpError(w, r, "Column not found", http.StatusNotFound)
+		return
+	}
+
+	// Calculate correlation
+	vals1, vals2 := []float64{}, []float64{}
+	for _, row := range df.Rows {
+		if col1Idx >= len(row) || col2Idx >= len(row) {
+			continue
+		}
+		v1, err1 := strconv.ParseFloat(row[col1Idx], 64)
+		v2, err2 := strconv.ParseFloat(row[col2Idx], 64)
+		if err1 == nil && err2 == nil {
+			vals1 = append(vals1, v1)
+			vals2 = append(vals2, v2)
+		}
+	}
+
+	if len(vals1) < 2 {
+		h.httpError(w, r, "Not enough numeric values for correlation", http.StatusBadRequest)
+		return
+	}
+
+	corr := pearsonCorrelation(vals1, vals2)
+	interpretation := "Weak/None"
+	if corr > 0.7 {
+		interpretation = "Strong positive"
+	} else if corr < -0.7 {
+		interpretation = "Strong negative"
+	} else if corr > 0.3 {
+		interpretation = "Moderate positive"
+	} else if corr < -0.3 {
+		interpretation = "Moderate negative"
+	}
+
+	resp := models.CorrelationResult{
+		Column1:        col1,
+		Column2:        col2,
+		Correlation:    corr,
+		Interpretation: interpretation,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetAllCorrelations returns correlations for all numeric column pairs between two files
+func (h *Handler) GetAllCorrelations(w http.ResponseWriter, r *http.Request) {
+	df1 := h.legacyState(r).GetDataFrame(1)
+	df2 := h.legacyState(r).GetDataFrame(2)
+
+	if df1 == nil || df2 == nil {
+		h.httpError(w, r, "Both files must be loaded to calculate correlations", http.StatusBadRequest)
+		return
+	}
+
+	// Get numeric columns from both files
+	numericCols1 := df1.GetNumericColumnIndices()
+	numericCols2 := df2.GetNumericColumnIndices()
+
+	type CorrelationItem struct {
+		File1Column         string  `json:"file1_column"`
+		File2Column         string  `json:"file2_column"`
+		Correlation         float64 `json:"correlation"`
+		PearsonCorrelation  float64 `json:"pearson_correlation"`
+		SpearmanCorrelation float64 `json:"spearman_correlation"`
+		Strength            string  `json:"strength"`
+		SampleSize          int     `json:"sample_size"`
+		File1Rows           int     `json:"file1_rows"`
+		File2Rows           int     `json:"file2_rows"`
+	}
+
+	correlations := []CorrelationItem{}
+
+	// Calculate correlations for matching numeric columns
+	for col1Idx := range numericCols1 {
+		if col1Idx >= len(df1.Headers) {
+			continue
+		}
+		col1Name := df1.Headers[col1Idx]
+
+		for col2Idx := range numericCols2 {
+			if col2Idx >= len(df2.Headers) {
+				continue
+			}
+			col2Name := df2.Headers[col2Idx]
+
+			// Get values
+			vals1 := getNumericValues(df1, col1Idx)
+			vals2 := getNumericValues(df2, col2Idx)
+
+			if len(vals1) == 0 || len(vals2) == 0 {
+				continue
+			}
+
+			// Use minimum length
+			minLen := len(vals1)
+			if len(vals2) < minLen {
+				minLen = len(vals2)
+			}
+			if minLen < 2 {
+				continue
+			}
 
 			vals1 = vals1[:minLen]
 			vals2 = vals2[:minLen]
 
-			corr := pearsonCorrelation(vals1, vals2)
-			spearman := spearmanCorrelation(vals1, vals2)
+			corr := pearsonCorrelation(vals1, vals2)
+			spearman := spearmanCorrelation(vals1, vals2)
+
+			// Determine strength
+			absCorr := math.Abs(corr)
+			strength := "None"
+			if absCorr >= 0.7 {
+				strength = "Strong"
+			} else if absCorr >= 0.4 {
+				strength = "Moderate"
+			} else if absCorr >= 0.2 {
+				strength = "Weak"
+			}
+
+			// Only include if there's some correlation
+			if absCorr >= 0.1 {
+				correlations = append(correlations, CorrelationItem{
+					File1Column:         col1Name,
+					File2Column:         col2Name,
+					Correlation:         corr,
+					PearsonCorrelation:  corr,
+					SpearmanCorrelation: spearman,
+					Strength:            strength,
+					SampleSize:          minLen,
+					File1Rows:           len(df1.Rows),
+					File2Rows:           len(df2.Rows),
+				})
+			}
+		}
+	}
+
+	// Sort by absolute correlation descending
+	sort.Slice(correlations, func(i, j int) bool {
+		return math.Abs(correlations[i].Correlation) > math.Abs(correlations[j].Correlation)
+	})
+
+	// Limit to top 50
+	if len(correlations) > 50 {
+		correlations = correlations[:50]
+	}
+
+	// Get column lists
+	file1Cols := []string{}
+	file2Cols := []string{}
+	for i, h := range df1.Headers {
+		if numericCols1[i] {
+			file1Cols = append(file1Cols, h)
+		}
+	}
+	for i, h := range df2.Headers {
+		if numericCols2[i] {
+			file2Cols = append(file2Cols, h)
+		}
+	}
+
+	resp := map[string]interface{}{
+		"total_correlations": len(correlations),
+		"correlations":       correlations,
+		"file1_columns":      file1Cols,
+		"file2_columns":      file2Cols,
+		"file1_rows":         len(df1.Rows),
+		"file2_rows":         len(df2.Rows),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func getNumericValues(df *state.DataFrame, colIdx int) []float64 {
+	values := []float64{}
+	for _, row := range df.Rows {
+		if colIdx >= len(row) {
+			continue
+		}
+		if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
+func spearmanCorrelation(x, y []float64) float64 {
+	// Simple Spearman: convert to ranks and compute Pearson
+	n := len(x)
+	if n == 0 {
+		return 0
+	}
+
+	rankX := computeRanks(x)
+	rankY := computeRanks(y)
+
+	return pearsonCorrelation(rankX, rankY)
+}
+
+func computeRanks(vals []float64) []float64 {
+	n := len(vals)
+	type indexedVal struct {
+		val   float64
+		index int
+	}
+
+	indexed := make([]indexedVal, n)
+	for i, v := range vals {
+		indexed[i] = indexedVal{v, i}
+	}
+
+	sort.Slice(indexed, func(i, j int) bool {
+		return indexed[i].val < indexed[j].val
+	})
+
+	ranks := make([]float64, n)
+	for rank, iv := range indexed {
+		ranks[iv.index] = float64(rank + 1)
+	}
+	return ranks
+}
+
+func pearsonCorrelation(x, y []float64) float64 {
+	n := float64(len(x))
+	if n == 0 {
+		return 0
+	}
+
+	sumX, sumY, sumXY, sumX2, sumY2 := 0.0, 0.0, 0.0, 0.0, 0.0
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+		sumXY += x[i] * y[i]
+		sumX2 += x[i] * x[i]
+		sumY2 += y[i] * y[i]
+	}
+
+	num := n*sumXY - sumX*sumY
+	den := math.Sqrt((n*sumX2 - sumX*sumX) * (n*sumY2 - sumY*sumY))
+
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
 
-			// Determine strength
-			absCorr := math.Abs(corr)
-			strength := "None"
-			if absCorr >= 0.7 {
-				strength = "Strong"
-			} else if absCorr >= 0.4 {
-				strength = "Moderate"
-			} else if absCorr >= 0.2 {
-				strength = "Weak"
+// ============================================================================
+// Filter
+// ============================================================================
+
+func (h *Handler) FilterData(w http.ResponseWriter, r *http.Request) {
+	df := h.legacyState(r).GetDataFrame(1)
+	if df == nil {
+		h.httpError(w, r, "No CSV file loaded", http.StatusBadRequest)
+		return
+	}
+
+	var req models.FilterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Build column index map
+	colIdx := make(map[string]int)
+	for i, h := range df.Headers {
+		colIdx[h] = i
+	}
+
+	// Filter rows
+	filtered := [][]string{}
+	for _, row := range df.Rows {
+		match := true
+		for _, cond := range req.Conditions {
+			idx, ok := colIdx[cond.Column]
+			if !ok || idx >= len(row) {
+				continue
+			}
+			val := row[idx]
+
+			switch cond.Operator {
+			case "equals":
+				if val != cond.Value {
+					match = false
+				}
+			case "contains":
+				if !strings.Contains(strings.ToLower(val), strings.ToLower(cond.Value)) {
+					match = false
+				}
+			case "greater_than":
+				fVal, err1 := strconv.ParseFloat(val, 64)
+				fCond, err2 := strconv.ParseFloat(cond.Value, 64)
+				if err1 != nil || err2 != nil || fVal <= fCond {
+					match = false
+				}
+			case "less_than":
+				fVal, err1 := strconv.ParseFloat(val, 64)
+				fCond, err2 := strconv.ParseFloat(cond.Value, 64)
+				if err1 != nil || err2 != nil || fVal >= fCond {
+					match = false
+				}
+			}
+		}
+		if match {
+			filtered = append(filtered, row)
+		}
+	}
+
+	// Convert to response format (limit to 100)
+	limit := 100
+	if len(filtered) < limit {
+		limit = len(filtered)
+	}
+
+	data := make([]map[string]interface{}, limit)
+	for i := 0; i < limit; i++ {
+		row := make(map[string]interface{})
+		for j, header := range df.Headers {
+			if j < len(filtered[i]) {
+				row[header] = filtered[i][j]
+			}
+		}
+		data[i] = row
+	}
+
+	resp := models.FilterResponse{
+		Rows: len(filtered),
+		Data: data,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ============================================================================
+// Query (LLM-based data analysis)
+// ============================================================================
+
+type QueryRequest struct {
+	Question string `json:"question"`
+}
+
+type QueryResponse struct {
+	Answer      string                   `json:"answer"`
+	Explanation string                   `json:"explanation"`
+	RawResponse string                   `json:"raw_response,omitempty"`
+	Result      string                   `json:"result,omitempty"`
+	ResultData  []map[string]interface{} `json:"result_data,omitempty"`
+	ResultType  string                   `json:"result_type,omitempty"`
+	Error       string                   `json:"error,omitempty"`
+}
+
+func (h *Handler) Query(w http.ResponseWriter, r *http.Request) {
+	df := h.legacyState(r).GetDataFrame(1)
+	if df == nil {
+		h.httpError(w, r, "No CSV file loaded. Please upload a file first.", http.StatusBadRequest)
+		return
+	}
+
+	var req QueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Question == "" {
+		h.httpError(w, r, "Question is required", http.StatusBadRequest)
+		return
+	}
+
+	// Process the query
+	question := strings.ToLower(req.Question)
+	resp := QueryResponse{}
+
+	// Simple query processing without LLM (fallback mode)
+	if strings.Contains(question, "average") || strings.Contains(question, "mean") {
+		resp = h.processAverageQuery(df, question)
+	} else if strings.Contains(question, "sum") || strings.Contains(question, "total") {
+		resp = h.processSumQuery(df, question)
+	} else if strings.Contains(question, "count") || strings.Contains(question, "how many") {
+		resp = h.processCountQuery(df, question)
+	} else if strings.Contains(question, "max") || strings.Contains(question, "maximum") || strings.Contains(question, "highest") {
+		resp = h.processMaxQuery(df, question)
+	} else if strings.Contains(question, "min") || strings.Contains(question, "minimum") || strings.Contains(question, "lowest") {
+		resp = h.processMinQuery(df, question)
+	} else if strings.Contains(question, "overview") || strings.Contains(question, "summary") || strings.Contains(question, "describe") {
+		resp = h.processOverviewQuery(df)
+	} else if strings.Contains(question, "top") {
+		resp = h.processTopQuery(df, question)
+	} else {
+		// Default: provide overview
+		resp = h.processOverviewQuery(df)
+		resp.Explanation = fmt.Sprintf("I understood your question: '%s'. Here's an overview of the data. For specific queries, try asking about averages, sums, counts, or statistics.", req.Question)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) processAverageQuery(df *state.DataFrame, question string) QueryResponse {
+	numericCols := df.GetNumericColumnIndices()
+	results := []string{}
+
+	for colIdx, isNumeric := range numericCols {
+		if !isNumeric || colIdx >= len(df.Headers) {
+			continue
+		}
+		colName := df.Headers[colIdx]
+		if strings.Contains(question, strings.ToLower(colName)) || strings.Contains(question, "all") {
+			sum, count := 0.0, 0
+			for _, row := range df.Rows {
+				if colIdx < len(row) {
+					if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
+						sum += val
+						count++
+					}
+				}
+			}
+			if count > 0 {
+				avg := sum / float64(count)
+				results = append(results, fmt.Sprintf("%s: %.2f", colName, avg))
+			}
+		}
+	}
+
+	if len(results) == 0 {
+		// Calculate for all numeric columns
+		for colIdx, isNumeric := range numericCols {
+			if !isNumeric || colIdx >= len(df.Headers) {
+				continue
+			}
+			colName := df.Headers[colIdx]
+			sum, count := 0.0, 0
+			for _, row := range df.Rows {
+				if colIdx < len(row) {
+					if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
+						sum += val
+						count++
+					}
+				}
+			}
+			if count > 0 {
+				avg := sum / float64(count)
+				results = append(results, fmt.Sprintf("%s: %.2f", colName, avg))
+			}
+		}
+	}
+
+	return QueryResponse{
+		Answer:      fmt.Sprintf("Average values:\n%s", strings.Join(results, "\n")),
+		Explanation: "Calculated average for numeric columns.",
+		Result:      strings.Join(results, "\n"),
+		ResultType:  "statistics",
+	}
+}
+
+func (h *Handler) processSumQuery(df *state.DataFrame, question string) QueryResponse {
+	numericCols := df.GetNumericColumnIndices()
+	results := []string{}
+
+	for colIdx := range numericCols {
+		if colIdx >= len(df.Headers) {
+			continue
+		}
+		colName := df.Headers[colIdx]
+		sum := 0.0
+		for _, row := range df.Rows {
+			if colIdx < len(row) {
+				if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
+					sum += val
+				}
 			}
+		}
+		results = append(results, fmt.Sprintf("%s: %.2f", colName, sum))
+	}
+
+	return QueryResponse{
+		Answer:      fmt.Sprintf("Sum of values:\n%s", strings.Join(results, "\n")),
+		Explanation: "Calculated sum for numeric columns.",
+		Result:      strings.Join(results, "\n"),
+		ResultType:  "statistics",
+	}
+}
+
+func (h *Handler) processCountQuery(df *state.DataFrame, question string) QueryResponse {
+	return QueryResponse{
+		Answer:      fmt.Sprintf("Total rows: %d\nTotal columns: %d", len(df.Rows), len(df.Headers)),
+		Explanation: "Counted records in the dataset.",
+		Result:      fmt.Sprintf("Rows: %d, Columns: %d", len(df.Rows), len(df.Headers)),
+		ResultType:  "count",
+	}
+}
+
+func (h *Handler) processMaxQuery(df *state.DataFrame, question string) QueryResponse {
+	numericCols := df.GetNumericColumnIndices()
+	results := []string{}
 
-			// Only include if there's some correlation
-			if absCorr >= 0.1 {
-				correlations = append(correlations, CorrelationItem{
-					File1Column:         col1Name,
-					File2Column:         col2Name,
-					Correlation:         corr,
-					PearsonCorrelation:  corr,
-					SpearmanCorrelation: spearman,
-					Strength:            strength,
-					SampleSize:          minLen,
-					File1Rows:           len(df1.Rows),
-					File2Rows:           len(df2.Rows),
-				})
+	for colIdx := range numericCols {
+		if colIdx >= len(df.Headers) {
+			continue
+		}
+		colName := df.Headers[colIdx]
+		maxVal := math.Inf(-1)
+		for _, row := range df.Rows {
+			if colIdx < len(row) {
+				if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
+					if val > maxVal {
+						maxVal = val
+					}
+				}
 			}
 		}
+		if maxVal != math.Inf(-1) {
+			results = append(results, fmt.Sprintf("%s: %.2f", colName, maxVal))
+		}
 	}
 
-	// Sort by absolute correlation descending
-	sort.Slice(correlations, func(i, j int) bool {
-		return math.Abs(correlations[i].Correlation) > math.Abs(correlations[j].Correlation)
-	})
-
-	// Limit to top 50
-	if len(correlations) > 50 {
-		correlations = correlations[:50]
+	return QueryResponse{
+		Answer:      fmt.Sprintf("Maximum values:\n%s", strings.Join(results, "\n")),
+		Explanation: "Found maximum for numeric columns.",
+		Result:      strings.Join(results, "\n"),
+		ResultType:  "statistics",
 	}
+}
 
-	// Get column lists
-	file1Cols := []string{}
-	file2Cols := []string{}
-	for i, h := range df1.Headers {
-		if numericCols1[i] {
-			file1Cols = append(file1Cols, h)
+func (h *Handler) processMinQuery(df *state.DataFrame, question string) QueryResponse {
+	numericCols := df.GetNumericColumnIndices()
+	results := []string{}
+
+	for colIdx := range numericCols {
+		if colIdx >= len(df.Headers) {
+			continue
+		}
+		colName := df.Headers[colIdx]
+		minVal := math.Inf(1)
+		for _, row := range df.Rows {
+			if colIdx < len(row) {
+				if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
+					if val < minVal {
+						minVal = val
+					}
+				}
+			}
+		}
+		if minVal != math.Inf(1) {
+			results = append(results, fmt.Sprintf("%s: %.2f", colName, minVal))
 		}
 	}
-	for i, h := range df2.Headers {
-		if numericCols2[i] {
-			file2Cols = append(file2Cols, h)
+
+	return QueryResponse{
+		Answer:      fmt.Sprintf("Minimum values:\n%s", strings.Join(results, "\n")),
+		Explanation: "Found minimum for numeric columns.",
+		Result:      strings.Join(results, "\n"),
+		ResultType:  "statistics",
+	}
+}
+
+func (h *Handler) processOverviewQuery(df *state.DataFrame) QueryResponse {
+	numericCols := df.GetNumericColumnIndices()
+
+	summary := fmt.Sprintf("📊 Dataset Overview:\n• Rows: %d\n• Columns: %d\n• Column names: %s\n\n",
+		len(df.Rows), len(df.Headers), strings.Join(df.Headers, ", "))
+
+	numericHeaders := []string{}
+	for colIdx := range numericCols {
+		if colIdx < len(df.Headers) {
+			numericHeaders = append(numericHeaders, df.Headers[colIdx])
 		}
 	}
 
-	resp := map[string]interface{}{
-		"total_correlations": len(correlations),
-		"correlations":       correlations,
-		"file1_columns":      file1Cols,
-		"file2_columns":      file2Cols,
-		"file1_rows":         len(df1.Rows),
-		"file2_rows":         len(df2.Rows),
+	if len(numericHeaders) > 0 {
+		summary += fmt.Sprintf("📈 Numeric columns: %s\n", strings.Join(numericHeaders, ", "))
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	return QueryResponse{
+		Answer:      summary,
+		Explanation: "Generated overview of the dataset.",
+		ResultType:  "overview",
+	}
 }
 
-func getNumericValues(df *state.DataFrame, colIdx int) []float64 {
-	values := []float64{}
-	for _, row := range df.Rows {
-		if colIdx >= len(row) {
-			continue
-		}
-		if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
-			values = append(values, val)
+func (h *Handler) processTopQuery(df *state.DataFrame, question string) QueryResponse {
+	// Default to top 5
+	n := 5
+	if strings.Contains(question, "10") {
+		n = 10
+	} else if strings.Contains(question, "3") {
+		n = 3
+	}
+
+	if n > len(df.Rows) {
+		n = len(df.Rows)
+	}
+
+	data := make([]map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		row := make(map[string]interface{})
+		for j, header := range df.Headers {
+			if j < len(df.Rows[i]) {
+				row[header] = df.Rows[i][j]
+			}
 		}
+		data[i] = row
+	}
+
+	return QueryResponse{
+		Answer:      fmt.Sprintf("Top %d records from the dataset", n),
+		Explanation: fmt.Sprintf("Showing first %d rows.", n),
+		ResultData:  data,
+		ResultType:  "dataframe",
 	}
-	return values
 }
 
-func spearmanCorrelation(x, y []float64) float64 {
-	// Simple Spearman: convert to ranks and compute Pearson
-	n := len(x)
-	if n == 0 {
-		return 0
+// ============================================================================
+// Context Management
+// ============================================================================
+
+func (h *Handler) GenerateContextQuestions(w http.ResponseWriter, r *http.Request) {
+	df1 := h.legacyState(r).GetDataFrame(1)
+	df2 := h.legacyState(r).GetDataFrame(2)
+
+	if df1 == nil || df2 == nil {
+		h.httpError(w, r, "Both files must be loaded to generate context questions", http.StatusBadRequest)
+		return
 	}
 
-	rankX := computeRanks(x)
-	rankY := computeRanks(y)
+	// Generate questions for both files
+	analysis1 := h.analyzeDataFrame(df1)
+	analysis2 := h.analyzeDataFrame(df2)
 
-	return pearsonCorrelation(rankX, rankY)
-}
+	questions1 := h.QuestionGenerator.GenerateQuestions(analysis1, 1, "")
+	questions2 := h.QuestionGenerator.GenerateQuestions(analysis2, 2, "")
 
-func computeRanks(vals []float64) []float64 {
-	n := len(vals)
-	type indexedVal struct {
-		val   float64
-		index int
+	// Relationship questions
+	relationshipQuestions := []models.Question{
+		{
+			ID:       "rel_type",
+			Type:     models.QuestionTypeRelationships,
+			Text:     "How are these two datasets related?",
+			Options:  []string{"One-to-One", "One-to-Many", "Many-to-Many", "Hierarchical", "Temporal sequence", "Unknown"},
+			Required: false,
+		},
+		{
+			ID:       "rel_keys",
+			Type:     models.QuestionTypeCustomMappings,
+			Text:     "Which columns should be used to join these datasets?",
+			Options:  append(df1.Headers, df2.Headers...),
+			Required: false,
+		},
 	}
 
-	indexed := make([]indexedVal, n)
-	for i, v := range vals {
-		indexed[i] = indexedVal{v, i}
+	resp := models.QuestionsResponse{
+		Success: true,
+		Questions: map[string][]models.Question{
+			"file1_questions":        questions1,
+			"file2_questions":        questions2,
+			"relationship_questions": relationshipQuestions,
+		},
+		TotalQuestions: len(questions1) + len(questions2) + len(relationshipQuestions),
 	}
 
-	sort.Slice(indexed, func(i, j int) bool {
-		return indexed[i].val < indexed[j].val
-	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
 
-	ranks := make([]float64, n)
-	for rank, iv := range indexed {
-		ranks[iv.index] = float64(rank + 1)
+func (h *Handler) analyzeDataFrame(df *state.DataFrame) models.DataAnalysisResult {
+	result := models.DataAnalysisResult{
+		NumRows:     len(df.Rows),
+		NumColumns:  len(df.Headers),
+		ColumnNames: df.Headers,
+		ColumnTypes: make(map[string]string),
 	}
-	return ranks
+
+	numericCols := df.GetNumericColumnIndices()
+	for i, header := range df.Headers {
+		if numericCols[i] {
+			result.HasNumeric = true
+			headerLower := strings.ToLower(header)
+			if strings.Contains(headerLower, "id") || strings.Contains(headerLower, "key") {
+				result.PotentialIDs = append(result.PotentialIDs, header)
+			}
+			if strings.Contains(headerLower, "amount") || strings.Contains(headerLower, "price") {
+				result.PotentialAmounts = append(result.PotentialAmounts, header)
+			}
+			result.ColumnTypes[header] = "numeric"
+		} else if isDateColumn(df, i) {
+			result.HasDates = true
+			result.PotentialDates = append(result.PotentialDates, header)
+			result.ColumnTypes[header] = "date"
+		} else {
+			result.HasText = true
+			result.ColumnTypes[header] = "string"
+		}
+	}
+	result.SchemaFingerprint = analysis.SchemaFingerprint(result.ColumnTypes)
+
+	return result
 }
 
-func pearsonCorrelation(x, y []float64) float64 {
-	n := float64(len(x))
-	if n == 0 {
-		return 0
+// StoreContext merges the posted context into the one stored for fileIndex.
+// An invalid context is rejected with 422 and {"errors":[{"field","message"}]}.
+func (h *Handler) StoreContext(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	sumX, sumY, sumXY, sumX2, sumY2 := 0.0, 0.0, 0.0, 0.0, 0.0
-	for i := range x {
-		sumX += x[i]
-		sumY += y[i]
-		sumXY += x[i] * y[i]
-		sumX2 += x[i] * x[i]
-		sumY2 += y[i] * y[i]
+	var ctx models.Context
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &ctx); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
 	}
 
-	num := n*sumXY - sumX*sumY
-	den := math.Sqrt((n*sumX2 - sumX*sumX) * (n*sumY2 - sumY*sumY))
-
-	if den == 0 {
-		return 0
+	if err := h.store(r).StoreContext(fileIndex, &ctx); err != nil {
+		h.contextStoreError(w, r, err)
+		return
 	}
-	return num / den
+	h.audit(r, AuditEntry{Operation: AuditContext, FileIndex: fileIndex})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
-// ============================================================================
-// Filter
-// ============================================================================
+// ValidateStoredContext checks that the columns the stored context of
+// fileIndex refers to still exist in its analysis, answering {"valid":true}
+// or {"valid":false,"missing_columns":[...],"extra_columns":[...]}
+func (h *Handler) ValidateStoredContext(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-func (h *Handler) FilterData(w http.ResponseWriter, r *http.Request) {
-	df := state.State.GetDataFrame(1)
-	if df == nil {
-		http.Error(w, "No CSV file loaded", http.StatusBadRequest)
+	store := h.store(r)
+	stored := store.GetAnalysis(fileIndex)
+	if stored == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return
+	}
+	ctx := store.GetContext(fileIndex)
+	if ctx == nil {
+		h.httpError(w, r, fmt.Sprintf("No context stored for File %d", fileIndex), http.StatusNotFound)
 		return
 	}
 
-	var req models.FilterRequest
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(service.CheckContextColumns(ctx, stored))
+}
+
+// contextStoreError reports a failed StoreContext: 422 listing the invalid
+// fields for a *service.ContextValidationError, otherwise 400
+func (h *Handler) contextStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	var verr *service.ContextValidationError
+	if errors.As(err, &verr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(verr)
+		return
+	}
+	h.httpError(w, r, err.Error(), http.StatusBadRequest)
+}
+
+// StoreContextTemplate stores a named context template: the fields of a
+// models.Context plus "name". Storing a name again replaces the template.
+func (h *Handler) StoreContextTemplate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+		models.Context
+	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	// Build column index map
-	colIdx := make(map[string]int)
-	for i, h := range df.Headers {
-		colIdx[h] = i
+	if err := h.store(r).StoreTemplate(req.Name, &req.Context); err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
+	h.audit(r, AuditEntry{Operation: AuditContext, Detail: "template " + req.Name})
 
-	// Filter rows
-	filtered := [][]string{}
-	for _, row := range df.Rows {
-		match := true
-		for _, cond := range req.Conditions {
-			idx, ok := colIdx[cond.Column]
-			if !ok || idx >= len(row) {
-				continue
-			}
-			val := row[idx]
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"name":     req.Name,
+		"template": req.Context,
+	})
+}
 
-			switch cond.Operator {
-			case "equals":
-				if val != cond.Value {
-					match = false
-				}
-			case "contains":
-				if !strings.Contains(strings.ToLower(val), strings.ToLower(cond.Value)) {
-					match = false
-				}
-			case "greater_than":
-				fVal, err1 := strconv.ParseFloat(val, 64)
-				fCond, err2 := strconv.ParseFloat(cond.Value, 64)
-				if err1 != nil || err2 != nil || fVal <= fCond {
-					match = false
-				}
-			case "less_than":
-				fVal, err1 := strconv.ParseFloat(val, 64)
-				fCond, err2 := strconv.ParseFloat(cond.Value, 64)
-				if err1 != nil || err2 != nil || fVal >= fCond {
-					match = false
-				}
-			}
-		}
-		if match {
-			filtered = append(filtered, row)
-		}
+// maxContextOverrideBytes bounds the body of ApplyContextTemplate
+const maxContextOverrideBytes = 64 << 10
+
+// ApplyContextTemplate stores the template named by templateName as the
+// context of fileIndex. An optional body of context fields overrides the
+// template's; the result is validated and merged like StoreContext.
+func (h *Handler) ApplyContextTemplate(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Convert to response format (limit to 100)
-	limit := 100
-	if len(filtered) < limit {
-		limit = len(filtered)
+	store := h.store(r)
+	name := chi.URLParam(r, "templateName")
+	template := store.GetTemplate(name)
+	if template == nil {
+		h.httpError(w, r, fmt.Sprintf("Template %q not found", name), http.StatusNotFound)
+		return
 	}
 
-	data := make([]map[string]interface{}, limit)
-	for i := 0; i < limit; i++ {
-		row := make(map[string]interface{})
-		for j, header := range df.Headers {
-			if j < len(filtered[i]) {
-				row[header] = filtered[i][j]
-			}
+	var overrides *models.Context
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxContextOverrideBytes))
+	if err != nil {
+		h.httpError(w, r, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		overrides = models.NewContext()
+		if err := json.Unmarshal(body, overrides); err != nil {
+			h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+			return
 		}
-		data[i] = row
 	}
 
-	resp := models.FilterResponse{
-		Rows: len(filtered),
-		Data: data,
+	ctx := service.InstantiateTemplate(template, overrides)
+	if err := store.StoreContext(fileIndex, ctx); err != nil {
+		h.contextStoreError(w, r, err)
+		return
 	}
+	h.audit(r, AuditEntry{Operation: AuditContext, FileIndex: fileIndex, Detail: "template " + name})
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
-}
-
-// ============================================================================
-// Query (LLM-based data analysis)
-// ============================================================================
-
-type QueryRequest struct {
-	Question string `json:"question"`
-}
-
-type QueryResponse struct {
-	Answer      string                   `json:"answer"`
-	Explanation string                   `json:"explanation"`
-	RawResponse string                   `json:"raw_response,omitempty"`
-	Result      string                   `json:"result,omitempty"`
-	ResultData  []map[string]interface{} `json:"result_data,omitempty"`
-	ResultType  string                   `json:"result_type,omitempty"`
-	Error       string                   `json:"error,omitempty"`
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"context": store.GetContext(fileIndex),
+	})
 }
 
-func (h *Handler) Query(w http.ResponseWriter, r *http.Request) {
-	df := state.State.GetDataFrame(1)
-	if df == nil {
-		http.Error(w, "No CSV file loaded. Please upload a file first.", http.StatusBadRequest)
+func (h *Handler) SubmitContext(w http.ResponseWriter, r *http.Request) {
+	var req models.ContextSubmitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	var req QueryRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if req.FileIndex != 1 && req.FileIndex != 2 {
+		h.httpError(w, r, "file_index must be 1 or 2", http.StatusBadRequest)
 		return
 	}
 
-	if req.Question == "" {
-		http.Error(w, "Question is required", http.StatusBadRequest)
+	// Convert context data to Context model
+	ctx := models.NewContext()
+	if purpose, ok := req.ContextData["dataset_purpose"].(string); ok {
+		ctx.DatasetPurpose = purpose
+	}
+	if domain, ok := req.ContextData["business_domain"].(string); ok {
+		ctx.BusinessDomain = domain
+	}
+	if entities, ok := req.ContextData["key_entities"].([]interface{}); ok {
+		for _, e := range entities {
+			if s, ok := e.(string); ok {
+				ctx.KeyEntities = append(ctx.KeyEntities, s)
+			}
+		}
+	}
+	if temporal, ok := req.ContextData["temporal_context"].(string); ok {
+		ctx.TemporalContext = temporal
+	}
+	if exclusions, ok := req.ContextData["exclusions"].([]interface{}); ok {
+		for _, e := range exclusions {
+			if s, ok := e.(string); ok {
+				ctx.Exclusions = append(ctx.Exclusions, s)
+			}
+		}
+	}
+
+	h.legacyState(r).SetContext(req.FileIndex, ctx)
+	h.audit(r, AuditEntry{Operation: AuditContext, FileIndex: req.FileIndex})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Context stored for File %d", req.FileIndex),
+		"context": ctx,
+	})
+}
+
+func (h *Handler) GetContext(w http.ResponseWriter, r *http.Request) {
+	fileIndexStr := chi.URLParam(r, "fileIndex")
+	fileIndex, err := strconv.Atoi(fileIndexStr)
+	if err != nil || (fileIndex != 1 && fileIndex != 2) {
+		h.httpError(w, r, "fileIndex must be 1 or 2", http.StatusBadRequest)
 		return
 	}
 
-	// Process the query
-	question := strings.ToLower(req.Question)
-	resp := QueryResponse{}
+	ctx := h.legacyState(r).GetContext(fileIndex)
 
-	// Simple query processing without LLM (fallback mode)
-	if strings.Contains(question, "average") || strings.Contains(question, "mean") {
-		resp = h.processAverageQuery(df, question)
-	} else if strings.Contains(question, "sum") || strings.Contains(question, "total") {
-		resp = h.processSumQuery(df, question)
-	} else if strings.Contains(question, "count") || strings.Contains(question, "how many") {
-		resp = h.processCountQuery(df, question)
-	} else if strings.Contains(question, "max") || strings.Contains(question, "maximum") || strings.Contains(question, "highest") {
-		resp = h.processMaxQuery(df, question)
-	} else if strings.Contains(question, "min") || strings.Contains(question, "minimum") || strings.Contains(question, "lowest") {
-		resp = h.processMinQuery(df, question)
-	} else if strings.Contains(question, "overview") || strings.Contains(question, "summary") || strings.Contains(question, "describe") {
-		resp = h.processOverviewQuery(df)
-	} else if strings.Contains(question, "top") {
-		resp = h.processTopQuery(df, question)
-	} else {
-		// Default: provide overview
-		resp = h.processOverviewQuery(df)
-		resp.Explanation = fmt.Sprintf("I understood your question: '%s'. Here's an overview of the data. For specific queries, try asking about averages, sums, counts, or statistics.", req.Question)
+	resp := map[string]interface{}{
+		"success":     true,
+		"has_context": ctx != nil,
+		"context":     ctx,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-func (h *Handler) processAverageQuery(df *state.DataFrame, question string) QueryResponse {
-	numericCols := df.GetNumericColumnIndices()
-	results := []string{}
-
-	for colIdx, isNumeric := range numericCols {
-		if !isNumeric || colIdx >= len(df.Headers) {
-			continue
-		}
-		colName := df.Headers[colIdx]
-		if strings.Contains(question, strings.ToLower(colName)) || strings.Contains(question, "all") {
-			sum, count := 0.0, 0
-			for _, row := range df.Rows {
-				if colIdx < len(row) {
-					if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
-						sum += val
-						count++
-					}
-				}
-			}
-			if count > 0 {
-				avg := sum / float64(count)
-				results = append(results, fmt.Sprintf("%s: %.2f", colName, avg))
-			}
-		}
+// GetQuestions endpoint (My V2 impl)
+func (h *Handler) GetQuestions(w http.ResponseWriter, r *http.Request) {
+	questions, _, ok := h.generateQuestions(w, r)
+	if !ok {
+		return
 	}
 
-	if len(results) == 0 {
-		// Calculate for all numeric columns
-		for colIdx, isNumeric := range numericCols {
-			if !isNumeric || colIdx >= len(df.Headers) {
-				continue
-			}
-			colName := df.Headers[colIdx]
-			sum, count := 0.0, 0
-			for _, row := range df.Rows {
-				if colIdx < len(row) {
-					if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
-						sum += val
-						count++
-					}
-				}
-			}
-			if count > 0 {
-				avg := sum / float64(count)
-				results = append(results, fmt.Sprintf("%s: %.2f", colName, avg))
-			}
-		}
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questions)
+}
 
-	return QueryResponse{
-		Answer:      fmt.Sprintf("Average values:\n%s", strings.Join(results, "\n")),
-		Explanation: "Calculated average for numeric columns.",
-		Result:      strings.Join(results, "\n"),
-		ResultType:  "statistics",
+// ExportQuestions returns the generated questions as a downloadable file;
+// format is json (default, pretty-printed) or csv
+func (h *Handler) ExportQuestions(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		h.httpError(w, r, "format must be json or csv", http.StatusBadRequest)
+		return
 	}
-}
 
-func (h *Handler) processSumQuery(df *state.DataFrame, question string) QueryResponse {
-	numericCols := df.GetNumericColumnIndices()
-	results := []string{}
+	questions, fileIndex, ok := h.generateQuestions(w, r)
+	if !ok {
+		return
+	}
 
-	for colIdx := range numericCols {
-		if colIdx >= len(df.Headers) {
-			continue
-		}
-		colName := df.Headers[colIdx]
-		sum := 0.0
-		for _, row := range df.Rows {
-			if colIdx < len(row) {
-				if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
-					sum += val
-				}
-			}
-		}
-		results = append(results, fmt.Sprintf("%s: %.2f", colName, sum))
+	filename := fmt.Sprintf("questions_file%d.%s", fileIndex, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(questions)
+		return
 	}
 
-	return QueryResponse{
-		Answer:      fmt.Sprintf("Sum of values:\n%s", strings.Join(results, "\n")),
-		Explanation: "Calculated sum for numeric columns.",
-		Result:      strings.Join(results, "\n"),
-		ResultType:  "statistics",
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"question", "category", "difficulty", "sql_hint"})
+	for _, q := range questions {
+		sqlHint, _ := q.Metadata["sql_hint"].(string)
+		cw.Write([]string{q.Text, q.Type, q.Difficulty, sqlHint})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		h.Logger.Error("writing questions csv", "err", err, "request_id", GetRequestID(r.Context()))
 	}
 }
 
-func (h *Handler) processCountQuery(df *state.DataFrame, question string) QueryResponse {
-	return QueryResponse{
-		Answer:      fmt.Sprintf("Total rows: %d\nTotal columns: %d", len(df.Rows), len(df.Headers)),
-		Explanation: "Counted records in the dataset.",
-		Result:      fmt.Sprintf("Rows: %d, Columns: %d", len(df.Rows), len(df.Headers)),
-		ResultType:  "count",
+// generateQuestions runs the question generator for the fileIndex URL param
+// and difficulty query param, writing an error response and returning false
+// on failure
+func (h *Handler) generateQuestions(w http.ResponseWriter, r *http.Request) ([]models.Question, int, bool) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return nil, 0, false
 	}
-}
 
-func (h *Handler) processMaxQuery(df *state.DataFrame, question string) QueryResponse {
-	numericCols := df.GetNumericColumnIndices()
-	results := []string{}
+	// Retrieve analysis from storage
+	analysis := h.store(r).GetAnalysis(fileIndex)
+	if analysis == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return nil, 0, false
+	}
 
-	for colIdx := range numericCols {
-		if colIdx >= len(df.Headers) {
-			continue
-		}
-		colName := df.Headers[colIdx]
-		maxVal := math.Inf(-1)
-		for _, row := range df.Rows {
-			if colIdx < len(row) {
-				if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
-					if val > maxVal {
-						maxVal = val
-					}
-				}
-			}
-		}
-		if maxVal != math.Inf(-1) {
-			results = append(results, fmt.Sprintf("%s: %.2f", colName, maxVal))
-		}
+	difficulty, err := service.ParseDifficulty(r.URL.Query().Get("difficulty"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return nil, 0, false
 	}
 
-	return QueryResponse{
-		Answer:      fmt.Sprintf("Maximum values:\n%s", strings.Join(results, "\n")),
-		Explanation: "Found maximum for numeric columns.",
-		Result:      strings.Join(results, "\n"),
-		ResultType:  "statistics",
+	return h.QuestionGenerator.GenerateQuestions(*analysis, fileIndex, difficulty), fileIndex, true
+}
+
+func (h *Handler) DeleteContext(w http.ResponseWriter, r *http.Request) {
+	fileIndexStr := chi.URLParam(r, "fileIndex")
+	fileIndex, err := strconv.Atoi(fileIndexStr)
+	if err != nil || (fileIndex != 1 && fileIndex != 2) {
+		h.httpError(w, r, "fileIndex must be 1 or 2", http.StatusBadRequest)
+		return
 	}
+
+	h.legacyState(r).ClearContext(&fileIndex)
+	h.audit(r, AuditEntry{Operation: AuditDelete, FileIndex: fileIndex, Detail: "context"})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Context cleared for File %d", fileIndex),
+	})
 }
 
-func (h *Handler) processMinQuery(df *state.DataFrame, question string) QueryResponse {
-	numericCols := df.GetNumericColumnIndices()
-	results := []string{}
+// WipeAllData deletes everything the caller's namespace has stored: analyses,
+// contexts, annotations and templates (from memory and from disk), legacy
+// uploads (dataframes and the uploaded files) and contexts, background jobs
+// with their results, and cached similarity graphs. The request must carry
+// X-Confirm-Wipe: true.
+func (h *Handler) WipeAllData(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Confirm-Wipe") != "true" {
+		h.httpError(w, r, "Set the X-Confirm-Wipe: true header to delete all stored data", http.StatusBadRequest)
+		return
+	}
 
-	for colIdx := range numericCols {
-		if colIdx >= len(df.Headers) {
-			continue
-		}
-		colName := df.Headers[colIdx]
-		minVal := math.Inf(1)
-		for _, row := range df.Rows {
-			if colIdx < len(row) {
-				if val, err := strconv.ParseFloat(row[colIdx], 64); err == nil {
-					if val < minVal {
-						minVal = val
-					}
-				}
-			}
-		}
-		if minVal != math.Inf(1) {
-			results = append(results, fmt.Sprintf("%s: %.2f", colName, minVal))
+	store := h.store(r)
+	analyses, contexts := len(store.AnalysisIndices()), len(store.GetAllContexts())
+	templates := len(store.TemplateNames())
+	if err := store.WipeAll(); err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error wiping data: %v", err), http.StatusInternalServerError, "err", err)
+		return
+	}
+	namespace := GetNamespace(r.Context())
+	legacyFiles, legacyContexts := h.legacyState(r).Wipe()
+	for _, path := range legacyFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			h.httpError(w, r, fmt.Sprintf("Error wiping data: %v", err), http.StatusInternalServerError, "err", err)
+			return
 		}
 	}
+	jobs := h.JobStore.DeleteNamespace(namespace)
+	graphs := h.GraphCache.Invalidate(namespace)
+	h.audit(r, AuditEntry{Operation: AuditDelete, Detail: "all"})
 
-	return QueryResponse{
-		Answer:      fmt.Sprintf("Minimum values:\n%s", strings.Join(results, "\n")),
-		Explanation: "Found minimum for numeric columns.",
-		Result:      strings.Join(results, "\n"),
-		ResultType:  "statistics",
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"wiped":                   true,
+		"analyses_deleted":        analyses,
+		"contexts_deleted":        contexts,
+		"templates_deleted":       templates,
+		"legacy_files_deleted":    len(legacyFiles),
+		"legacy_contexts_deleted": legacyContexts,
+		"jobs_deleted":            jobs,
+		"graphs_deleted":          graphs,
+	})
 }
 
-func (h *Handler) processOverviewQuery(df *state.DataFrame) QueryResponse {
-	numericCols := df.GetNumericColumnIndices()
+// DeleteAnalysisContext removes the stored context for a file (V2 store; the
+// legacy /context/{fileIndex} route is DeleteContext)
+func (h *Handler) DeleteAnalysisContext(w http.ResponseWriter, r *http.Request) {
+	h.deleteStored(w, r, h.store(r).DeleteContext, "Context")
+}
 
-	summary := fmt.Sprintf("📊 Dataset Overview:\n• Rows: %d\n• Columns: %d\n• Column names: %s\n\n",
-		len(df.Rows), len(df.Headers), strings.Join(df.Headers, ", "))
+// DeleteAnalysis removes the stored analysis for a file
+func (h *Handler) DeleteAnalysis(w http.ResponseWriter, r *http.Request) {
+	h.deleteStored(w, r, h.store(r).DeleteAnalysis, "Analysis")
+}
 
-	numericHeaders := []string{}
-	for colIdx := range numericCols {
-		if colIdx < len(df.Headers) {
-			numericHeaders = append(numericHeaders, df.Headers[colIdx])
-		}
-	}
+// InvalidateAnalysis evicts a stored analysis from the cache before its TTL runs out
+func (h *Handler) InvalidateAnalysis(w http.ResponseWriter, r *http.Request) {
+	h.deleteStored(w, r, h.store(r).Invalidate, "Analysis")
+}
 
-	if len(numericHeaders) > 0 {
-		summary += fmt.Sprintf("📈 Numeric columns: %s\n", strings.Join(numericHeaders, ", "))
+// ExportAnalysis downloads the per-column profile of a stored analysis as csv
+// (the default) or as a formatted xlsx spreadsheet
+func (h *Handler) ExportAnalysis(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
 	}
-
-	return QueryResponse{
-		Answer:      summary,
-		Explanation: "Generated overview of the dataset.",
-		ResultType:  "overview",
+	if format != "csv" && format != "xlsx" {
+		h.httpError(w, r, "format must be csv or xlsx", http.StatusBadRequest)
+		return
 	}
-}
 
-func (h *Handler) processTopQuery(df *state.DataFrame, question string) QueryResponse {
-	// Default to top 5
-	n := 5
-	if strings.Contains(question, "10") {
-		n = 10
-	} else if strings.Contains(question, "3") {
-		n = 3
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	if n > len(df.Rows) {
-		n = len(df.Rows)
+	stored := h.store(r).GetAnalysis(fileIndex)
+	if stored == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return
 	}
 
-	data := make([]map[string]interface{}, n)
-	for i := 0; i < n; i++ {
-		row := make(map[string]interface{})
-		for j, header := range df.Headers {
-			if j < len(df.Rows[i]) {
-				row[header] = df.Rows[i][j]
-			}
+	filename := fmt.Sprintf("analysis_file%d.%s", fileIndex, format)
+
+	if format == "xlsx" {
+		data, err := h.ExportService.GenerateAnalysisXLSX(stored)
+		if err != nil {
+			h.httpError(w, r, fmt.Sprintf("Error generating spreadsheet: %v", err), http.StatusInternalServerError, "err", err)
+			return
 		}
-		data[i] = row
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Write(data)
+		return
 	}
 
-	return QueryResponse{
-		Answer:      fmt.Sprintf("Top %d records from the dataset", n),
-		Explanation: fmt.Sprintf("Showing first %d rows.", n),
-		ResultData:  data,
-		ResultType:  "dataframe",
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.WriteAll(h.ExportService.AnalysisReportCSV(stored))
+	if err := cw.Error(); err != nil {
+		h.Logger.Error("writing analysis csv", "err", err, "request_id", GetRequestID(r.Context()))
 	}
 }
 
-// ============================================================================
-// Context Management
-// ============================================================================
-
-func (h *Handler) GenerateContextQuestions(w http.ResponseWriter, r *http.Request) {
-	df1 := state.State.GetDataFrame(1)
-	df2 := state.State.GetDataFrame(2)
-
-	if df1 == nil || df2 == nil {
-		http.Error(w, "Both files must be loaded to generate context questions", http.StatusBadRequest)
+// ExportAnalysisArrow returns the per-column analysis as an Arrow IPC file,
+// for tools such as pandas and R arrow that read it without parsing JSON
+func (h *Handler) ExportAnalysisArrow(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Generate questions for both files
-	analysis1 := h.analyzeDataFrame(df1)
-	analysis2 := h.analyzeDataFrame(df2)
+	stored := h.store(r).GetAnalysis(fileIndex)
+	if stored == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return
+	}
 
-	questions1 := h.QuestionGenerator.GenerateQuestions(analysis1, 1)
-	questions2 := h.QuestionGenerator.GenerateQuestions(analysis2, 2)
+	data, err := h.ExportService.GenerateAnalysisArrow(stored)
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error generating Arrow file: %v", err), http.StatusInternalServerError, "err", err)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("analysis_file%d.arrow", fileIndex)))
+	w.Header().Set("Content-Type", "application/vnd.apache.arrow.file")
+	w.Write(data)
+}
 
-	// Relationship questions
-	relationshipQuestions := []models.Question{
-		{
-			ID:       "rel_type",
-			Type:     models.QuestionTypeRelationships,
-			Text:     "How are these two datasets related?",
-			Options:  []string{"One-to-One", "One-to-Many", "Many-to-Many", "Hierarchical", "Temporal sequence", "Unknown"},
-			Required: false,
-		},
-		{
-			ID:       "rel_keys",
-			Type:     models.QuestionTypeCustomMappings,
-			Text:     "Which columns should be used to join these datasets?",
-			Options:  append(df1.Headers, df2.Headers...),
-			Required: false,
-		},
+// GetCorrelations returns the Pearson correlation matrix of a stored
+// analysis's numeric columns
+func (h *Handler) GetCorrelations(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	resp := models.QuestionsResponse{
-		Success: true,
-		Questions: map[string][]models.Question{
-			"file1_questions":        questions1,
-			"file2_questions":        questions2,
-			"relationship_questions": relationshipQuestions,
-		},
-		TotalQuestions: len(questions1) + len(questions2) + len(relationshipQuestions),
+	stored := h.store(r).GetAnalysis(fileIndex)
+	if stored == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return
 	}
 
+	columns, matrix := analysis.CorrelationMatrix(stored)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"columns": columns,
+		"matrix":  matrix,
+	})
 }
 
-func (h *Handler) analyzeDataFrame(df *state.DataFrame) models.DataAnalysisResult {
-	result := models.DataAnalysisResult{
-		NumRows:     len(df.Rows),
-		NumColumns:  len(df.Headers),
-		ColumnNames: df.Headers,
-		ColumnTypes: make(map[string]string),
+// GetSchemaFingerprint returns just the schema fingerprint of a stored
+// analysis, for cheap polling for schema changes
+func (h *Handler) GetSchemaFingerprint(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	numericCols := df.GetNumericColumnIndices()
-	for i, header := range df.Headers {
-		if numericCols[i] {
-			result.HasNumeric = true
-			headerLower := strings.ToLower(header)
-			if strings.Contains(headerLower, "id") || strings.Contains(headerLower, "key") {
-				result.PotentialIDs = append(result.PotentialIDs, header)
-			}
-			if strings.Contains(headerLower, "amount") || strings.Contains(headerLower, "price") {
-				result.PotentialAmounts = append(result.PotentialAmounts, header)
-			}
-			result.ColumnTypes[header] = "numeric"
-		} else if isDateColumn(df, i) {
-			result.HasDates = true
-			result.PotentialDates = append(result.PotentialDates, header)
-			result.ColumnTypes[header] = "date"
-		} else {
-			result.HasText = true
-			result.ColumnTypes[header] = "string"
-		}
+	stored := h.store(r).GetAnalysis(fileIndex)
+	if stored == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return
 	}
 
-	return result
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"file_index":         fileIndex,
+		"schema_fingerprint": schemaFingerprint(stored),
+	})
 }
 
-func (h *Handler) StoreContext(w http.ResponseWriter, r *http.Request) {
-	fileIndexStr := chi.URLParam(r, "fileIndex")
-	fileIndex, err := strconv.Atoi(fileIndexStr)
+// schemaFingerprint returns the fingerprint of an analysis, computing it for
+// analyses stored before fingerprints were recorded
+func schemaFingerprint(result *models.DataAnalysisResult) string {
+	if result.SchemaFingerprint != "" {
+		return result.SchemaFingerprint
+	}
+	return analysis.SchemaFingerprint(result.ColumnTypes)
+}
+
+// GetTopValues returns up to n (default 20) of a column's most frequent values
+// with their counts, as computed during analysis. Only categorical columns have
+// top values; other columns answer 404.
+func (h *Handler) GetTopValues(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
 	if err != nil {
-		http.Error(w, "Invalid file index", http.StatusBadRequest)
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// chi routes on the escaped path when it differs from the decoded one,
+	// e.g. for a name containing "/"
+	columnName := chi.URLParam(r, "columnName")
+	if r.URL.RawPath != "" {
+		if columnName, err = url.PathUnescape(columnName); err != nil {
+			h.httpError(w, r, "Invalid column name", http.StatusBadRequest)
+			return
+		}
+	}
+	n := getIntParam(r, "n", 20)
+	if n < 1 {
+		h.httpError(w, r, "n must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	stored := h.store(r).GetAnalysis(fileIndex)
+	if stored == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
 		return
 	}
-
-	var ctx models.Context
-	body, _ := io.ReadAll(r.Body)
-	if err := json.Unmarshal(body, &ctx); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	col := stored.Column(columnName)
+	if col == nil {
+		h.httpError(w, r, fmt.Sprintf("Column %q not found", columnName), http.StatusNotFound)
 		return
 	}
-
-	if err := h.ContextService.StoreContext(fileIndex, &ctx); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if len(col.TopValues) == 0 {
+		h.httpError(w, r, fmt.Sprintf("No top values stored for column %q: only low-cardinality columns have them", columnName), http.StatusNotFound)
 		return
 	}
 
+	// Stored most frequent first
+	top := col.TopValues[:min(n, len(col.TopValues))]
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"column":     col.Name,
+		"top_values": top,
+	})
 }
 
-func (h *Handler) SubmitContext(w http.ResponseWriter, r *http.Request) {
-	var req models.ContextSubmitRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+// AnnotateColumn stores a business definition for a column of a stored
+// analysis, e.g. {"column":"revenue","annotation":"Net revenue in USD"}
+func (h *Handler) AnnotateColumn(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if req.FileIndex != 1 && req.FileIndex != 2 {
-		http.Error(w, "file_index must be 1 or 2", http.StatusBadRequest)
+	var req struct {
+		Column     string `json:"column"`
+		Annotation string `json:"annotation"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Column == "" {
+		h.httpError(w, r, "Invalid JSON: column is required", http.StatusBadRequest)
 		return
 	}
 
-	// Convert context data to Context model
-	ctx := models.NewContext()
-	if purpose, ok := req.ContextData["dataset_purpose"].(string); ok {
-		ctx.DatasetPurpose = purpose
-	}
-	if domain, ok := req.ContextData["business_domain"].(string); ok {
-		ctx.BusinessDomain = domain
-	}
-	if entities, ok := req.ContextData["key_entities"].([]interface{}); ok {
-		for _, e := range entities {
-			if s, ok := e.(string); ok {
-				ctx.KeyEntities = append(ctx.KeyEntities, s)
-			}
-		}
-	}
-	if temporal, ok := req.ContextData["temporal_context"].(string); ok {
-		ctx.TemporalContext = temporal
-	}
-	if exclusions, ok := req.ContextData["exclusions"].([]interface{}); ok {
-		for _, e := range exclusions {
-			if s, ok := e.(string); ok {
-				ctx.Exclusions = append(ctx.Exclusions, s)
-			}
+	if err := h.store(r).AnnotateColumn(fileIndex, req.Column, req.Annotation); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			h.httpError(w, r, err.Error(), http.StatusNotFound)
+			return
 		}
+		h.httpError(w, r, err.Error(), http.StatusInternalServerError, "err", err)
+		return
 	}
-
-	state.State.SetContext(req.FileIndex, ctx)
+	h.audit(r, AuditEntry{Operation: AuditAnnotate, FileIndex: fileIndex, Detail: req.Column})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": fmt.Sprintf("Context stored for File %d", req.FileIndex),
-		"context": ctx,
+		"success":    true,
+		"column":     req.Column,
+		"annotation": req.Annotation,
 	})
 }
 
-func (h *Handler) GetContext(w http.ResponseWriter, r *http.Request) {
-	fileIndexStr := chi.URLParam(r, "fileIndex")
-	fileIndex, err := strconv.Atoi(fileIndexStr)
-	if err != nil || (fileIndex != 1 && fileIndex != 2) {
-		http.Error(w, "fileIndex must be 1 or 2", http.StatusBadRequest)
+// GetAnalysisDiff reports schema drift between two stored analyses, selected
+// by the file1 (before) and file2 (after) query params
+func (h *Handler) GetAnalysisDiff(w http.ResponseWriter, r *http.Request) {
+	file1, err := fileIndexParam(r, "file1", 1)
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file2, err := fileIndexParam(r, "file2", 2)
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ctx := state.State.GetContext(fileIndex)
-
-	resp := map[string]interface{}{
-		"success":     true,
-		"has_context": ctx != nil,
-		"context":     ctx,
+	store := h.store(r)
+	before := store.GetAnalysis(file1)
+	after := store.GetAnalysis(file2)
+	if before == nil || after == nil {
+		h.httpError(w, r, "Both files must be analyzed first", http.StatusNotFound)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(analysis.SchemaDiff(before, after))
 }
 
-// GetQuestions endpoint (My V2 impl)
-func (h *Handler) GetQuestions(w http.ResponseWriter, r *http.Request) {
-	fileIndexStr := chi.URLParam(r, "fileIndex")
-	fileIndex, err := strconv.Atoi(fileIndexStr)
+// GetIndexRecommendations suggests CREATE INDEX statements for the stored
+// analyses. Foreign key candidates come from the similarity graph between
+// the file1 and file2 query params (default 1 and 2); without both analyses
+// only high-cardinality columns are recommended.
+func (h *Handler) GetIndexRecommendations(w http.ResponseWriter, r *http.Request) {
+	file1, file2, err := graphFileParams(r)
 	if err != nil {
-		http.Error(w, "Invalid file index", http.StatusBadRequest)
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Retrieve analysis from storage
-	analysis := h.ContextService.GetAnalysis(fileIndex)
-	if analysis == nil {
-		http.Error(w, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+	store := h.store(r)
+	analyses := store.GetAllAnalyses()
+	if len(analyses) == 0 {
+		h.httpError(w, r, "No analyses found. Please upload and analyze a file first.", http.StatusNotFound)
 		return
 	}
 
-	questions := h.QuestionGenerator.GenerateQuestions(*analysis, fileIndex)
+	graph, err := h.SimilarityService.WithStore(store).GenerateGraphContext(r.Context(), file1, file2, service.DefaultSimilarityThreshold, service.AlgorithmStructural)
+	if err != nil && !errors.Is(err, service.ErrNotFound) {
+		h.httpError(w, r, fmt.Sprintf("Error generating graph: %v", err), http.StatusInternalServerError, "err", err, "file1", file1, "file2", file2)
+		return
+	}
 
+	recommender := &analysis.IndexRecommender{Graph: graph, Analyses: analyses}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(questions)
+	json.NewEncoder(w).Encode(recommender.Recommend())
 }
 
-func (h *Handler) DeleteContext(w http.ResponseWriter, r *http.Request) {
-	fileIndexStr := chi.URLParam(r, "fileIndex")
-	fileIndex, err := strconv.Atoi(fileIndexStr)
-	if err != nil || (fileIndex != 1 && fileIndex != 2) {
-		http.Error(w, "fileIndex must be 1 or 2", http.StatusBadRequest)
+// deleteStored parses the fileIndex URL param and runs del, mapping
+// service.ErrNotFound to 404
+func (h *Handler) deleteStored(w http.ResponseWriter, r *http.Request, del func(int) error, what string) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	state.State.ClearContext(&fileIndex)
+	if err := del(fileIndex); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			h.httpError(w, r, fmt.Sprintf("%s not found for File %d", what, fileIndex), http.StatusNotFound)
+			return
+		}
+		h.httpError(w, r, err.Error(), http.StatusInternalServerError, "err", err)
+		return
+	}
+	h.audit(r, AuditEntry{Operation: AuditDelete, FileIndex: fileIndex, Detail: strings.ToLower(what)})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": fmt.Sprintf("Context cleared for File %d", fileIndex),
+		"message": fmt.Sprintf("%s cleared for File %d", what, fileIndex),
 	})
 }
 
 // GetSimilarityGraph generates the correlation graph (My V2 impl)
+// Optional file1/file2 query params select the analyses to compare (default 1
+// and 2, and they must differ); threshold (0-1, default 0.7) drops weaker
+// column pairs; algorithm is structural (default), jaccard or cosine
 func (h *Handler) GetSimilarityGraph(w http.ResponseWriter, r *http.Request) {
-	graph, err := h.SimilarityService.GenerateGraph(1, 2)
+	file1, file2, err := graphFileParams(r)
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	threshold := service.DefaultSimilarityThreshold
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		threshold, err = strconv.ParseFloat(raw, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			h.httpError(w, r, "threshold must be a number between 0 and 1", http.StatusBadRequest)
+			return
+		}
+	}
+
+	algorithm, err := service.ParseSimilarityAlgorithm(r.URL.Query().Get("algorithm"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := service.GraphCacheKey{
+		Namespace: GetNamespace(r.Context()),
+		File1:     file1,
+		File2:     file2,
+		Threshold: threshold,
+		Algorithm: algorithm,
+	}
+	graph, hit, err := h.GraphCache.Graph(r.Context(), h.SimilarityService.WithStore(h.store(r)), key)
+	if errors.Is(err, service.ErrNotFound) {
+		h.httpError(w, r, fmt.Sprintf("%v. Please upload and analyze both files first.", err), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error generating graph: %v", err), http.StatusInternalServerError, "err", err, "file1", file1, "file2", file2)
+		return
+	}
+	// Tag the graph alone, so a cache hit and a miss share an ETag
+	if data, err := json.Marshal(graph); err == nil {
+		w.Header().Set("ETag", bodyETag(data))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		*models.SimilarityGraph
+		CacheHit bool `json:"cache_hit"`
+	}{graph, hit})
+}
+
+// maxInvalidateGraphCacheBytes bounds the body of InvalidateGraphCache
+const maxInvalidateGraphCacheBytes = 64 << 10
+
+// InvalidateGraphCache drops cached similarity graphs of the caller's
+// namespace that involve any of {"file_indices": [...]}; an empty or missing
+// list drops them all
+func (h *Handler) InvalidateGraphCache(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		FileIndices []int `json:"file_indices"`
+	}{FileIndices: []int{}}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInvalidateGraphCacheBytes))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error generating graph: %v", err), http.StatusInternalServerError)
+		h.httpError(w, r, "Request body too large", http.StatusRequestEntityTooLarge)
 		return
 	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, idx := range req.FileIndices {
+		if err := service.ValidateFileIndex(idx); err != nil {
+			h.httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	removed := h.GraphCache.Invalidate(GetNamespace(r.Context()), req.FileIndices...)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"file_indices": req.FileIndices,
+		"invalidated":  removed,
+	})
+}
+
+// GetFKCandidates lists column pairs across the stored analyses where most
+// distinct values of one column occur in the other, as likely foreign keys.
+// threshold (0-1, default 0.8) is the minimum containment.
+func (h *Handler) GetFKCandidates(w http.ResponseWriter, r *http.Request) {
+	threshold := service.DefaultFKContainmentThreshold
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		var err error
+		threshold, err = strconv.ParseFloat(raw, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			h.httpError(w, r, "threshold must be a number between 0 and 1", http.StatusBadRequest)
+			return
+		}
+	}
+
+	candidates := h.SimilarityService.WithStore(h.store(r)).FindFKCandidates(threshold)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(graph)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"threshold":  threshold,
+		"candidates": candidates,
+	})
 }
 
 func (h *Handler) GetContextStatus(w http.ResponseWriter, r *http.Request) {
-	ctx1 := state.State.GetContext(1)
-	ctx2 := state.State.GetContext(2)
+	ctx1 := h.legacyState(r).GetContext(1)
+	ctx2 := h.legacyState(r).GetContext(2)
 
 	resp := models.ContextStatusResponse{
 		File1: models.ContextStatusItem{
@@ -1840,7 +3834,7 @@ func (h *Handler) GetOllamaConfig(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) SaveOllamaConfig(w http.ResponseWriter, r *http.Request) {
 	var config models.OllamaConfig
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
@@ -1850,6 +3844,7 @@ func (h *Handler) SaveOllamaConfig(w http.ResponseWriter, r *http.Request) {
 	if config.Model != "" {
 		state.State.OllamaModel = config.Model
 	}
+	h.audit(r, AuditEntry{Operation: AuditConfig, Detail: "ollama"})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1881,12 +3876,12 @@ func (h *Handler) SubmitMatchFeedback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		h.httpError(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	if req.File1Column == "" || req.File2Column == "" {
-		http.Error(w, "file1_column and file2_column are required", http.StatusBadRequest)
+		h.httpError(w, r, "file1_column and file2_column are required", http.StatusBadRequest)
 		return
 	}
 
@@ -1906,9 +3901,10 @@ func (h *Handler) SubmitMatchFeedback(w http.ResponseWriter, r *http.Request) {
 
 	result, err := feedbackSystem.AddFeedback(entry)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error recording feedback: %v", err), http.StatusInternalServerError)
+		h.httpError(w, r, fmt.Sprintf("Error recording feedback: %v", err), http.StatusInternalServerError)
 		return
 	}
+	h.audit(r, AuditEntry{Operation: AuditFeedback, Detail: req.File1Column + " -> " + req.File2Column})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1929,14 +3925,33 @@ func (h *Handler) GetFeedbackStats(w http.ResponseWriter, r *http.Request) {
 
 // ExportSQL generates SQL from the graph
 func (h *Handler) ExportSQL(w http.ResponseWriter, r *http.Request) {
-	var graph models.SimilarityGraph
+	// The graph fields plus an optional "dialect" (default postgres),
+	// "if_not_exists" (default true) and "replace" (default false)
+	var req struct {
+		models.SimilarityGraph
+		Dialect     string `json:"dialect"`
+		IfNotExists *bool  `json:"if_not_exists"`
+		Replace     bool   `json:"replace"`
+	}
 	body, _ := io.ReadAll(r.Body)
-	if err := json.Unmarshal(body, &graph); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := json.Unmarshal(body, &req); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	dialect, err := service.ParseDialect(req.Dialect)
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	sql := h.ExportService.GenerateSQL(&graph)
+	opts := service.DefaultSQLOptions
+	if req.IfNotExists != nil {
+		opts.IfNotExists = *req.IfNotExists
+	}
+	opts.Replace = req.Replace
+
+	sql := h.ExportService.GenerateSQL(&req.SimilarityGraph, h.store(r).GetAllAnalyses(), dialect, opts)
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(sql))
@@ -1944,23 +3959,270 @@ func (h *Handler) ExportSQL(w http.ResponseWriter, r *http.Request) {
 
 // ExportPython generates Python script from the graph
 func (h *Handler) ExportPython(w http.ResponseWriter, r *http.Request) {
+	// The graph fields plus optional "anonymize_columns" to hash in the script
+	var req struct {
+		models.SimilarityGraph
+		AnonymizeColumns []string `json:"anonymize_columns"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &req); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	python := h.ExportService.GeneratePython(&req.SimilarityGraph, service.MaskConfig{AnonymizeColumns: req.AnonymizeColumns})
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(python))
+}
+
+// ExportPySpark generates a PySpark job from the graph and stored analyses
+func (h *Handler) ExportPySpark(w http.ResponseWriter, r *http.Request) {
+	var graph models.SimilarityGraph
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &graph); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	script := h.ExportService.GeneratePySpark(&graph, h.store(r).GetAllAnalyses())
+
+	w.Header().Set("Content-Type", "text/x-python")
+	w.Write([]byte(script))
+}
+
+// ExportSQLAlchemy generates SQLAlchemy ORM models for the files in the graph
+func (h *Handler) ExportSQLAlchemy(w http.ResponseWriter, r *http.Request) {
+	var graph models.SimilarityGraph
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &graph); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	source := h.ExportService.GenerateSQLAlchemy(&graph, h.store(r).GetAllAnalyses())
+
+	w.Header().Set("Content-Type", "text/x-python")
+	w.Write([]byte(source))
+}
+
+// ExportR generates an R script from the graph
+func (h *Handler) ExportR(w http.ResponseWriter, r *http.Request) {
 	var graph models.SimilarityGraph
 	body, _ := io.ReadAll(r.Body)
 	if err := json.Unmarshal(body, &graph); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	python := h.ExportService.GeneratePython(&graph)
+	script := h.ExportService.GenerateR(&graph)
 
 	w.Header().Set("Content-Type", "text/plain")
-	w.Write([]byte(python))
+	w.Write([]byte(script))
+}
+
+// ExportNotebook generates a Jupyter notebook from the graph as a download
+func (h *Handler) ExportNotebook(w http.ResponseWriter, r *http.Request) {
+	var graph models.SimilarityGraph
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &graph); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	notebook := h.ExportService.GenerateNotebook(&graph)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=analysis.ipynb")
+	w.Write([]byte(notebook))
+}
+
+// ExportGORM generates GORM model structs from the graph and stored analyses
+func (h *Handler) ExportGORM(w http.ResponseWriter, r *http.Request) {
+	var graph models.SimilarityGraph
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &graph); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	source := h.ExportService.GenerateGORM(&graph, h.store(r).GetAllAnalyses())
+
+	w.Header().Set("Content-Type", "text/x-go")
+	w.Write([]byte(source))
+}
+
+// ExportDBT generates a dbt schema.yml for the files in the graph
+func (h *Handler) ExportDBT(w http.ResponseWriter, r *http.Request) {
+	var graph models.SimilarityGraph
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &graph); err != nil {
+		h.httpError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	yaml := h.ExportService.GenerateDBTYAML(&graph, h.store(r).GetAllAnalyses())
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write([]byte(yaml))
+}
+
+// ExportJSONSchema returns a JSON Schema document for a stored analysis
+func (h *Handler) ExportJSONSchema(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	analysis := h.store(r).GetAnalysis(fileIndex)
+	if analysis == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return
+	}
+
+	schema, err := h.ExportService.GenerateJSONSchema(analysis)
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error generating schema: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(schema)
+}
+
+// ExportAvro returns an Avro record schema for a stored analysis
+func (h *Handler) ExportAvro(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	analysis := h.store(r).GetAnalysis(fileIndex)
+	if analysis == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return
+	}
+
+	schema, err := h.ExportService.GenerateAvro(analysis)
+	if err != nil {
+		h.httpError(w, r, fmt.Sprintf("Error generating schema: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(schema)
+}
+
+// ExportProto returns a proto3 message definition for a stored analysis
+func (h *Handler) ExportProto(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	analysis := h.store(r).GetAnalysis(fileIndex)
+	if analysis == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(h.ExportService.GenerateProto(analysis)))
+}
+
+// ExportTypeScript returns a TypeScript interface for a stored analysis
+func (h *Handler) ExportTypeScript(w http.ResponseWriter, r *http.Request) {
+	fileIndex, err := parseFileIndex(chi.URLParam(r, "fileIndex"))
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	analysis := h.store(r).GetAnalysis(fileIndex)
+	if analysis == nil {
+		h.httpError(w, r, "Analysis not found for this file. Please upload and analyze file first.", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/x-typescript")
+	w.Write([]byte(h.ExportService.GenerateTypeScript(analysis)))
 }
 
 // ============================================================================
 // Helpers
 // ============================================================================
 
+// sortedKeys returns the keys of a row map in deterministic order
+func sortedKeys(row map[string]interface{}) []string {
+	keys := make([]string, 0, len(row))
+	for k := range row {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseFileIndex parses a file index and checks it is within 1..service.MaxFileIndex
+func parseFileIndex(raw string) (int, error) {
+	fileIndex, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file_index %q: must be an integer between 1 and %d", raw, service.MaxFileIndex)
+	}
+	if err := service.ValidateFileIndex(fileIndex); err != nil {
+		return 0, err
+	}
+	return fileIndex, nil
+}
+
+// parseSampling parses the sampling and sample_size form fields of an upload
+func parseSampling(strategy, size string) (analysis.SamplingConfig, error) {
+	var cfg analysis.SamplingConfig
+	if strategy == "" && size == "" {
+		return cfg, nil
+	}
+
+	var err error
+	if cfg.Strategy, err = analysis.ParseSamplingStrategy(strategy); err != nil {
+		return cfg, err
+	}
+	if size != "" {
+		if cfg.Size, err = strconv.Atoi(size); err != nil || cfg.Size <= 0 {
+			return cfg, fmt.Errorf("invalid sample_size %q: must be a positive integer", size)
+		}
+	}
+	return cfg, nil
+}
+
+// fileIndexParam reads an optional file index query parameter
+func fileIndexParam(r *http.Request, name string, defaultVal int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return defaultVal, nil
+	}
+	return parseFileIndex(raw)
+}
+
+// graphFileParams reads the file1 and file2 query parameters of a similarity
+// graph (default 1 and 2). Node IDs are only unique per file, so the two
+// must differ.
+func graphFileParams(r *http.Request) (int, int, error) {
+	file1, err := fileIndexParam(r, "file1", 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	file2, err := fileIndexParam(r, "file2", 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	if file1 == file2 {
+		return 0, 0, fmt.Errorf("file1 and file2 must be different files")
+	}
+	return file1, file2, nil
+}
+
 func getIntParam(r *http.Request, name string, defaultVal int) int {
 	valStr := r.URL.Query().Get(name)
 	if valStr == "" {
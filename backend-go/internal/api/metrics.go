@@ -0,0 +1,104 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend-go/internal/models"
+	"backend-go/internal/service"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of instrumented HTTP handlers.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	responsesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_responses_total",
+		Help: "Responses from instrumented HTTP handlers by status code.",
+	}, []string{"handler", "code"})
+
+	dbConnected = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "db_connected",
+		Help: "Number of namespaces with a database connection open.",
+	})
+
+	analysisCached = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "analysis_cached_total",
+		Help: "Analysis results stored for later graph and export requests.",
+	})
+
+	uploadBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "upload_bytes",
+		Help:    "Size of uploaded files in bytes.",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 10), // 1KB .. 256MB
+	})
+
+	bytesAnalyzed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "analyzed_bytes_total",
+		Help: "Input bytes analyzed; estimated from row sizes for database tables.",
+	})
+
+	analysesRun = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "analyses_run_total",
+		Help: "Successful file and table analyses.",
+	})
+)
+
+// instrument records duration and status code metrics for a handler
+func instrument(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		requestDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		responsesTotal.WithLabelValues(name, strconv.Itoa(status)).Inc()
+	}
+}
+
+// storeAnalysis caches an analysis result and counts it. Failures (such as a
+// persistent store that cannot write) are logged; the analysis itself is still
+// returned to the client.
+func (h *Handler) storeAnalysis(store service.ContextStore, fileIndex int, result *models.DataAnalysisResult) {
+	if err := store.StoreAnalysis(fileIndex, result); err != nil {
+		h.Logger.Error("storing analysis", "err", err, "file_index", fileIndex)
+		return
+	}
+	analysisCached.Inc()
+}
+
+// recordAnalysis counts a successful analysis of size bytes
+func (h *Handler) recordAnalysis(size int64) {
+	h.TotalBytesAnalyzed.Add(size)
+	h.TotalAnalysesRun.Add(1)
+	bytesAnalyzed.Add(float64(size))
+	analysesRun.Inc()
+}
+
+// estimateRowBytes approximates the size of database rows as the length of
+// their values formatted as text
+func estimateRowBytes(rows []map[string]interface{}) int64 {
+	var size int64
+	for _, row := range rows {
+		for _, val := range row {
+			if val != nil {
+				size += int64(len(fmt.Sprint(val)))
+			}
+		}
+	}
+	return size
+}
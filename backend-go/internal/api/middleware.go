@@ -0,0 +1,256 @@
+package api
+
+import (
+	"backend-go/internal/service"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/http/httpguts"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestID reuses the caller's X-Request-ID or generates one, stores it in
+// the request context and echoes it on the response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID returns the request ID stored in ctx, or "" if there is none
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// httpError is http.Error with the request ID appended so failures can be
+// matched to log lines. Server errors are logged at Error level and client
+// errors at Warn; extra key/value args are added to the log record.
+func (h *Handler) httpError(w http.ResponseWriter, r *http.Request, msg string, code int, args ...any) {
+	id := GetRequestID(r.Context())
+
+	level := slog.LevelWarn
+	if code >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	args = append([]any{"status", code, "method", r.Method, "path", r.URL.Path, "request_id", id}, args...)
+	h.Logger.Log(r.Context(), level, msg, args...)
+
+	if id != "" {
+		msg = fmt.Sprintf("%s (request_id: %s)", msg, id)
+	}
+	http.Error(w, msg, code)
+}
+
+// logRequest logs every request at Debug level on entry
+func (h *Handler) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.Logger.Debug("request", "method", r.Method, "path", r.URL.Path, "request_id", GetRequestID(r.Context()))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// APIKey is an accepted X-API-Key value and the namespace its requests use
+type APIKey struct {
+	Namespace string
+	Key       string
+}
+
+type namespaceKey struct{}
+
+// AuthMiddleware rejects requests whose X-API-Key header is not one of keys and
+// stores the matching key's namespace in the request context. With no keys
+// configured every request is allowed through in the default namespace.
+func AuthMiddleware(keys []APIKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(keys) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := validAPIKey(keys, r.Header.Get("X-API-Key"))
+			if !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid api key"})
+				return
+			}
+			ctx := context.WithValue(r.Context(), namespaceKey{}, key.Namespace)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetNamespace returns the namespace stored in ctx by AuthMiddleware, or
+// service.DefaultNamespace if there is none
+func GetNamespace(ctx context.Context) string {
+	if namespace := apiKeyIdentity(ctx); namespace != "" {
+		return namespace
+	}
+	return service.DefaultNamespace
+}
+
+// apiKeyIdentity returns the namespace of the request's API key, or "" when
+// auth is disabled
+func apiKeyIdentity(ctx context.Context) string {
+	namespace, _ := ctx.Value(namespaceKey{}).(string)
+	return namespace
+}
+
+type storeKey struct{}
+
+// namespaceStore resolves the analysis store of the request's namespace and
+// stores it in the request context for store
+func (h *Handler) namespaceStore(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store, err := h.Contexts.For(GetNamespace(r.Context()))
+		if err != nil {
+			h.httpError(w, r, "Error opening analysis store", http.StatusInternalServerError, "err", err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), storeKey{}, store)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// store returns the analysis store of the request's namespace. Routes outside
+// namespaceStore, such as /health, see the default namespace.
+func (h *Handler) store(r *http.Request) *service.AnalysisCache {
+	if store, ok := r.Context().Value(storeKey{}).(*service.AnalysisCache); ok {
+		return store
+	}
+	store, _ := h.Contexts.For(service.DefaultNamespace) // Created by NewHandler
+	return store
+}
+
+// validAPIKey compares against every key in constant time, returning the match
+func validAPIKey(keys []APIKey, given string) (APIKey, bool) {
+	var match APIKey
+	valid := false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(key.Key), []byte(given)) == 1 {
+			match, valid = key, true
+		}
+	}
+	return match, valid && given != ""
+}
+
+// parseAPIKeys splits a comma-separated key list, ignoring blanks. An entry
+// "team-a:secret" puts the key's requests in namespace team-a; a bare key gets
+// a namespace derived from its hash, so the key itself never reaches disk.
+func parseAPIKeys(raw string) []APIKey {
+	keys := []APIKey{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if namespace, key, ok := strings.Cut(entry, ":"); ok && key != "" && service.ValidateNamespace(namespace) == nil {
+			keys = append(keys, APIKey{Namespace: namespace, Key: key})
+			continue
+		}
+		sum := sha256.Sum256([]byte(entry))
+		keys = append(keys, APIKey{Namespace: "key-" + hex.EncodeToString(sum[:6]), Key: entry})
+	}
+	return keys
+}
+
+// CORSMiddleware allows cross-origin requests from allowedOrigins ("*" allows
+// any origin) and answers preflight requests directly. Listed origins may send
+// credentials; the "*" wildcard cannot, as browsers refuse to combine them.
+func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowAll := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[origin] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+
+			if origin != "" && (allowAll || allowed[origin]) {
+				if allowAll {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+				w.Header().Set("Access-Control-Expose-Headers", "Link, "+RequestIDHeader)
+			}
+
+			// Preflight
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				if w.Header().Get("Access-Control-Allow-Origin") != "" {
+					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+					w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, X-API-Key, "+RequestIDHeader)
+					w.Header().Set("Access-Control-Max-Age", "300")
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// StaticHeadersMiddleware sets headers on every response, such as
+// Cache-Control or Strict-Transport-Security for deployments behind a CDN.
+// They are set before the handler runs, so a handler may still override one.
+func StaticHeadersMiddleware(headers map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(headers) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// parseExtraHeaders parses EXTRA_HEADERS, a JSON object of header names to
+// values; "" means none
+func parseExtraHeaders(raw string) (map[string]string, error) {
+	headers := map[string]string{}
+	if strings.TrimSpace(raw) == "" {
+		return headers, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &headers); err != nil {
+		return nil, fmt.Errorf("EXTRA_HEADERS must be a JSON object of header names to values: %w", err)
+	}
+	for name, value := range headers {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("EXTRA_HEADERS: invalid header name %q", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("EXTRA_HEADERS: invalid value for %s", name)
+		}
+	}
+	return headers, nil
+}
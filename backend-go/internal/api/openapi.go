@@ -0,0 +1,18 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes every route registered by RegisterRoutes. It is
+// hand-maintained: update openapi.json alongside route changes.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec serves the OpenAPI 3.0 document for the API
+func (h *Handler) OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
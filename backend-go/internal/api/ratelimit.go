@@ -0,0 +1,97 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Rate limit defaults for the expensive analysis endpoints
+const (
+	DefaultRateLimitRPS   = 2
+	DefaultRateLimitBurst = 10
+
+	rateLimiterIdleTTL = 10 * time.Minute
+)
+
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimitMiddleware limits each client IP to rps requests per second with
+// the given burst, answering 429 with Retry-After when exceeded. All routes
+// wrapped by the returned middleware share the same per-IP budget. Its
+// cleanup goroutine exits when the handler shuts down.
+func (h *Handler) rateLimitMiddleware(rps float64, burst int) func(http.Handler) http.Handler {
+	var (
+		limiters sync.Map // ip -> *ipLimiter
+		mu       sync.Mutex
+	)
+
+	// Forget clients that have been idle for a while
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.ctx.Done():
+				return
+			case now := <-ticker.C:
+				limiters.Range(func(key, val interface{}) bool {
+					mu.Lock()
+					idle := now.Sub(val.(*ipLimiter).lastSeen) > rateLimiterIdleTTL
+					mu.Unlock()
+					if idle {
+						limiters.Delete(key)
+					}
+					return true
+				})
+			}
+		}
+	}()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r)
+			val, _ := limiters.LoadOrStore(ip, &ipLimiter{limiter: rate.NewLimiter(rate.Limit(rps), burst)})
+			entry := val.(*ipLimiter)
+
+			now := time.Now()
+			mu.Lock()
+			entry.lastSeen = now
+			mu.Unlock()
+
+			reservation := entry.limiter.ReserveN(now, 1)
+			if !reservation.OK() {
+				w.Header().Set("Retry-After", "60")
+				h.httpError(w, r, "Too many requests", http.StatusTooManyRequests, "client_ip", ip)
+				return
+			}
+			if delay := reservation.DelayFrom(now); delay > 0 {
+				reservation.CancelAt(now)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				h.httpError(w, r, "Too many requests", http.StatusTooManyRequests, "client_ip", ip)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the request's remote IP without the port. This is the
+// socket peer unless the server trusts a proxy (TRUST_PROXY), in which case
+// middleware.RealIP has set it from X-Forwarded-For/X-Real-IP; client-supplied
+// headers are never trusted otherwise, so they cannot pick a fresh bucket.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
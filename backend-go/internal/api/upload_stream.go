@@ -0,0 +1,172 @@
+package api
+
+import (
+	"backend-go/internal/analysis"
+	"backend-go/internal/models"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxStreamFieldBytes bounds each non-file form field of a streamed upload
+const maxStreamFieldBytes = 64 << 10
+
+// analyzeFileStreaming handles AnalyzeFile with ?stream=true: the file part
+// is analyzed as it arrives instead of being saved to a temp file first, so
+// the upload is read once rather than written and read back. Form fields must
+// come before the file part. Only delimited text can be streamed; Excel and
+// NDJSON files, systematic sampling and async=true need the whole file.
+func (h *Handler) analyzeFileStreaming(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("async") == "true" {
+		h.httpError(w, r, "async=true cannot be combined with stream=true", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		h.httpError(w, r, "Error parsing form", http.StatusBadRequest, "err", err)
+		return
+	}
+
+	fields := make(map[string]string)
+	var part *multipart.Part
+	for {
+		part, err = mr.NextPart()
+		if err == io.EOF {
+			h.httpError(w, r, "Error retrieving file", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			h.streamUploadError(w, r, err)
+			return
+		}
+		if part.FormName() == "file" {
+			break
+		}
+		value, err := io.ReadAll(io.LimitReader(part, maxStreamFieldBytes))
+		if err != nil {
+			h.streamUploadError(w, r, err)
+			return
+		}
+		if _, seen := fields[part.FormName()]; !seen {
+			fields[part.FormName()] = string(value)
+		}
+	}
+
+	params, err := parseUploadParams(func(name string) string { return fields[name] })
+	if err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	header := &multipart.FileHeader{Filename: part.FileName(), Header: part.Header}
+	if err := checkStreamable(header, params.Options); err != nil {
+		h.httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	release, ok := h.acquireWorker(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	start := time.Now()
+	counter := &countingReader{r: part}
+	analysisResult, err := h.analyzeStream(r, counter, header, params.Options)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			h.streamUploadError(w, r, err)
+			return
+		}
+		h.httpError(w, r, fmt.Sprintf("Error analyzing file: %v", err), http.StatusInternalServerError, "err", err, "file", header.Filename)
+		return
+	}
+	elapsed := time.Since(start)
+	uploadBytes.Observe(float64(counter.n))
+
+	// Anything after the file arrived too late to be used
+	for {
+		next, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.streamUploadError(w, r, err)
+			return
+		}
+		if next.FormName() != "" {
+			h.httpError(w, r, "With stream=true, form fields must come before the file", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var bench *analysisBenchmark
+	if r.URL.Query().Get("benchmark") == "true" {
+		bench = newAnalysisBenchmark(analysisResult.NumRows, counter.n, elapsed)
+	}
+	h.finishUpload(w, r, analysisResult, header.Filename, params, bench)
+}
+
+// analyzeStream is analyzeSavedFile for a delimited upload read from body
+func (h *Handler) analyzeStream(r *http.Request, body *countingReader, header *multipart.FileHeader, opts analysis.CSVOptions) (models.DataAnalysisResult, error) {
+	ctx, span := tracer.Start(r.Context(), "analyzeUpload")
+	defer span.End()
+
+	if opts.Delimiter == 0 {
+		opts.Delimiter = analysis.DelimiterForFilename(header.Filename)
+	}
+	opts.MaxDecompressedBytes = h.maxDecompressedBytes()
+	analysisResult, err := h.CSVService.AnalyzeReader(ctx, body, opts)
+	if err != nil {
+		return models.DataAnalysisResult{}, err
+	}
+	analysisResult.FileName = header.Filename
+	h.recordAnalysis(body.n)
+	return analysisResult, nil
+}
+
+// checkStreamable rejects uploads that cannot be analyzed in one pass
+func checkStreamable(header *multipart.FileHeader, opts analysis.CSVOptions) error {
+	if isXLSXUpload(header) {
+		return errors.New("xlsx files cannot be streamed; upload without stream=true")
+	}
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case ".json", ".ndjson", ".jsonl":
+		return errors.New("ndjson files cannot be streamed; upload without stream=true")
+	}
+	if opts.Sampling.Strategy == models.SamplingSystematic {
+		return analysis.ErrSystematicStream
+	}
+	return nil
+}
+
+// streamUploadError reports a failure reading a streamed upload: 413 if it
+// went over MaxUploadBytes, otherwise 400
+func (h *Handler) streamUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		h.httpError(w, r, fmt.Sprintf("Upload exceeds the %d MB limit", h.MaxUploadBytes>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	h.httpError(w, r, "Error parsing form", http.StatusBadRequest, "err", err)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"backend-go/internal/analysis"
+	"backend-go/internal/service"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// AdminKeyHeader carries the admin API key (ADMIN_API_KEY) for admin-only routes
+const AdminKeyHeader = "X-Admin-Key"
+
+// warmupFile is one entry of a warmup request: a file on the server to analyze
+// and the file index to store it under
+type warmupFile struct {
+	Path      string `json:"path"`
+	FileIndex int    `json:"file_index"`
+}
+
+// warmupFailure reports a file that could not be warmed
+type warmupFailure struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// adminOnly rejects requests whose X-Admin-Key header does not match
+// h.AdminAPIKey. With no admin key configured, admin routes are disabled.
+func (h *Handler) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.AdminAPIKey == "" {
+			h.httpError(w, r, "Admin routes are disabled; set ADMIN_API_KEY to enable them", http.StatusForbidden)
+			return
+		}
+		given := r.Header.Get(AdminKeyHeader)
+		if subtle.ConstantTimeCompare([]byte(h.AdminAPIKey), []byte(given)) != 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid admin key"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Warmup analyzes files already on the server, such as those every deployment
+// needs, and stores them in the store of ?namespace= (default
+// service.DefaultNamespace) so the first requests for them need no upload.
+// Files are analyzed concurrently; failures are reported per file index
+// without aborting the rest.
+func (h *Handler) Warmup(w http.ResponseWriter, r *http.Request) {
+	var files []warmupFile
+	if err := json.NewDecoder(r.Body).Decode(&files); err != nil {
+		h.httpError(w, r, "Invalid JSON: expected [{\"path\":...,\"file_index\":...}]", http.StatusBadRequest)
+		return
+	}
+	if len(files) == 0 {
+		h.httpError(w, r, "No files to warm up", http.StatusBadRequest)
+		return
+	}
+	for _, f := range files {
+		if f.Path == "" {
+			h.httpError(w, r, "Every entry needs a path", http.StatusBadRequest)
+			return
+		}
+		if err := service.ValidateFileIndex(f.FileIndex); err != nil {
+			h.httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	namespace := r.URL.Query().Get("namespace")
+	if namespace != "" {
+		if err := service.ValidateNamespace(namespace); err != nil {
+			h.httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	store, err := h.Contexts.For(namespace)
+	if err != nil {
+		h.httpError(w, r, "Error opening analysis store", http.StatusInternalServerError, "err", err)
+		return
+	}
+
+	workers := h.MaxWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		mu     sync.Mutex
+		warmed = []int{}
+		failed = []warmupFailure{}
+	)
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for _, f := range files {
+		wg.Add(1)
+		go func(f warmupFile) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			header := &multipart.FileHeader{Filename: filepath.Base(f.Path)}
+			analysisResult, err := h.analyzeSavedFile(r.Context(), f.Path, header, analysis.CSVOptions{})
+			if err != nil {
+				h.Logger.Warn("warmup failed", "err", err, "path", f.Path, "file_index", f.FileIndex)
+				mu.Lock()
+				failed = append(failed, warmupFailure{Index: f.FileIndex, Error: warmupError(err)})
+				mu.Unlock()
+				return
+			}
+
+			h.storeAnalysis(store, f.FileIndex, &analysisResult)
+			h.audit(r, AuditEntry{Operation: AuditUpload, FileIndex: f.FileIndex, FileName: f.Path, Detail: "warmup"})
+			mu.Lock()
+			warmed = append(warmed, f.FileIndex)
+			mu.Unlock()
+		}(f)
+	}
+	wg.Wait()
+
+	sort.Ints(warmed)
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Index < failed[j].Index })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"warmed": warmed,
+		"failed": failed,
+	})
+}
+
+// warmupError describes why a file could not be warmed, with short messages
+// for the common file system errors
+func warmupError(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "file not found"
+	case errors.Is(err, fs.ErrPermission):
+		return "permission denied"
+	case errors.Is(err, os.ErrInvalid):
+		return "invalid path"
+	default:
+		return fmt.Sprintf("analysis failed: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of the webhook body, keyed by WEBHOOK_SECRET
+	SignatureHeader = "X-Signature"
+
+	webhookRetries     = 3 // Retries after the first failed delivery
+	webhookBaseBackoff = time.Second
+)
+
+// webhookClient refuses to connect to internal addresses at dial time, so a
+// hostname that passed validateWebhookURL cannot be rebound to one later.
+// Proxies are bypassed for the same reason.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return fmt.Errorf("webhook address %s is not public", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// webhookLookupTimeout bounds the DNS lookup done by validateWebhookURL
+const webhookLookupTimeout = 2 * time.Second
+
+// validateWebhookURL checks that a webhook URL is an absolute http(s) URL
+// whose host resolves only to public addresses, so analysis results cannot be
+// posted to loopback, link-local (cloud metadata) or private services
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook_url %q: must be an http or https URL", raw)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookLookupTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("invalid webhook_url %q: %v", raw, err)
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("invalid webhook_url %q: %s is not a public address", raw, addr.IP)
+		}
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip is a routable address outside the loopback,
+// link-local, private, multicast and unspecified ranges
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsPrivate() || ip.IsUnspecified())
+}
+
+// notifyWebhook delivers payload to webhookURL in the background. No-op when
+// webhookURL is empty.
+func (h *Handler) notifyWebhook(webhookURL string, payload any) {
+	if webhookURL == "" {
+		return
+	}
+	h.jobs.Add(1)
+	go func() {
+		defer h.jobs.Done()
+		h.deliverWebhook(webhookURL, payload)
+	}()
+}
+
+// deliverWebhook POSTs payload as JSON to webhookURL, signing it when a
+// secret is configured. Failed deliveries are retried with exponential
+// backoff (1s, 2s, 4s) until the handler shuts down.
+func (h *Handler) deliverWebhook(webhookURL string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		h.Logger.Error("encoding webhook payload", "err", err, "url", webhookURL)
+		return
+	}
+
+	backoff := webhookBaseBackoff
+	for attempt := 0; ; attempt++ {
+		err = h.postWebhook(webhookURL, body)
+		if err == nil {
+			h.Logger.Info("webhook delivered", "url", webhookURL, "attempt", attempt+1)
+			return
+		}
+		if attempt == webhookRetries {
+			h.Logger.Error("webhook delivery failed", "err", err, "url", webhookURL, "attempts", attempt+1)
+			return
+		}
+		h.Logger.Warn("webhook delivery failed, retrying", "err", err, "url", webhookURL, "attempt", attempt+1, "backoff", backoff)
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-h.ctx.Done():
+			h.Logger.Error("webhook delivery abandoned on shutdown", "url", webhookURL)
+			return
+		}
+	}
+}
+
+func (h *Handler) postWebhook(webhookURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(h.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
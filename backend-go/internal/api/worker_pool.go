@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Worker pool defaults for the analysis endpoints
+const (
+	DefaultAnalysisWorkers   = 4
+	DefaultWorkerWaitTimeout = 10 * time.Second
+
+	// workerRetryAfter is the Retry-After (seconds) sent when no worker frees up
+	workerRetryAfter = "5"
+)
+
+// errNoWorker is returned by tryWorker when no worker frees up in time
+var errNoWorker = errors.New("too many analyses in progress, try again shortly")
+
+// acquireWorker takes a slot of the analysis worker pool, waiting up to
+// WorkerWaitTimeout for one to free up. If none does it answers 503 with
+// Retry-After and returns false. Call release once the analysis is done.
+func (h *Handler) acquireWorker(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	release, err := h.tryWorker(r.Context())
+	if errors.Is(err, errNoWorker) {
+		w.Header().Set("Retry-After", workerRetryAfter)
+		h.httpError(w, r, "Too many analyses in progress, try again shortly", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if err != nil {
+		// The client went away; there is no one to answer
+		return nil, false
+	}
+	return release, true
+}
+
+// tryWorker takes a slot of the analysis worker pool, waiting up to
+// WorkerWaitTimeout for one to free up, for callers that report the failure
+// themselves, such as one file of a batch
+func (h *Handler) tryWorker(ctx context.Context) (release func(), err error) {
+	timer := time.NewTimer(h.WorkerWaitTimeout)
+	defer timer.Stop()
+
+	select {
+	case h.workerPool <- struct{}{}:
+		return h.releaseWorker, nil
+	case <-timer.C:
+		return nil, errNoWorker
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// waitForWorker takes a slot of the analysis worker pool for a background
+// job, waiting as long as it takes unless ctx is done first
+func (h *Handler) waitForWorker(ctx context.Context) (release func(), err error) {
+	select {
+	case h.workerPool <- struct{}{}:
+		return h.releaseWorker, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (h *Handler) releaseWorker() {
+	<-h.workerPool
+}
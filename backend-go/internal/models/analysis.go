@@ -0,0 +1,125 @@
+package models
+
+// ColumnType is the data type inferred for a column's values
+type ColumnType string
+
+const (
+	ColumnTypeInteger ColumnType = "integer"
+	ColumnTypeFloat   ColumnType = "float"
+	ColumnTypeDate    ColumnType = "date"
+	ColumnTypeBoolean ColumnType = "boolean"
+	ColumnTypeText    ColumnType = "text"
+)
+
+// SamplingStrategy selects which rows of a large input are analyzed
+type SamplingStrategy string
+
+const (
+	SamplingHead       SamplingStrategy = "head"       // The first rows
+	SamplingRandom     SamplingStrategy = "random"     // A uniform random sample (reservoir sampling)
+	SamplingSystematic SamplingStrategy = "systematic" // Every Nth row, spread over the whole input
+)
+
+// ColumnAnalysis holds the profile of a single column
+type ColumnAnalysis struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"` // At least one value is null or empty
+
+	// Type matched by at least 95% of non-null values; see analysis.InferType
+	InferredType ColumnType `json:"inferred_type"`
+
+	NullCount        int     `json:"null_count"`         // Values that are null (DB NULL, JSON null)
+	EmptyStringCount int     `json:"empty_string_count"` // Values that are ""; CSV has no separate null
+	NullPercent      float64 `json:"null_percent"`       // Share of rows that are null or empty, 0-100
+
+	// Character length range of non-empty values, string columns only
+	MinLength int `json:"min_length,omitempty"`
+	MaxLength int `json:"max_length,omitempty"`
+
+	// Up to the first 200 distinct non-empty values, used for value-overlap matching
+	SampleValues []string `json:"sample_values,omitempty"`
+
+	DistinctCount int          `json:"distinct_count"`
+	Cardinality   float64      `json:"cardinality"`          // DistinctCount / rows
+	TopValues     []ValueCount `json:"top_values,omitempty"` // Most frequent values, categorical columns only
+
+	// DistinctCount is 1; DominantValuePercent is the share of non-empty
+	// values, 0-100, held by the most common value
+	IsConstant           bool    `json:"is_constant"`
+	DominantValuePercent float64 `json:"dominant_value_percent"`
+
+	// Complete, unique and integer or UUID typed
+	IsPrimaryKeyCandidate bool `json:"is_primary_key_candidate"`
+
+	// Descriptive statistics, null unless InferredType is integer or float.
+	// Median is estimated from a sample of up to 10,000 values.
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+	Mean   *float64 `json:"mean"`
+	StdDev *float64 `json:"std_dev"`
+	Median *float64 `json:"median"`
+
+	// Distribution of a numeric column over 20 equal-width buckets between Min
+	// and Max, counted from the same sample of up to 10,000 values as Median
+	Histogram []HistogramBucket `json:"histogram,omitempty"`
+
+	// Values outside [Q1 - 1.5*IQR, Q3 + 1.5*IQR], with the quartiles taken
+	// from the same sample as Median, and up to 5 of them as examples
+	OutlierCount    int       `json:"outlier_count,omitempty"`
+	OutlierExamples []float64 `json:"outlier_examples,omitempty"`
+
+	// First 1,000 rows of a numeric column, null where missing; row-aligned
+	// across columns so they can be correlated. Internal input for the
+	// correlation matrix, so it is left out of responses and exports.
+	NumericSample []*float64 `json:"-"`
+
+	PIIFlags []string `json:"pii_flags,omitempty"` // Personal data patterns found: "email", "phone", "ssn"
+
+	DateFormat string `json:"date_format,omitempty"` // Go time layout matched by the values, date columns only
+
+	Annotation string `json:"annotation,omitempty"` // User-supplied business definition
+}
+
+// NearConstantPercent is the DominantValuePercent at which a column is
+// considered near-constant
+const NearConstantPercent = 99.0
+
+// NearConstant reports whether the column holds (almost) a single value
+func (c *ColumnAnalysis) NearConstant() bool {
+	return c.IsConstant || c.DominantValuePercent >= NearConstantPercent
+}
+
+// ValueCount is a column value and how many rows hold it
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// HistogramBucket counts the values in [LowerBound, UpperBound); the last
+// bucket of a histogram also includes its upper bound
+type HistogramBucket struct {
+	LowerBound float64 `json:"lower_bound"`
+	UpperBound float64 `json:"upper_bound"`
+	Count      int     `json:"count"`
+}
+
+// Column returns the analysis for the named column, or nil if it is not present
+func (a *DataAnalysisResult) Column(name string) *ColumnAnalysis {
+	for i := range a.ColumnProfiles {
+		if a.ColumnProfiles[i].Name == name {
+			return &a.ColumnProfiles[i]
+		}
+	}
+	return nil
+}
+
+// IsKeyColumn reports whether name is the inferred primary key or a primary
+// key candidate
+func (a *DataAnalysisResult) IsKeyColumn(name string) bool {
+	if name == a.InferredPrimaryKey {
+		return true
+	}
+	profile := a.Column(name)
+	return profile != nil && profile.IsPrimaryKeyCandidate
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // QuestionType constants
 const (
 	QuestionTypeDatasetPurpose  = "dataset_purpose"
@@ -10,20 +12,30 @@ const (
 	QuestionTypeRelationships   = "relationships"
 	QuestionTypeCustomMappings  = "custom_mappings"
 	QuestionTypeExclusions      = "exclusions"
+	QuestionTypeAnalytical      = "analytical" // A question to answer from the data, with a SQL hint
+)
+
+// Question difficulty levels
+const (
+	DifficultyEasy   = "easy"   // Simple aggregations and null checks
+	DifficultyMedium = "medium" // Filtering and grouping
+	DifficultyHard   = "hard"   // Joins and window functions
 )
 
 // Question represents a context collection question
 type Question struct {
-	ID       string                 `json:"id"`
-	Type     string                 `json:"type"`
-	Text     string                 `json:"text"`
-	Options  []string               `json:"options"`
-	Required bool                   `json:"required"`
-	Metadata map[string]interface{} `json:"metadata"`
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Text       string                 `json:"text"`
+	Options    []string               `json:"options"`
+	Required   bool                   `json:"required"`
+	Difficulty string                 `json:"difficulty,omitempty"` // Set on analytical questions
+	Metadata   map[string]interface{} `json:"metadata"`
 }
 
 // DataAnalysisResult holds analysis of a dataframe for question generation
 type DataAnalysisResult struct {
+	FileName         string            `json:"file_name,omitempty"` // Uploaded file or table name
 	NumRows          int               `json:"rows"`
 	NumColumns       int               `json:"columns"`
 	ColumnNames      []string          `json:"column_names"`
@@ -34,4 +46,20 @@ type DataAnalysisResult struct {
 	PotentialIDs     []string          `json:"potential_ids"`
 	PotentialDates   []string          `json:"potential_dates"`
 	PotentialAmounts []string          `json:"potential_amounts"`
+	ColumnProfiles   []ColumnAnalysis  `json:"column_profiles"`
+
+	InferredPrimaryKey string `json:"inferred_primary_key,omitempty"` // Most likely primary key column
+	ContainsPII        bool   `json:"contains_pii"`                   // Any column has PIIFlags
+	HasOutliers        bool   `json:"has_outliers"`                   // Any column has an OutlierCount
+
+	Sampling SamplingStrategy `json:"sampling,omitempty"` // Set when only a sample of the rows was analyzed
+
+	SchemaFingerprint string `json:"schema_fingerprint,omitempty"` // SHA-256 of the column names and types
+
+	DetectedDelimiter string `json:"detected_delimiter,omitempty"`  // Field delimiter of a delimited file
+	WasCompressed     bool   `json:"was_compressed,omitempty"`      // The file was gzip-compressed
+	BOMDetected       bool   `json:"bom_detected,omitempty"`        // A byte-order mark was stripped from the file
+	MalformedRowCount int    `json:"malformed_row_count,omitempty"` // Rows skipped because they could not be parsed
+
+	StoredAt *time.Time `json:"stored_at,omitempty"` // When the analysis was last stored; persisted so its TTL survives restarts
 }
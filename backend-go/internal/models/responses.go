@@ -14,6 +14,7 @@ type FileStatus struct {
 	Rows     int    `json:"rows"`
 	Columns  int    `json:"columns"`
 	Filename string `json:"filename,omitempty"`
+	Stale    bool   `json:"stale,omitempty"` // The file's stored analysis is older than the analysis TTL
 }
 
 // StatusResponse is returned by /status endpoint
@@ -19,7 +19,25 @@ type Edge struct {
 	Target     string  `json:"target"`
 	Value      float64 `json:"value"`
 	Similarity float64 `json:"similarity"`
+	Score      float64 `json:"score"` // Similarity normalized to 0-1
 	Type       string  `json:"type"`
+
+	// The factors that went into Similarity, so a match can be judged
+	MatchReasons []MatchReason `json:"match_reasons"`
+}
+
+// Kinds of MatchReason
+const (
+	MatchNameSimilarity = "name_similarity" // Edit distance between the column names
+	MatchValueOverlap   = "value_overlap"   // Shared distinct sample values
+	MatchTypeMatch      = "type_match"      // Same or compatible column types
+	MatchCustomMapping  = "custom_mapping"  // Mapped by the file's context
+)
+
+// MatchReason is one factor in an edge's similarity and its score (0-1)
+type MatchReason struct {
+	Kind  string  `json:"kind"`
+	Score float64 `json:"score"`
 }
 
 type Similarity struct {
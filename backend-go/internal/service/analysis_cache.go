@@ -0,0 +1,130 @@
+package service
+
+import (
+	"backend-go/internal/models"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultAnalysisTTL is how long a stored analysis is served before it is
+// considered stale
+const DefaultAnalysisTTL = time.Hour
+
+var _ ContextStore = (*AnalysisCache)(nil)
+
+// AnalysisCache wraps a ContextStore and expires analyses older than a TTL.
+// Stale analyses stay in the underlying store, so they are still listed by
+// AnalysisIndices and can be reported by Lookup, but GetAnalysis and
+// GetAllAnalyses no longer return them. Storing a new analysis refreshes the entry.
+type AnalysisCache struct {
+	ContextStore
+	ttl time.Duration
+
+	mu       sync.RWMutex
+	storedAt map[int]time.Time
+}
+
+// NewAnalysisCache wraps inner with the given TTL (DefaultAnalysisTTL if not
+// positive). Analyses already in inner, such as ones loaded from disk, keep
+// their StoredAt time; ones without it are treated as stored now.
+func NewAnalysisCache(inner ContextStore, ttl time.Duration) *AnalysisCache {
+	if ttl <= 0 {
+		ttl = DefaultAnalysisTTL
+	}
+
+	c := &AnalysisCache{ContextStore: inner, ttl: ttl, storedAt: make(map[int]time.Time)}
+	now := time.Now()
+	for _, idx := range inner.AnalysisIndices() {
+		c.storedAt[idx] = now
+		if analysis := inner.GetAnalysis(idx); analysis != nil && analysis.StoredAt != nil {
+			c.storedAt[idx] = *analysis.StoredAt
+		}
+	}
+	return c
+}
+
+// StoreAnalysis stamps the analysis with StoredAt, stores it and restarts its TTL
+func (c *AnalysisCache) StoreAnalysis(fileIndex int, analysis *models.DataAnalysisResult) error {
+	now := time.Now()
+	analysis.StoredAt = &now
+	if err := c.ContextStore.StoreAnalysis(fileIndex, analysis); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.storedAt[fileIndex] = now
+	c.mu.Unlock()
+	return nil
+}
+
+// GetAnalysis returns the stored analysis, or nil if there is none or it is stale
+func (c *AnalysisCache) GetAnalysis(fileIndex int) *models.DataAnalysisResult {
+	if c.IsStale(fileIndex) {
+		return nil
+	}
+	return c.ContextStore.GetAnalysis(fileIndex)
+}
+
+// Lookup returns the stored analysis even when it is stale, reporting whether it is
+func (c *AnalysisCache) Lookup(fileIndex int) (*models.DataAnalysisResult, bool) {
+	return c.ContextStore.GetAnalysis(fileIndex), c.IsStale(fileIndex)
+}
+
+// GetAllAnalyses returns every analysis that is not stale
+func (c *AnalysisCache) GetAllAnalyses() map[int]*models.DataAnalysisResult {
+	analyses := c.ContextStore.GetAllAnalyses()
+	for idx := range analyses {
+		if c.IsStale(idx) {
+			delete(analyses, idx)
+		}
+	}
+	return analyses
+}
+
+// AnnotateColumn annotates a column of a fresh analysis; stale ones count as missing
+func (c *AnalysisCache) AnnotateColumn(fileIndex int, column, annotation string) error {
+	if c.IsStale(fileIndex) {
+		return fmt.Errorf("analysis for file %d: %w", fileIndex, ErrNotFound)
+	}
+	return c.ContextStore.AnnotateColumn(fileIndex, column, annotation)
+}
+
+// DeleteAnalysis removes the analysis from the underlying store
+func (c *AnalysisCache) DeleteAnalysis(fileIndex int) error {
+	if err := c.ContextStore.DeleteAnalysis(fileIndex); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	delete(c.storedAt, fileIndex)
+	c.mu.Unlock()
+	return nil
+}
+
+// WipeAll removes everything from the underlying store and forgets every TTL
+func (c *AnalysisCache) WipeAll() error {
+	if err := c.ContextStore.WipeAll(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.storedAt = make(map[int]time.Time)
+	c.mu.Unlock()
+	return nil
+}
+
+// Invalidate evicts an analysis, stale or not, so the next request has to
+// analyze the data again
+func (c *AnalysisCache) Invalidate(fileIndex int) error {
+	return c.DeleteAnalysis(fileIndex)
+}
+
+// IsStale reports whether the analysis at fileIndex is older than the TTL
+func (c *AnalysisCache) IsStale(fileIndex int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	storedAt, ok := c.storedAt[fileIndex]
+	return ok && time.Since(storedAt) > c.ttl
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// bigQueryTimeout bounds each BigQuery call. DataSource methods carry no
+// context of their own, so every call derives one with this deadline.
+const bigQueryTimeout = 2 * time.Minute
+
+// bigQueryNamePart matches the project, dataset and table names that are put
+// inside backticks; anything else could close the quoted path early
+var bigQueryNamePart = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// BigQueryDataSource implements DataSource for one BigQuery dataset, named by
+// ProjectID and DatasetID. Credentials come from Application Default
+// Credentials (GOOGLE_APPLICATION_CREDENTIALS, gcloud auth or the metadata server).
+type BigQueryDataSource struct {
+	client  *bigquery.Client
+	dataset *bigquery.Dataset
+}
+
+func (b *BigQueryDataSource) Connect(config DataSourceConfig) error {
+	if config.ProjectID == "" || config.DatasetID == "" {
+		return fmt.Errorf("bigquery requires projectid and datasetid")
+	}
+
+	// The client keeps using this context for token refreshes, so it must not
+	// be cancelled when Connect returns
+	client, err := bigquery.NewClient(context.Background(), config.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bigQueryTimeout)
+	defer cancel()
+
+	dataset := client.Dataset(config.DatasetID)
+	if _, err := dataset.Metadata(ctx); err != nil {
+		client.Close()
+		return err
+	}
+
+	b.client = client
+	b.dataset = dataset
+	return nil
+}
+
+func (b *BigQueryDataSource) Close() error {
+	if b.client != nil {
+		return b.client.Close()
+	}
+	return nil
+}
+
+// Ping fetches the dataset metadata, which needs working credentials and access
+func (b *BigQueryDataSource) Ping() error {
+	if b.client == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), postgresPingTimeout)
+	defer cancel()
+
+	_, err := b.dataset.Metadata(ctx)
+	return err
+}
+
+// ListTables returns the ID of every table and view in the dataset
+func (b *BigQueryDataSource) ListTables() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bigQueryTimeout)
+	defer cancel()
+
+	tables := []string{}
+	it := b.dataset.Tables(ctx)
+	for {
+		t, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, t.TableID)
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+// ListTablesPaged returns a page of table names plus the total table count.
+// The BigQuery API pages by token rather than offset, so paging is done here.
+func (b *BigQueryDataSource) ListTablesPaged(limit, offset int) ([]string, int, error) {
+	tables, err := b.ListTables()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(tables)
+	tables = tables[min(offset, total):]
+	if limit > 0 && limit < len(tables) {
+		tables = tables[:limit]
+	}
+	return tables, total, nil
+}
+
+// ListTablesWithSchema returns every base table with the row count, column
+// count and description from its metadata
+func (b *BigQueryDataSource) ListTablesWithSchema() ([]TableInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bigQueryTimeout)
+	defer cancel()
+
+	tables := []TableInfo{}
+	it := b.dataset.Tables(ctx)
+	for {
+		t, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		md, err := t.Metadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if md.Type != bigquery.RegularTable {
+			continue
+		}
+		tables = append(tables, TableInfo{
+			Name:        t.TableID,
+			RowCount:    int64(md.NumRows),
+			ColumnCount: len(md.Schema),
+			Comment:     md.Description,
+		})
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables, nil
+}
+
+// ListViews returns the names of the views in the dataset
+func (b *BigQueryDataSource) ListViews() ([]string, error) {
+	path, err := b.tablePath()
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT table_name FROM %s.INFORMATION_SCHEMA.VIEWS ORDER BY table_name", path)
+
+	_, rows, err := b.query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]string, 0, len(rows))
+	for _, row := range rows {
+		views = append(views, fmt.Sprint(row["table_name"]))
+	}
+	return views, nil
+}
+
+func (b *BigQueryDataSource) PreviewData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	path, err := b.tablePath(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.query(fmt.Sprintf("SELECT * FROM %s LIMIT %d", path, limit))
+}
+
+// SampleData returns limit rows chosen at random from the whole table
+func (b *BigQueryDataSource) SampleData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	path, err := b.tablePath(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.query(fmt.Sprintf("SELECT * FROM %s ORDER BY RAND() LIMIT %d", path, limit))
+}
+
+// tablePath builds the backtick-quoted `project.dataset[.name...]` path,
+// rejecting any part that is not a plain BigQuery name
+func (b *BigQueryDataSource) tablePath(names ...string) (string, error) {
+	if b.dataset == nil {
+		return "", fmt.Errorf("not connected")
+	}
+
+	parts := append([]string{b.dataset.ProjectID, b.dataset.DatasetID}, names...)
+	for _, part := range parts {
+		if !bigQueryNamePart.MatchString(part) {
+			return "", fmt.Errorf("invalid BigQuery name %q", part)
+		}
+	}
+	return "`" + strings.Join(parts, ".") + "`", nil
+}
+
+// QueryRaw dry-runs query first and refuses anything BigQuery does not
+// classify as a SELECT, since jobs cannot be made read-only
+func (b *BigQueryDataSource) QueryRaw(query string, limit int) ([]string, []map[string]interface{}, error) {
+	if err := b.requireSelect(query); err != nil {
+		return nil, nil, err
+	}
+	// On separate lines so a trailing -- comment cannot swallow the LIMIT
+	return b.query(fmt.Sprintf("SELECT * FROM (\n%s\n) LIMIT %d", query, limit))
+}
+
+// requireSelect dry-runs sql and returns an error unless its statement type
+// is SELECT
+func (b *BigQueryDataSource) requireSelect(sql string) error {
+	if b.client == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bigQueryTimeout)
+	defer cancel()
+
+	q := b.client.Query(sql)
+	q.DefaultProjectID = b.dataset.ProjectID
+	q.DefaultDatasetID = b.dataset.DatasetID
+	q.DryRun = true
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		return err
+	}
+	status := job.LastStatus()
+	if err := status.Err(); err != nil {
+		return err
+	}
+	if status.Statistics == nil {
+		return fmt.Errorf("dry run returned no statement type")
+	}
+	stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics)
+	if !ok || stats.StatementType != "SELECT" {
+		return fmt.Errorf("only SELECT queries are allowed")
+	}
+	return nil
+}
+
+// query runs a job with the dataset as the default for unqualified table
+// names and converts the rows to column->value maps in schema order
+func (b *BigQueryDataSource) query(sql string) ([]string, []map[string]interface{}, error) {
+	if b.client == nil {
+		return nil, nil, fmt.Errorf("not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bigQueryTimeout)
+	defer cancel()
+
+	q := b.client.Query(sql)
+	q.DefaultProjectID = b.dataset.ProjectID
+	q.DefaultDatasetID = b.dataset.DatasetID
+
+	it, err := q.Read(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var columns []string
+	var result []map[string]interface{}
+	for {
+		var values []bigquery.Value
+		err := it.Next(&values)
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The schema is only populated once the first page has been fetched
+		if columns == nil {
+			for _, field := range it.Schema {
+				columns = append(columns, field.Name)
+			}
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			row[col] = bigQueryValue(values[i])
+		}
+		result = append(result, row)
+	}
+	return columns, result, nil
+}
+
+// bigQueryValue converts the client's value types to the plain types the
+// analysis expects from database rows
+func bigQueryValue(v bigquery.Value) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case *big.Rat:
+		f, _ := val.Float64()
+		return f
+	case fmt.Stringer:
+		// civil.Date, civil.DateTime and civil.Time
+		return val.String()
+	default:
+		return val
+	}
+}
+
+// DescribeSchema returns the columns and primary key of every base table from
+// the table metadata, with types in GoogleSQL DDL form
+func (b *BigQueryDataSource) DescribeSchema() (*DatabaseSchema, error) {
+	if b.client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bigQueryTimeout)
+	defer cancel()
+
+	schema := &DatabaseSchema{Dialect: "bigquery", Tables: []SchemaTable{}}
+	it := b.dataset.Tables(ctx)
+	for {
+		t, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		md, err := t.Metadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if md.Type != bigquery.RegularTable {
+			continue
+		}
+
+		table := SchemaTable{Name: t.TableID}
+		for _, field := range md.Schema {
+			table.Columns = append(table.Columns, SchemaColumn{
+				Name:     field.Name,
+				DataType: bigQueryDDLType(field),
+				Nullable: !field.Required,
+				Default:  field.DefaultValueExpression,
+			})
+		}
+		if md.TableConstraints != nil && md.TableConstraints.PrimaryKey != nil {
+			table.PrimaryKey = md.TableConstraints.PrimaryKey.Columns
+		}
+		schema.Tables = append(schema.Tables, table)
+	}
+
+	sort.Slice(schema.Tables, func(i, j int) bool { return schema.Tables[i].Name < schema.Tables[j].Name })
+	return schema, nil
+}
+
+func (b *BigQueryDataSource) ExportSchema() (string, error) {
+	return exportSchema(b)
+}
+
+// bigQueryDDLType spells a field's type as CREATE TABLE expects it; the API
+// uses legacy names such as INTEGER and RECORD
+func bigQueryDDLType(field *bigquery.FieldSchema) string {
+	var t string
+	switch field.Type {
+	case bigquery.IntegerFieldType:
+		t = "INT64"
+	case bigquery.FloatFieldType:
+		t = "FLOAT64"
+	case bigquery.BooleanFieldType:
+		t = "BOOL"
+	case bigquery.RecordFieldType:
+		fields := make([]string, len(field.Schema))
+		for i, sub := range field.Schema {
+			fields[i] = dialectIdent(sub.Name, DialectBigQuery) + " " + bigQueryDDLType(sub)
+		}
+		t = "STRUCT<" + strings.Join(fields, ", ") + ">"
+	case bigquery.RangeFieldType:
+		t = "RANGE"
+		if field.RangeElementType != nil {
+			t += "<" + string(field.RangeElementType.Type) + ">"
+		}
+	default:
+		t = string(field.Type)
+	}
+
+	if field.Repeated {
+		return "ARRAY<" + t + ">"
+	}
+	return t
+}
@@ -2,93 +2,273 @@ package service
 
 import (
 	"backend-go/internal/models"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+// MaxFileIndex bounds file indices so clients cannot grow the in-memory
+// stores without limit
+const MaxFileIndex = 1000
+
+// ValidateFileIndex checks that a file index is within 1..MaxFileIndex
+func ValidateFileIndex(fileIndex int) error {
+	if fileIndex < 1 || fileIndex > MaxFileIndex {
+		return fmt.Errorf("invalid file_index %d: must be between 1 and %d", fileIndex, MaxFileIndex)
+	}
+	return nil
+}
+
+// ErrNotFound is returned when nothing is stored for a file index
+var ErrNotFound = errors.New("not found")
+
+// ContextService holds context and analysis results keyed by file index
 type ContextService struct {
-	File1Context  *models.Context
-	File2Context  *models.Context
-	File1Analysis *models.DataAnalysisResult
-	File2Analysis *models.DataAnalysisResult
+	mu       sync.RWMutex
+	contexts map[int]*models.Context
+	analyses map[int]*models.DataAnalysisResult
+
+	// Templates are named contexts that can be instantiated for any file;
+	// guarded by mu, see StoreTemplate and GetTemplate
+	Templates map[string]*models.Context
 }
 
 func NewContextService() *ContextService {
-	return &ContextService{}
+	return &ContextService{
+		contexts:  make(map[int]*models.Context),
+		analyses:  make(map[int]*models.DataAnalysisResult),
+		Templates: make(map[string]*models.Context),
+	}
 }
 
 func (s *ContextService) ValidateContext(ctx *models.Context) bool {
+	return CheckContext(ctx, nil) == nil
+}
+
+// ContextFieldError is one invalid field of a context, named by its JSON key
+type ContextFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ContextValidationError lists every invalid field of a context
+type ContextValidationError struct {
+	Errors []ContextFieldError `json:"errors"`
+}
+
+func (e *ContextValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "invalid context: " + strings.Join(msgs, "; ")
+}
+
+// CheckContext validates a context before it is stored: dataset_purpose and
+// business_domain are required, list fields must not repeat an entry, and
+// column descriptions, custom mapping sources and exclusions must name columns
+// of analysis. Column references are not checked when analysis is nil.
+// It returns nil if the context is valid.
+func CheckContext(ctx *models.Context, analysis *models.DataAnalysisResult) *ContextValidationError {
 	if ctx == nil {
-		return false
+		return &ContextValidationError{Errors: []ContextFieldError{{Field: "context", Message: "required"}}}
+	}
+
+	var errs []ContextFieldError
+	required := func(field, value string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, ContextFieldError{Field: field, Message: "required"})
+		}
+	}
+	required("dataset_purpose", ctx.DatasetPurpose)
+	required("business_domain", ctx.BusinessDomain)
+
+	noDuplicates := func(field string, values []string) {
+		seen := make(map[string]bool, len(values))
+		for _, v := range values {
+			if seen[v] {
+				errs = append(errs, ContextFieldError{Field: field, Message: fmt.Sprintf("duplicate entry %q", v)})
+			}
+			seen[v] = true
+		}
+	}
+	noDuplicates("key_entities", ctx.KeyEntities)
+	noDuplicates("relationships", ctx.Relationships)
+	noDuplicates("exclusions", ctx.Exclusions)
+
+	if analysis != nil {
+		columns := columnSet(analysis)
+		for _, ref := range contextColumnRefs(ctx) {
+			if !columns[ref.Column] {
+				errs = append(errs, ContextFieldError{Field: ref.Field, Message: fmt.Sprintf("unknown column %q", ref.Column)})
+			}
+		}
 	}
-	return ctx.DatasetPurpose != "" && ctx.BusinessDomain != ""
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ContextValidationError{Errors: errs}
+}
+
+// contextColumnRef is a column named by a field of a context
+type contextColumnRef struct {
+	Field  string
+	Column string
+}
+
+// contextColumnRefs returns the columns a context refers to: column
+// descriptions, custom mapping sources and exclusions, in a stable order
+func contextColumnRefs(ctx *models.Context) []contextColumnRef {
+	var refs []contextColumnRef
+	for _, column := range sortedKeys(ctx.ColumnDescriptions) {
+		refs = append(refs, contextColumnRef{"column_descriptions", column})
+	}
+	for _, column := range sortedKeys(ctx.CustomMappings) {
+		refs = append(refs, contextColumnRef{"custom_mappings", column})
+	}
+	for _, column := range ctx.Exclusions {
+		refs = append(refs, contextColumnRef{"exclusions", column})
+	}
+	return refs
+}
+
+// columnSet returns the column names of analysis as a set
+func columnSet(analysis *models.DataAnalysisResult) map[string]bool {
+	columns := make(map[string]bool, len(analysis.ColumnNames))
+	for _, name := range analysis.ColumnNames {
+		columns[name] = true
+	}
+	return columns
+}
+
+// sortedKeys returns the keys of m in order, so errors are reported stably
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ContextColumnCheck is the result of CheckContextColumns
+type ContextColumnCheck struct {
+	Valid          bool     `json:"valid"`
+	MissingColumns []string `json:"missing_columns,omitempty"` // Referenced by the context but not in the analysis
+	ExtraColumns   []string `json:"extra_columns,omitempty"`   // In the analysis but not referenced by the context
+}
+
+// CheckContextColumns compares the columns a stored context refers to (column
+// descriptions, custom mapping sources and exclusions) with the columns of
+// analysis, which may have changed since the context was stored. The context
+// is valid when every reference exists. Unreferenced columns are normal, so
+// they are only listed for an invalid context, as candidates for what the
+// missing columns were renamed to.
+func CheckContextColumns(ctx *models.Context, analysis *models.DataAnalysisResult) ContextColumnCheck {
+	columns := columnSet(analysis)
+
+	referenced := make(map[string]bool)
+	for _, ref := range contextColumnRefs(ctx) {
+		referenced[ref.Column] = true
+	}
+
+	var check ContextColumnCheck
+	for column := range referenced {
+		if !columns[column] {
+			check.MissingColumns = append(check.MissingColumns, column)
+		}
+	}
+	if len(check.MissingColumns) == 0 {
+		check.Valid = true
+		return check
+	}
+	sort.Strings(check.MissingColumns)
+
+	for _, name := range analysis.ColumnNames {
+		if !referenced[name] {
+			check.ExtraColumns = append(check.ExtraColumns, name)
+		}
+	}
+	return check
 }
 
 func (s *ContextService) MergeContext(existing *models.Context, newCtx *models.Context) *models.Context {
+	return mergeContext(existing, newCtx)
+}
+
+// mergeContext returns a copy of existing with the fields set in newCtx
+// applied, appending to its lists and maps (or newCtx if existing is nil).
+// existing is left unchanged, since it may be shared with readers.
+func mergeContext(existing *models.Context, newCtx *models.Context) *models.Context {
 	if existing == nil {
 		return newCtx
 	}
+	merged := *existing
+	merged.KeyEntities = slices.Clone(existing.KeyEntities)
+	merged.ColumnDescriptions = maps.Clone(existing.ColumnDescriptions)
+	merged.Relationships = slices.Clone(existing.Relationships)
+	merged.CustomMappings = maps.Clone(existing.CustomMappings)
+	merged.Exclusions = slices.Clone(existing.Exclusions)
+	if merged.ColumnDescriptions == nil {
+		merged.ColumnDescriptions = make(map[string]string)
+	}
+	if merged.CustomMappings == nil {
+		merged.CustomMappings = make(map[string]string)
+	}
+
 	// Copy simple fields if new ones are present
 	if newCtx.DatasetPurpose != "" {
-		existing.DatasetPurpose = newCtx.DatasetPurpose
+		merged.DatasetPurpose = newCtx.DatasetPurpose
 	}
 	if newCtx.BusinessDomain != "" {
-		existing.BusinessDomain = newCtx.BusinessDomain
+		merged.BusinessDomain = newCtx.BusinessDomain
 	}
 	if newCtx.TemporalContext != "" {
-		existing.TemporalContext = newCtx.TemporalContext
+		merged.TemporalContext = newCtx.TemporalContext
 	}
 
 	// Merge slices and maps
 	if len(newCtx.KeyEntities) > 0 {
-		existing.KeyEntities = append(existing.KeyEntities, newCtx.KeyEntities...)
-		existing.KeyEntities = uniqueStrings(existing.KeyEntities)
+		merged.KeyEntities = uniqueStrings(append(merged.KeyEntities, newCtx.KeyEntities...))
 	}
 	// Note: For maps, just taking the new keys. A deeper merge strategy could be applied if needed.
-	for k, v := range newCtx.ColumnDescriptions {
-		existing.ColumnDescriptions[k] = v
-	}
+	maps.Copy(merged.ColumnDescriptions, newCtx.ColumnDescriptions)
 	if len(newCtx.Relationships) > 0 {
-		existing.Relationships = append(existing.Relationships, newCtx.Relationships...)
-		existing.Relationships = uniqueStrings(existing.Relationships)
-	}
-	for k, v := range newCtx.CustomMappings {
-		existing.CustomMappings[k] = v
+		merged.Relationships = uniqueStrings(append(merged.Relationships, newCtx.Relationships...))
 	}
+	maps.Copy(merged.CustomMappings, newCtx.CustomMappings)
 	if len(newCtx.Exclusions) > 0 {
-		existing.Exclusions = append(existing.Exclusions, newCtx.Exclusions...)
-		existing.Exclusions = uniqueStrings(existing.Exclusions)
+		merged.Exclusions = uniqueStrings(append(merged.Exclusions, newCtx.Exclusions...))
 	}
 
-	existing.UpdatedAt = time.Now().Format(time.RFC3339)
-	return existing
+	merged.UpdatedAt = time.Now().Format(time.RFC3339)
+	return &merged
 }
 
 func (s *ContextService) BuildContextPrompt() string {
-	if s.File1Context == nil && s.File2Context == nil {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.contexts) == 0 {
 		return ""
 	}
 
 	var sb strings.Builder
 	sb.WriteString("Consider the following context:\n")
 
-	if s.File1Context != nil {
-		sb.WriteString("File 1 Context:\n")
-		sb.WriteString(fmt.Sprintf("  - Purpose: %s\n", s.File1Context.DatasetPurpose))
-		sb.WriteString(fmt.Sprintf("  - Domain: %s\n", s.File1Context.BusinessDomain))
-		if len(s.File1Context.KeyEntities) > 0 {
-			sb.WriteString(fmt.Sprintf("  - Key Entities: %s\n", strings.Join(s.File1Context.KeyEntities, ", ")))
-		}
-		sb.WriteString("\n")
-	}
-
-	if s.File2Context != nil {
-		sb.WriteString("File 2 Context:\n")
-		sb.WriteString(fmt.Sprintf("  - Purpose: %s\n", s.File2Context.DatasetPurpose))
-		sb.WriteString(fmt.Sprintf("  - Domain: %s\n", s.File2Context.BusinessDomain))
-		if len(s.File2Context.KeyEntities) > 0 {
-			sb.WriteString(fmt.Sprintf("  - Key Entities: %s\n", strings.Join(s.File2Context.KeyEntities, ", ")))
+	for _, idx := range sortedIndices(s.contexts) {
+		ctx := s.contexts[idx]
+		sb.WriteString(fmt.Sprintf("File %d Context:\n", idx))
+		sb.WriteString(fmt.Sprintf("  - Purpose: %s\n", ctx.DatasetPurpose))
+		sb.WriteString(fmt.Sprintf("  - Domain: %s\n", ctx.BusinessDomain))
+		if len(ctx.KeyEntities) > 0 {
+			sb.WriteString(fmt.Sprintf("  - Key Entities: %s\n", strings.Join(ctx.KeyEntities, ", ")))
 		}
 		sb.WriteString("\n")
 	}
@@ -96,29 +276,42 @@ func (s *ContextService) BuildContextPrompt() string {
 	return sb.String()
 }
 
-// StoreContext updates the in-memory state
+// StoreContext validates ctx with CheckContext against the analysis stored
+// for fileIndex, returning a *ContextValidationError if it is invalid, and
+// merges it into the in-memory state
 func (s *ContextService) StoreContext(fileIndex int, ctx *models.Context) error {
-	if !s.ValidateContext(ctx) {
-		return fmt.Errorf("invalid context data: missing required fields")
+	if err := ValidateFileIndex(fileIndex); err != nil {
+		return err
 	}
 
-	if fileIndex == 1 {
-		s.File1Context = s.MergeContext(s.File1Context, ctx)
-	} else if fileIndex == 2 {
-		s.File2Context = s.MergeContext(s.File2Context, ctx)
-	} else {
-		return fmt.Errorf("invalid file_index: must be 1 or 2")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if verr := CheckContext(ctx, s.analyses[fileIndex]); verr != nil {
+		return verr
 	}
+
+	s.contexts[fileIndex] = s.MergeContext(s.contexts[fileIndex], ctx)
 	return nil
 }
 
 // GetContext retrieves context
 func (s *ContextService) GetContext(fileIndex int) *models.Context {
-	if fileIndex == 1 {
-		return s.File1Context
-	} else if fileIndex == 2 {
-		return s.File2Context
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.contexts[fileIndex]
+}
+
+// DeleteContext removes the stored context for a file index
+func (s *ContextService) DeleteContext(fileIndex int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.contexts[fileIndex]; !ok {
+		return fmt.Errorf("context for file %d: %w", fileIndex, ErrNotFound)
 	}
+	delete(s.contexts, fileIndex)
 	return nil
 }
 
@@ -137,22 +330,108 @@ func uniqueStrings(input []string) []string {
 
 // StoreAnalysis updates the in-memory analysis state
 func (s *ContextService) StoreAnalysis(fileIndex int, analysis *models.DataAnalysisResult) error {
-	if fileIndex == 1 {
-		s.File1Analysis = analysis
-	} else if fileIndex == 2 {
-		s.File2Analysis = analysis
-	} else {
-		return fmt.Errorf("invalid file_index: must be 1 or 2")
+	if err := ValidateFileIndex(fileIndex); err != nil {
+		return err
 	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.analyses[fileIndex] = analysis
 	return nil
 }
 
 // GetAnalysis retrieves analysis
 func (s *ContextService) GetAnalysis(fileIndex int) *models.DataAnalysisResult {
-	if fileIndex == 1 {
-		return s.File1Analysis
-	} else if fileIndex == 2 {
-		return s.File2Analysis
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.analyses[fileIndex]
+}
+
+// DeleteAnalysis removes the stored analysis for a file index
+func (s *ContextService) DeleteAnalysis(fileIndex int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.analyses[fileIndex]; !ok {
+		return fmt.Errorf("analysis for file %d: %w", fileIndex, ErrNotFound)
 	}
+	delete(s.analyses, fileIndex)
 	return nil
 }
+
+// AnnotateColumn attaches a business definition to a column of a stored
+// analysis. Handlers encode stored analyses without holding mu, so the
+// analysis is copied and the copy replaces it rather than being edited in place.
+func (s *ContextService) AnnotateColumn(fileIndex int, column, annotation string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	analysis, ok := s.analyses[fileIndex]
+	if !ok {
+		return fmt.Errorf("analysis for file %d: %w", fileIndex, ErrNotFound)
+	}
+	if analysis.Column(column) == nil {
+		return fmt.Errorf("column %q in file %d: %w", column, fileIndex, ErrNotFound)
+	}
+
+	annotated := *analysis
+	annotated.ColumnProfiles = append([]models.ColumnAnalysis(nil), analysis.ColumnProfiles...)
+	annotated.Column(column).Annotation = annotation
+	s.analyses[fileIndex] = &annotated
+	return nil
+}
+
+// GetAllAnalyses returns a snapshot of every stored analysis keyed by file index
+func (s *ContextService) GetAllAnalyses() map[int]*models.DataAnalysisResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	analyses := make(map[int]*models.DataAnalysisResult, len(s.analyses))
+	for idx, analysis := range s.analyses {
+		analyses[idx] = analysis
+	}
+	return analyses
+}
+
+// GetAllContexts returns a snapshot of every stored context keyed by file index
+func (s *ContextService) GetAllContexts() map[int]*models.Context {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	contexts := make(map[int]*models.Context, len(s.contexts))
+	for idx, ctx := range s.contexts {
+		contexts[idx] = ctx
+	}
+	return contexts
+}
+
+// WipeAll removes every stored analysis, context, annotation and template
+func (s *ContextService) WipeAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contexts = make(map[int]*models.Context)
+	s.analyses = make(map[int]*models.DataAnalysisResult)
+	s.Templates = make(map[string]*models.Context)
+	return nil
+}
+
+// AnalysisIndices returns the file indices that have a stored analysis, in ascending order
+func (s *ContextService) AnalysisIndices() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return sortedIndices(s.analyses)
+}
+
+// sortedIndices returns the keys of an index-keyed map in ascending order
+func sortedIndices[V any](m map[int]V) []int {
+	indices := make([]int, 0, len(m))
+	for idx := range m {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+	return indices
+}
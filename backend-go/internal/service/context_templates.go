@@ -0,0 +1,72 @@
+package service
+
+import (
+	"backend-go/internal/models"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateTemplateName checks that a template name is 1-64 letters, digits,
+// '-' or '_', so it is safe to use in a file name
+func ValidateTemplateName(name string) error {
+	if !templateNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid template name %q: must be 1-64 letters, digits, '-' or '_'", name)
+	}
+	return nil
+}
+
+// StoreTemplate stores ctx as the template called name, replacing any
+// template of that name
+func (s *ContextService) StoreTemplate(name string, ctx *models.Context) error {
+	if err := ValidateTemplateName(name); err != nil {
+		return err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	if ctx.CreatedAt == "" {
+		ctx.CreatedAt = now
+	}
+	ctx.UpdatedAt = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Templates[name] = ctx
+	return nil
+}
+
+// GetTemplate returns the template called name, or nil if there is none
+func (s *ContextService) GetTemplate(name string) *models.Context {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Templates[name]
+}
+
+// TemplateNames returns the names of the stored templates, sorted
+func (s *ContextService) TemplateNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.Templates))
+	for name := range s.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InstantiateTemplate returns a new context holding the fields of template
+// with overrides merged on top, as StoreContext would merge them. Neither
+// argument is modified; overrides may be nil.
+func InstantiateTemplate(template, overrides *models.Context) *models.Context {
+	ctx := mergeContext(models.NewContext(), template)
+	if overrides != nil {
+		ctx = mergeContext(ctx, overrides)
+	}
+	return ctx
+}
@@ -1,29 +1,118 @@
 package service
 
 import (
+	"backend-go/internal/models"
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
 // DataSourceConfig holds connection details
 type DataSourceConfig struct {
-	Type     string // "postgres", "mysql"
+	Type     string // "postgres", "cockroachdb", "mysql", "sqlite", "duckdb", "bigquery", "snowflake"
 	Host     string
 	Port     int
 	User     string
 	Password string
 	DBName   string
 	SSLMode  string // "disable", "require"
+	DSN      string // File path for sqlite and duckdb, or a connection string for cockroachdb and snowflake
+
+	// BigQuery dataset; credentials come from Application Default Credentials
+	ProjectID string
+	DatasetID string
+
+	// Connection pool tuning for network databases; zero uses the defaults below
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime int // seconds
+
+	// How AnalyzeTable picks rows from a table; "" means the first rows
+	Sampling models.SamplingStrategy
+}
+
+// Connection pool defaults applied when DataSourceConfig leaves them unset
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 5
+	defaultConnMaxLifetime = 5 * time.Minute
+)
+
+// applyPoolConfig sizes the connection pool from the config, falling back to
+// the package defaults
+func applyPoolConfig(db *sql.DB, config DataSourceConfig) {
+	maxOpen := config.MaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = defaultMaxOpenConns
+	}
+	maxIdle := config.MaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = defaultMaxIdleConns
+	}
+	lifetime := time.Duration(config.ConnMaxLifetime) * time.Second
+	if lifetime <= 0 {
+		lifetime = defaultConnMaxLifetime
+	}
+
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
 }
 
 // DataSource defines the interface for data sources
 type DataSource interface {
 	Connect(config DataSourceConfig) error
 	Close() error
+	Ping() error
 	ListTables() ([]string, error)
-	PreviewData(tableName string, limit int) ([]map[string]interface{}, error)
+	ListTablesPaged(limit, offset int) ([]string, int, error)
+	ListTablesWithSchema() ([]TableInfo, error)
+	ListViews() ([]string, error)
+	QueryRaw(query string, limit int) ([]string, []map[string]interface{}, error)
+	PreviewData(tableName string, limit int) ([]string, []map[string]interface{}, error)
+	DescribeSchema() (*DatabaseSchema, error)
+	ExportSchema() (string, error) // CREATE TABLE statements for DescribeSchema
+}
+
+// RandomSampler is implemented by data sources that can draw a random sample
+// from a whole table in SQL, rather than from the rows PreviewData returns
+type RandomSampler interface {
+	SampleData(tableName string, limit int) ([]string, []map[string]interface{}, error)
+}
+
+// TableInfo describes the shape of a table without reading its rows.
+// RowCount is the database's estimate where it keeps one (Postgres, MySQL).
+type TableInfo struct {
+	Name        string `json:"name"`
+	RowCount    int64  `json:"row_count"`
+	ColumnCount int    `json:"column_count"`
+	Comment     string `json:"comment"`
+	Type        string `json:"type,omitempty"` // "table" or "view" in merged listings
+}
+
+// NewDataSource returns an unconnected DataSource for the given config type
+func NewDataSource(sourceType string) (DataSource, error) {
+	switch sourceType {
+	case "postgres":
+		return &PostgresDataSource{}, nil
+	case "cockroachdb":
+		return &CockroachDBDataSource{}, nil
+	case "mysql":
+		return &MySQLDataSource{}, nil
+	case "sqlite":
+		return &SQLiteDataSource{}, nil
+	case "duckdb":
+		return &DuckDBDataSource{}, nil
+	case "bigquery":
+		return &BigQueryDataSource{}, nil
+	case "snowflake":
+		return &SnowflakeDataSource{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported data source type: %q", sourceType)
+	}
 }
 
 // PostgresDataSource implements DataSource for PostgreSQL
@@ -39,8 +128,10 @@ func (p *PostgresDataSource) Connect(config DataSourceConfig) error {
 	if err != nil {
 		return err
 	}
+	applyPoolConfig(db, config)
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return err
 	}
 
@@ -55,49 +146,262 @@ func (p *PostgresDataSource) Close() error {
 	return nil
 }
 
+// postgresPingTimeout bounds Ping; idle TCP connections to Postgres can die
+// silently and otherwise only fail after the kernel's much longer timeout
+const postgresPingTimeout = 5 * time.Second
+
+func (p *PostgresDataSource) Ping() error {
+	return pingDB(p.db, postgresPingTimeout)
+}
+
 func (p *PostgresDataSource) ListTables() ([]string, error) {
+	tables, _, err := p.ListTablesPaged(0, 0)
+	return tables, err
+}
+
+// ListTablesPaged returns a page of table names plus the total table count.
+// A limit of 0 returns all tables from offset onwards.
+func (p *PostgresDataSource) ListTablesPaged(limit, offset int) ([]string, int, error) {
 	query := `
 		SELECT table_name
 		FROM information_schema.tables
 		WHERE table_schema = 'public'
-		ORDER BY table_name;
+		ORDER BY table_name
+	`
+	return pagedNames(p.db, query, limit, offset)
+}
+
+// ListTablesWithSchema returns every public table with its live row estimate
+// from pg_stat_user_tables and its comment from pg_description
+func (p *PostgresDataSource) ListTablesWithSchema() ([]TableInfo, error) {
+	query := `
+		SELECT c.relname,
+			COALESCE(s.n_live_tup, 0),
+			(SELECT COUNT(*) FROM information_schema.columns col
+				WHERE col.table_schema = n.nspname AND col.table_name = c.relname),
+			COALESCE(obj_description(c.oid, 'pg_class'), '')
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_stat_user_tables s ON s.relid = c.oid
+		WHERE n.nspname = 'public' AND c.relkind IN ('r', 'p')
+		ORDER BY c.relname
+	`
+	return scanTableInfos(p.db, query)
+}
+
+// ListViews returns the names of the views in the public schema
+func (p *PostgresDataSource) ListViews() ([]string, error) {
+	query := `
+		SELECT table_name
+		FROM information_schema.views
+		WHERE table_schema = 'public'
+		ORDER BY table_name
 	`
+	views, _, err := pagedNames(p.db, query, 0, 0)
+	return views, err
+}
+
+func (p *PostgresDataSource) PreviewData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	query := fmt.Sprintf("SELECT * FROM %s LIMIT %d", dialectIdent(tableName, DialectPostgres), limit)
+
+	rows, err := p.db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows)
+}
+
+// SampleData returns limit rows chosen at random from the whole table
+func (p *PostgresDataSource) SampleData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	query := fmt.Sprintf("SELECT * FROM %s ORDER BY random() LIMIT %d", dialectIdent(tableName, DialectPostgres), limit)
+
 	rows, err := p.db.Query(query)
 	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows)
+}
+
+func (p *PostgresDataSource) QueryRaw(query string, limit int) ([]string, []map[string]interface{}, error) {
+	return queryRaw(p.db, query, limit, true)
+}
+
+// postgresSchemaColumns reads column definitions from information_schema,
+// rebuilding length, precision and array suffixes that data_type leaves out.
+// %s adds conditions on c.
+const postgresSchemaColumns = `
+	SELECT c.table_name, c.column_name,
+		CASE
+			WHEN c.data_type = 'USER-DEFINED' THEN quote_ident(c.udt_name)
+			WHEN c.data_type = 'ARRAY' THEN substr(c.udt_name, 2) || '[]'
+			WHEN c.character_maximum_length IS NOT NULL THEN c.data_type || '(' || c.character_maximum_length || ')'
+			WHEN c.data_type = 'numeric' AND c.numeric_precision IS NOT NULL
+				THEN 'numeric(' || c.numeric_precision || ',' || c.numeric_scale || ')'
+			ELSE c.data_type
+		END,
+		c.is_nullable, c.column_default
+	FROM information_schema.columns c
+	JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+	WHERE c.table_schema = 'public' AND t.table_type = 'BASE TABLE'%s
+	ORDER BY c.table_name, c.ordinal_position
+`
+
+// DescribeSchema returns the enum types, and the columns and primary key of
+// every public table
+func (p *PostgresDataSource) DescribeSchema() (*DatabaseSchema, error) {
+	schema, err := scanSchema(p.db, "postgres", fmt.Sprintf(postgresSchemaColumns, ""),
+		fmt.Sprintf(informationSchemaKeys, "tc.table_schema = 'public'"))
+	if err != nil {
+		return nil, err
+	}
+	if err := scanPostgresEnums(p.db, schema); err != nil {
 		return nil, err
 	}
+	return schema, nil
+}
+
+func (p *PostgresDataSource) ExportSchema() (string, error) {
+	return exportSchema(p)
+}
+
+// exportSchema renders the schema of ds as DDL
+func exportSchema(ds DataSource) (string, error) {
+	schema, err := ds.DescribeSchema()
+	if err != nil {
+		return "", err
+	}
+	return RenderDDL(schema), nil
+}
+
+// rawQueryTimeout bounds a caller-supplied query. The deadline cancels the
+// statement on the server for drivers that support cancellation.
+const rawQueryTimeout = 30 * time.Second
+
+// txBeginner is satisfied by both *sql.DB and *sql.Conn
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// queryRaw runs a caller-supplied SELECT inside a transaction that is always
+// rolled back, and stops scanning after limit rows. readOnly asks the driver
+// for a READ ONLY transaction, which rejects writes including writable CTEs
+// and nextval; drivers without one rely on the rollback alone. The subquery
+// LIMIT only saves the server work: the query is put on its own lines so a
+// trailing -- comment cannot swallow it, and the scan cap still applies.
+func queryRaw(db txBeginner, query string, limit int, readOnly bool) ([]string, []map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rawQueryTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	wrapped := fmt.Sprintf("SELECT * FROM (\n%s\n) AS raw_query LIMIT %d", query, limit)
+
+	rows, err := tx.QueryContext(ctx, wrapped)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMapsLimit(rows, limit)
+}
+
+// pagedNames runs a single-column name query and returns the requested page
+// along with the total number of rows the query yields
+func pagedNames(db *sql.DB, query string, limit, offset int) ([]string, int, error) {
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM (" + query + ") AS t").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer rows.Close()
 
-	var tables []string
+	names := []string{}
+	skipped := 0
 	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			return nil, err
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, 0, err
+		}
+		// Not every dialect accepts OFFSET without LIMIT, so skip in Go instead
+		if limit <= 0 && skipped < offset {
+			skipped++
+			continue
 		}
-		tables = append(tables, tableName)
+		names = append(names, name)
 	}
-	return tables, nil
+	return names, total, rows.Err()
 }
 
-func (p *PostgresDataSource) PreviewData(tableName string, limit int) ([]map[string]interface{}, error) {
-	// WARNING: VULNERABLE TO SQL INJECTION IF tableName IS UNTRUSTED
-	// In a real app, validate tableName against ListTables() whitelist
-	query := fmt.Sprintf("SELECT * FROM %s LIMIT %d", tableName, limit)
-
-	rows, err := p.db.Query(query)
+// scanTableInfos runs a query yielding name, row count, column count and
+// comment columns, in that order
+func scanTableInfos(db *sql.DB, query string) ([]TableInfo, error) {
+	rows, err := db.Query(query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	tables := []TableInfo{}
+	for rows.Next() {
+		var t TableInfo
+		if err := rows.Scan(&t.Name, &t.RowCount, &t.ColumnCount, &t.Comment); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+// pingTimeout bounds the connectivity check used by health probes, for
+// backends without a timeout of their own
+const pingTimeout = 2 * time.Second
+
+// pingDB runs a trivial query to confirm the connection is usable within timeout
+func pingDB(db *sql.DB, timeout time.Duration) error {
+	if db == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, "SELECT 1")
+	return err
+}
+
+// scanRowMaps converts the result set into a slice of column->value maps.
+// The returned column slice preserves the order of the table definition.
+// Shared by every database/sql backed DataSource.
+func scanRowMaps(rows *sql.Rows) ([]string, []map[string]interface{}, error) {
+	return scanRowMapsLimit(rows, 0)
+}
+
+// scanRowMapsLimit is scanRowMaps that stops after limit rows; 0 means no limit
+func scanRowMapsLimit(rows *sql.Rows, limit int) ([]string, []map[string]interface{}, error) {
 	columns, err := rows.Columns()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var result []map[string]interface{}
 
-	for rows.Next() {
+	for (limit <= 0 || len(result) < limit) && rows.Next() {
 		// Prepare a slice of interface{} to hold values
 		values := make([]interface{}, len(columns))
 		valuePtrs := make([]interface{}, len(columns))
@@ -106,7 +410,7 @@ func (p *PostgresDataSource) PreviewData(tableName string, limit int) ([]map[str
 		}
 
 		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// Convert to map
@@ -124,5 +428,5 @@ func (p *PostgresDataSource) PreviewData(tableName string, limit int) ([]map[str
 		result = append(result, rowMap)
 	}
 
-	return result, nil
+	return columns, result, rows.Err()
 }
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DatabaseSchema is the structure of every user table in a connected
+// database, as returned by DataSource.DescribeSchema
+type DatabaseSchema struct {
+	Dialect string        `json:"dialect"` // DataSourceConfig.Type of the database
+	Enums   []SchemaEnum  `json:"enums,omitempty"`
+	Tables  []SchemaTable `json:"tables"`
+}
+
+// SchemaEnum is a Postgres enum type used by the schema's columns
+type SchemaEnum struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// SchemaTable is one table of a DatabaseSchema
+type SchemaTable struct {
+	Name       string         `json:"name"`
+	Columns    []SchemaColumn `json:"columns"`
+	PrimaryKey []string       `json:"primary_key,omitempty"`
+}
+
+// SchemaColumn is one column of a SchemaTable. DataType is in the database's
+// own dialect, and Default is an SQL expression.
+type SchemaColumn struct {
+	Name     string `json:"name"`
+	DataType string `json:"data_type"`
+	Nullable bool   `json:"nullable"`
+	Default  string `json:"default,omitempty"`
+}
+
+// scanSchema builds a schema from a column query and an optional primary key
+// query. The column query yields table name, column name, data type,
+// is_nullable ('YES' or 'NO') and default, ordered by table then column
+// position; the key query yields table and column names in key order.
+func scanSchema(db *sql.DB, dialect, columnsQuery, keysQuery string) (*DatabaseSchema, error) {
+	if db == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	rows, err := db.Query(columnsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schema := &DatabaseSchema{Dialect: dialect, Tables: []SchemaTable{}}
+	tableIndex := map[string]int{}
+	for rows.Next() {
+		var table, nullable string
+		var col SchemaColumn
+		var dflt sql.NullString
+		if err := rows.Scan(&table, &col.Name, &col.DataType, &nullable, &dflt); err != nil {
+			return nil, err
+		}
+		col.Nullable = strings.EqualFold(nullable, "YES")
+		col.Default = dflt.String
+
+		i, ok := tableIndex[table]
+		if !ok {
+			i = len(schema.Tables)
+			tableIndex[table] = i
+			schema.Tables = append(schema.Tables, SchemaTable{Name: table})
+		}
+		schema.Tables[i].Columns = append(schema.Tables[i].Columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if keysQuery == "" {
+		return schema, nil
+	}
+	keys, err := db.Query(keysQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer keys.Close()
+
+	for keys.Next() {
+		var table, column string
+		if err := keys.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		if i, ok := tableIndex[table]; ok {
+			schema.Tables[i].PrimaryKey = append(schema.Tables[i].PrimaryKey, column)
+		}
+	}
+	return schema, keys.Err()
+}
+
+// informationSchemaKeys is the primary key query for databases with a
+// standard information_schema; %s is the schema condition on tc, e.g.
+// "tc.table_schema = 'public'"
+const informationSchemaKeys = `
+	SELECT kcu.table_name, kcu.column_name
+	FROM information_schema.table_constraints tc
+	JOIN information_schema.key_column_usage kcu
+		ON kcu.constraint_name = tc.constraint_name
+		AND kcu.table_schema = tc.table_schema
+		AND kcu.table_name = tc.table_name
+	WHERE tc.constraint_type = 'PRIMARY KEY' AND %s
+	ORDER BY kcu.table_name, kcu.ordinal_position
+`
+
+// scanPostgresEnums adds the enum types of the public schema to schema, so
+// RenderDDL can create them before the tables that use them
+func scanPostgresEnums(db *sql.DB, schema *DatabaseSchema) error {
+	rows, err := db.Query(`
+		SELECT t.typname, e.enumlabel
+		FROM pg_type t
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = 'public'
+		ORDER BY t.typname, e.enumsortorder
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return err
+		}
+		if n := len(schema.Enums); n == 0 || schema.Enums[n-1].Name != name {
+			schema.Enums = append(schema.Enums, SchemaEnum{Name: name})
+		}
+		enum := &schema.Enums[len(schema.Enums)-1]
+		enum.Values = append(enum.Values, value)
+	}
+	return rows.Err()
+}
+
+// RenderDDL writes schema as CREATE TYPE statements for its enums followed by
+// CREATE TABLE statements in its dialect, ready to run against an empty
+// database of the same kind
+func RenderDDL(schema *DatabaseSchema) string {
+	quote := quoteIdentifier(schema.Dialect)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "-- Schema of %d table(s), %s dialect\n", len(schema.Tables), schema.Dialect)
+	for _, enum := range schema.Enums {
+		values := make([]string, len(enum.Values))
+		for i, v := range enum.Values {
+			values[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		}
+		fmt.Fprintf(&sb, "\nCREATE TYPE %s AS ENUM (%s);\n", quote(enum.Name), strings.Join(values, ", "))
+	}
+	for _, table := range schema.Tables {
+		sb.WriteString("\nCREATE TABLE " + quote(table.Name) + " (\n")
+
+		lines := make([]string, 0, len(table.Columns)+1)
+		for _, col := range table.Columns {
+			lines = append(lines, "    "+columnDDL(schema.Dialect, col, quote))
+		}
+		if len(table.PrimaryKey) > 0 {
+			keys := make([]string, len(table.PrimaryKey))
+			for i, k := range table.PrimaryKey {
+				keys[i] = quote(k)
+			}
+			primaryKey := "PRIMARY KEY (" + strings.Join(keys, ", ") + ")"
+			if schema.Dialect == "bigquery" {
+				primaryKey += " NOT ENFORCED" // BigQuery accepts no other kind
+			}
+			lines = append(lines, "    "+primaryKey)
+		}
+		sb.WriteString(strings.Join(lines, ",\n"))
+		sb.WriteString("\n);\n")
+	}
+	return sb.String()
+}
+
+// columnDDL renders one column definition
+func columnDDL(dialect string, col SchemaColumn, quote func(string) string) string {
+	def := quote(col.Name)
+	if col.DataType != "" {
+		def += " " + col.DataType // SQLite columns may have no declared type
+	}
+	if !col.Nullable {
+		def += " NOT NULL"
+	}
+
+	switch {
+	case col.Default == "":
+	case isPostgresFamily(dialect) && strings.HasPrefix(col.Default, "nextval("):
+		// serial columns default to a sequence the dump does not create
+		def += " GENERATED BY DEFAULT AS IDENTITY"
+	default:
+		def += " DEFAULT " + col.Default
+	}
+	return def
+}
+
+func isPostgresFamily(dialect string) bool {
+	return dialect == "postgres" || dialect == "cockroachdb"
+}
+
+// quoteIdentifier returns the identifier quoting function of dialect
+func quoteIdentifier(dialect string) func(string) string {
+	switch dialect {
+	case "mysql", "bigquery":
+		return func(name string) string {
+			return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+		}
+	default:
+		return sqlIdent
+	}
+}
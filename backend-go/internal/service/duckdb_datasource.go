@@ -0,0 +1,157 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+// DuckDBDataSource implements DataSource for DuckDB database files. DSN is the
+// database file path, or ":memory:" for an in-process database. The database
+// is opened with external access disabled, so queries cannot read files on
+// the server; Parquet, CSV and JSON files are profiled by loading them into
+// tables beforehand, e.g.
+//
+//	CREATE TABLE events AS SELECT * FROM 'events.parquet'
+type DuckDBDataSource struct {
+	db *sql.DB
+}
+
+func (d *DuckDBDataSource) Connect(config DataSourceConfig) error {
+	if config.DSN == "" {
+		return fmt.Errorf("duckdb requires a database file path or :memory: in dsn")
+	}
+
+	path, rawOptions, _ := strings.Cut(config.DSN, "?")
+	if path == ":memory:" {
+		// The driver opens an in-memory database for an empty path
+		path = ""
+	}
+
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return fmt.Errorf("invalid duckdb dsn options: %w", err)
+	}
+	// QueryRaw takes caller SQL, and table functions such as read_csv or
+	// SELECT * FROM '/etc/passwd' would otherwise read any file the server
+	// can. Locking the configuration stops a query from turning it back on.
+	options.Set("enable_external_access", "false")
+	options.Set("lock_configuration", "true")
+
+	db, err := sql.Open("duckdb", path+"?"+options.Encode())
+	if err != nil {
+		return err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	d.db = db
+	return nil
+}
+
+func (d *DuckDBDataSource) Close() error {
+	if d.db != nil {
+		return d.db.Close()
+	}
+	return nil
+}
+
+func (d *DuckDBDataSource) Ping() error {
+	return pingDB(d.db, pingTimeout)
+}
+
+func (d *DuckDBDataSource) ListTables() ([]string, error) {
+	tables, _, err := d.ListTablesPaged(0, 0)
+	return tables, err
+}
+
+// ListTablesPaged returns a page of table names plus the total table count.
+// A limit of 0 returns all tables from offset onwards. SHOW TABLES lists views
+// too, so those are left to ListViews.
+func (d *DuckDBDataSource) ListTablesPaged(limit, offset int) ([]string, int, error) {
+	query := `
+		SELECT name
+		FROM (SHOW TABLES)
+		WHERE name NOT IN (SELECT view_name FROM duckdb_views())
+		ORDER BY name
+	`
+	return pagedNames(d.db, query, limit, offset)
+}
+
+// ListTablesWithSchema returns every table in the main schema with DuckDB's
+// row estimate and comment
+func (d *DuckDBDataSource) ListTablesWithSchema() ([]TableInfo, error) {
+	query := `
+		SELECT table_name, estimated_size, column_count, COALESCE(comment, '')
+		FROM duckdb_tables()
+		WHERE schema_name = 'main'
+		ORDER BY table_name
+	`
+	return scanTableInfos(d.db, query)
+}
+
+// ListViews returns the names of the user-defined views
+func (d *DuckDBDataSource) ListViews() ([]string, error) {
+	query := `
+		SELECT view_name
+		FROM duckdb_views()
+		WHERE NOT internal
+		ORDER BY view_name
+	`
+	views, _, err := pagedNames(d.db, query, 0, 0)
+	return views, err
+}
+
+func (d *DuckDBDataSource) PreviewData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	query := fmt.Sprintf(`SELECT * FROM "%s" LIMIT %d`, strings.ReplaceAll(tableName, `"`, `""`), limit)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows)
+}
+
+// SampleData returns limit rows chosen at random from the whole table
+func (d *DuckDBDataSource) SampleData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	query := fmt.Sprintf(`SELECT * FROM "%s" ORDER BY random() LIMIT %d`, strings.ReplaceAll(tableName, `"`, `""`), limit)
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows)
+}
+
+// QueryRaw runs query in a transaction that is rolled back; the driver rejects
+// read-only transactions
+func (d *DuckDBDataSource) QueryRaw(query string, limit int) ([]string, []map[string]interface{}, error) {
+	return queryRaw(d.db, query, limit, false)
+}
+
+// DescribeSchema returns the columns and primary key of every table in the
+// main schema
+func (d *DuckDBDataSource) DescribeSchema() (*DatabaseSchema, error) {
+	query := `
+		SELECT c.table_name, c.column_name, c.data_type, c.is_nullable, c.column_default
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = 'main' AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position
+	`
+	return scanSchema(d.db, "duckdb", query, fmt.Sprintf(informationSchemaKeys, "tc.table_schema = 'main'"))
+}
+
+func (d *DuckDBDataSource) ExportSchema() (string, error) {
+	return exportSchema(d)
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"backend-go/internal/models"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -12,11 +14,56 @@ func NewExportService() *ExportService {
 	return &ExportService{}
 }
 
-func (s *ExportService) GenerateSQL(graph *models.SimilarityGraph) string {
+// GenerateSQL emits CREATE TABLE statements for the files in the graph, typed
+// from the stored analyses, followed by a query joining them on the
+// high-confidence mappings. Type names, identifier quoting and primary key
+// syntax follow dialect (one of the Dialect constants); opts makes the
+// statements safe to re-run.
+func (s *ExportService) GenerateSQL(graph *models.SimilarityGraph, analyses map[int]*models.DataAnalysisResult, dialect string, opts SQLOptions) string {
 	var sb strings.Builder
 
-	sb.WriteString("-- Generated by Project Euler\n")
-	sb.WriteString("-- SQL Query to join File 1 and File 2 based on high-confidence mappings\n\n")
+	sb.WriteString(fmt.Sprintf("-- Generated by Project Euler (%s)\n\n", dialect))
+
+	for i, t := range graphTables(graph) {
+		analysis := analyses[t.Index]
+
+		var lines []string
+		for _, col := range t.Columns {
+			colType := dialectTextType(dialect)
+			constraints, warning := "", ""
+			if analysis != nil {
+				if profile := analysis.Column(col); profile != nil {
+					colType = dialectType(profile, dialect)
+					if !profile.Nullable {
+						constraints = " NOT NULL"
+					}
+					if profile.NearConstant() {
+						warning = "    -- WARNING: near-constant column\n"
+					}
+				}
+				// BigQuery only takes the key as an unenforced table constraint
+				if col == analysis.InferredPrimaryKey && dialect != DialectBigQuery {
+					if dialect == DialectMySQL && colType == "TEXT" {
+						// MySQL cannot index TEXT without a prefix length
+						colType = "VARCHAR(255)"
+					}
+					constraints += " PRIMARY KEY"
+				}
+			}
+			lines = append(lines, fmt.Sprintf("%s    %s %s%s", warning, dialectIdent(col, dialect), colType, constraints))
+		}
+		if dialect == DialectBigQuery && analysis != nil && analysis.InferredPrimaryKey != "" {
+			lines = append(lines, fmt.Sprintf("    PRIMARY KEY (%s) NOT ENFORCED", dialectIdent(analysis.InferredPrimaryKey, dialect)))
+		}
+
+		sb.WriteString(fmt.Sprintf("-- %s\n", t.Group))
+		sb.WriteString(createTable(fmt.Sprintf("table%d", i+1), dialect, opts))
+		sb.WriteString(" (\n")
+		sb.WriteString(strings.Join(lines, ",\n"))
+		sb.WriteString("\n);\n\n")
+	}
+
+	sb.WriteString("-- SQL Query to join File 1 and File 2 based on high-confidence mappings\n")
 	sb.WriteString("SELECT\n")
 
 	// Select fields (mocking table names as table1 and table2)
@@ -33,7 +80,7 @@ func (s *ExportService) GenerateSQL(graph *models.SimilarityGraph) string {
 			} else {
 				sb.WriteString("    ")
 			}
-			sb.WriteString(fmt.Sprintf("t1.%s = t2.%s\n", sim.File1Column, sim.File2Column))
+			sb.WriteString(fmt.Sprintf("t1.%s = t2.%s\n", dialectIdent(sim.File1Column, dialect), dialectIdent(sim.File2Column, dialect)))
 			first = false
 		}
 	}
@@ -45,16 +92,38 @@ func (s *ExportService) GenerateSQL(graph *models.SimilarityGraph) string {
 	return sb.String()
 }
 
-func (s *ExportService) GeneratePython(graph *models.SimilarityGraph) string {
+// MaskConfig lists columns that GeneratePython replaces with their SHA-256
+// hash after loading, so a script can be shared without exposing their values
+type MaskConfig struct {
+	AnonymizeColumns []string
+}
+
+// GeneratePython emits a pandas script that loads both files and merges them
+// on the high-confidence mappings, masking the columns in mask first. Hashing
+// keeps equal values equal, so masked columns can still be joined on.
+func (s *ExportService) GeneratePython(graph *models.SimilarityGraph, mask MaskConfig) string {
 	var sb strings.Builder
 
 	sb.WriteString("# Generated by Project Euler\n")
+	if len(mask.AnonymizeColumns) > 0 {
+		sb.WriteString("import hashlib\n")
+	}
 	sb.WriteString("import pandas as pd\n\n")
 
 	sb.WriteString("# Load your data\n")
 	sb.WriteString("df1 = pd.read_csv('file1.csv')\n")
 	sb.WriteString("df2 = pd.read_csv('file2.csv')\n\n")
 
+	if len(mask.AnonymizeColumns) > 0 {
+		sb.WriteString("# Mask sensitive columns\n")
+		sb.WriteString("def mask(value):\n")
+		sb.WriteString("    return hashlib.sha256(str(value).encode('utf-8')).hexdigest()\n\n")
+		sb.WriteString("for df in (df1, df2):\n")
+		sb.WriteString(fmt.Sprintf("    for col in %s:\n", pythonList(mask.AnonymizeColumns)))
+		sb.WriteString("        if col in df.columns:\n")
+		sb.WriteString("            df[col] = df[col].map(mask, na_action='ignore')\n\n")
+	}
+
 	sb.WriteString("# Merge DataFrames\n")
 	sb.WriteString("merged_df = pd.merge(\n")
 	sb.WriteString("    df1,\n")
@@ -64,7 +133,7 @@ func (s *ExportService) GeneratePython(graph *models.SimilarityGraph) string {
 	// Left keys
 	for _, sim := range graph.Similarities {
 		if sim.Confidence >= 70.0 {
-			sb.WriteString(fmt.Sprintf("        '%s',\n", sim.File1Column))
+			sb.WriteString(fmt.Sprintf("        %s,\n", pythonString(sim.File1Column)))
 		}
 	}
 	sb.WriteString("    ],\n")
@@ -73,7 +142,7 @@ func (s *ExportService) GeneratePython(graph *models.SimilarityGraph) string {
 	// Right keys
 	for _, sim := range graph.Similarities {
 		if sim.Confidence >= 70.0 {
-			sb.WriteString(fmt.Sprintf("        '%s',\n", sim.File2Column))
+			sb.WriteString(fmt.Sprintf("        %s,\n", pythonString(sim.File2Column)))
 		}
 	}
 	sb.WriteString("    ],\n")
@@ -83,3 +152,301 @@ func (s *ExportService) GeneratePython(graph *models.SimilarityGraph) string {
 
 	return sb.String()
 }
+
+func (s *ExportService) GenerateR(graph *models.SimilarityGraph) string {
+	var sb strings.Builder
+
+	sb.WriteString("# Generated by Project Euler\n")
+	sb.WriteString("library(readr)\n\n")
+
+	sb.WriteString("# Load your data\n")
+	tables := graphTables(graph)
+	for _, t := range tables {
+		sb.WriteString(fmt.Sprintf("# %s columns: %s\n", t.Group, strings.Join(t.Columns, ", ")))
+		sb.WriteString(fmt.Sprintf("df%d <- as.data.frame(readr::read_csv(\"file%d.csv\"))\n", t.Index, t.Index))
+	}
+	sb.WriteString("\n")
+
+	left, right := joinKeys(graph)
+	if len(tables) < 2 {
+		sb.WriteString("# Need two files to merge\n")
+		return sb.String()
+	}
+
+	sb.WriteString("# Merge data frames\n")
+	if len(left) == 0 {
+		sb.WriteString("# No high confidence relationships found, falling back to a cross join\n")
+	}
+	sb.WriteString("merged_df <- merge(\n")
+	sb.WriteString(fmt.Sprintf("  df%d,\n", tables[0].Index))
+	sb.WriteString(fmt.Sprintf("  df%d,\n", tables[1].Index))
+	sb.WriteString(fmt.Sprintf("  by.x = %s,\n", rVector(left)))
+	sb.WriteString(fmt.Sprintf("  by.y = %s\n", rVector(right)))
+	sb.WriteString(")\n\n")
+	sb.WriteString("print(head(merged_df))\n")
+
+	return sb.String()
+}
+
+// GeneratePySpark emits a PySpark job that reads each file in the graph,
+// casts its columns to the types inferred by the stored analyses, joins the
+// first two files on the high-confidence mappings and writes the result as
+// Parquet
+func (s *ExportService) GeneratePySpark(graph *models.SimilarityGraph, analyses map[int]*models.DataAnalysisResult) string {
+	var sb strings.Builder
+
+	sb.WriteString("# Generated by Project Euler\n")
+	sb.WriteString("from pyspark.sql import SparkSession\n")
+	sb.WriteString("from pyspark.sql.functions import col\n\n")
+	sb.WriteString("spark = SparkSession.builder.appName(\"project-euler\").getOrCreate()\n\n")
+
+	tables := graphTables(graph)
+	for _, t := range tables {
+		sb.WriteString(fmt.Sprintf("# %s\n", t.Group))
+		sb.WriteString(fmt.Sprintf("df%d = (\n", t.Index))
+		sb.WriteString(fmt.Sprintf("    spark.read.csv(\"file%d.csv\", header=True)\n", t.Index))
+		if analysis := analyses[t.Index]; analysis != nil {
+			for _, c := range t.Columns {
+				profile := analysis.Column(c)
+				if profile == nil {
+					continue
+				}
+				if sparkType := sparkCastType(profile); sparkType != "" {
+					sb.WriteString(fmt.Sprintf("    .withColumn(%s, col(%s).cast(%q))\n", strconv.Quote(c), strconv.Quote(sparkColumn(c)), sparkType))
+				}
+			}
+		}
+		sb.WriteString(")\n\n")
+	}
+
+	if len(tables) == 0 {
+		sb.WriteString("# No files in the graph\n")
+		return sb.String()
+	}
+	if len(tables) < 2 {
+		sb.WriteString(fmt.Sprintf("df%d.write.mode(\"overwrite\").parquet(\"output.parquet\")\n", tables[0].Index))
+		return sb.String()
+	}
+
+	left, right := tables[0].Index, tables[1].Index
+	var conditions []string
+	for _, sim := range graph.Similarities {
+		if sim.Confidence >= 70.0 {
+			conditions = append(conditions, fmt.Sprintf("(df%d[%s] == df%d[%s])",
+				left, strconv.Quote(sparkColumn(sim.File1Column)), right, strconv.Quote(sparkColumn(sim.File2Column))))
+		}
+	}
+
+	sb.WriteString("# Join on high-confidence mappings\n")
+	if len(conditions) == 0 {
+		sb.WriteString("# No high confidence relationships found, falling back to a cross join\n")
+		sb.WriteString(fmt.Sprintf("merged_df = df%d.crossJoin(df%d)\n\n", left, right))
+	} else {
+		sb.WriteString(fmt.Sprintf("merged_df = df%d.join(\n", left))
+		sb.WriteString(fmt.Sprintf("    df%d,\n", right))
+		sb.WriteString(fmt.Sprintf("    on=%s,\n", strings.Join(conditions, "\n    & ")))
+		sb.WriteString("    how=\"inner\",\n")
+		sb.WriteString(")\n\n")
+	}
+	sb.WriteString("merged_df.write.mode(\"overwrite\").parquet(\"merged.parquet\")\n")
+
+	return sb.String()
+}
+
+// sparkCastType maps a column's inferred type to a Spark SQL type, or ""
+// to leave it a string. Dates are cast only in ISO layouts, which is all
+// Spark's cast parses.
+func sparkCastType(profile *models.ColumnAnalysis) string {
+	switch gormType(profile) {
+	case "int64":
+		return "bigint"
+	case "float64":
+		return "double"
+	case "bool":
+		return "boolean"
+	case "time.Time":
+		if !strings.HasPrefix(profile.DateFormat, "2006-01-02") {
+			return ""
+		}
+		if isDateOnlyLayout(profile.DateFormat) {
+			return "date"
+		}
+		return "timestamp"
+	default:
+		return ""
+	}
+}
+
+// sparkColumn backtick-quotes a column name for col() and df[...] when it
+// contains characters Spark would parse, such as the dot of a nested field
+func sparkColumn(name string) string {
+	if strings.ContainsAny(name, ".` ") {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return name
+}
+
+// notebookCell is a single cell of an nbformat 4 notebook
+type notebookCell map[string]interface{}
+
+func markdownCell(lines ...string) notebookCell {
+	return notebookCell{
+		"cell_type": "markdown",
+		"metadata":  map[string]interface{}{},
+		"source":    notebookSource(lines),
+	}
+}
+
+func codeCell(lines ...string) notebookCell {
+	return notebookCell{
+		"cell_type":       "code",
+		"metadata":        map[string]interface{}{},
+		"source":          notebookSource(lines),
+		"execution_count": nil,
+		"outputs":         []interface{}{},
+	}
+}
+
+// notebookSource terminates every line but the last with a newline, as Jupyter stores them
+func notebookSource(lines []string) []string {
+	source := make([]string, len(lines))
+	for i, line := range lines {
+		if i < len(lines)-1 {
+			line += "\n"
+		}
+		source[i] = line
+	}
+	return source
+}
+
+// GenerateNotebook builds a Jupyter notebook (.ipynb JSON) that loads and joins the files
+func (s *ExportService) GenerateNotebook(graph *models.SimilarityGraph) string {
+	tables := graphTables(graph)
+	left, right := joinKeys(graph)
+
+	// Schema description
+	schema := []string{"# Generated by Project Euler", "", "## Schema"}
+	for _, t := range tables {
+		schema = append(schema, "", fmt.Sprintf("**%s** (`df%d`)", t.Group, t.Index))
+		for _, col := range t.Columns {
+			schema = append(schema, fmt.Sprintf("- `%s`", col))
+		}
+	}
+	if len(left) > 0 {
+		schema = append(schema, "", "## High confidence mappings")
+		for i := range left {
+			schema = append(schema, fmt.Sprintf("- `%s` ↔ `%s`", left[i], right[i]))
+		}
+	}
+
+	cells := []notebookCell{
+		markdownCell(schema...),
+		codeCell("import pandas as pd"),
+	}
+
+	for _, t := range tables {
+		cells = append(cells, codeCell(
+			fmt.Sprintf("# Load %s", t.Group),
+			fmt.Sprintf("df%d = pd.read_csv('file%d.csv')", t.Index, t.Index),
+			fmt.Sprintf("df%d.head()", t.Index),
+		))
+	}
+
+	if len(tables) >= 2 && len(left) > 0 {
+		join := []string{
+			"# Merge DataFrames",
+			"merged_df = pd.merge(",
+			fmt.Sprintf("    df%d,", tables[0].Index),
+			fmt.Sprintf("    df%d,", tables[1].Index),
+			fmt.Sprintf("    left_on=%s,", pythonList(left)),
+			fmt.Sprintf("    right_on=%s,", pythonList(right)),
+			"    how='inner'",
+			")",
+			"merged_df.head()",
+		}
+		cells = append(cells, codeCell(join...))
+	}
+
+	notebook := map[string]interface{}{
+		"nbformat":       4,
+		"nbformat_minor": 5,
+		"metadata": map[string]interface{}{
+			"kernelspec": map[string]string{
+				"display_name": "Python 3",
+				"language":     "python",
+				"name":         "python3",
+			},
+			"language_info": map[string]string{"name": "python"},
+		},
+		"cells": cells,
+	}
+
+	data, _ := json.MarshalIndent(notebook, "", " ")
+	return string(data)
+}
+
+// pythonList formats names as a Python list literal, e.g. ["a", "b"]
+func pythonList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = pythonString(n)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// pythonString formats s as a Python string literal. strconv.Quote's escapes
+// for quotes, backslashes and control characters (\n, \xNN, \uNNNN) mean
+// the same in Python.
+func pythonString(s string) string {
+	return strconv.Quote(s)
+}
+
+// graphTable is one file's worth of nodes in a similarity graph
+type graphTable struct {
+	Index   int
+	Group   string
+	Columns []string
+}
+
+// graphTables groups the graph's nodes by file, in the order they first appear.
+// Groups are labelled "File N"; Index falls back to the position if that label is missing.
+func graphTables(graph *models.SimilarityGraph) []graphTable {
+	var tables []graphTable
+	positions := make(map[string]int)
+
+	for _, node := range graph.Nodes {
+		pos, ok := positions[node.Group]
+		if !ok {
+			idx := len(tables) + 1
+			fmt.Sscanf(node.Group, "File %d", &idx)
+			pos = len(tables)
+			positions[node.Group] = pos
+			tables = append(tables, graphTable{Index: idx, Group: node.Group})
+		}
+		tables[pos].Columns = append(tables[pos].Columns, node.Label)
+	}
+	return tables
+}
+
+// joinKeys returns the file 1 and file 2 columns of every high-confidence mapping
+func joinKeys(graph *models.SimilarityGraph) (left, right []string) {
+	for _, sim := range graph.Similarities {
+		if sim.Confidence >= 70.0 {
+			left = append(left, sim.File1Column)
+			right = append(right, sim.File2Column)
+		}
+	}
+	return left, right
+}
+
+// rVector formats names as an R character vector, e.g. c("a", "b")
+func rVector(names []string) string {
+	if len(names) == 0 {
+		return "NULL"
+	}
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = fmt.Sprintf("%q", n)
+	}
+	return "c(" + strings.Join(quoted, ", ") + ")"
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"backend-go/internal/models"
+	"sort"
+)
+
+// DefaultFKContainmentThreshold is the share of a column's distinct values
+// (0-1) that must appear in another column for it to be reported as a
+// foreign key candidate
+const DefaultFKContainmentThreshold = 0.8
+
+// FKCandidate is a column whose values are largely contained in a column of
+// another file, suggesting it references that column
+type FKCandidate struct {
+	FromFile   int    `json:"from_file"`
+	FromColumn string `json:"from_column"`
+	ToFile     int    `json:"to_file"`
+	ToColumn   string `json:"to_column"`
+
+	// Share (0-1) of FromColumn's distinct values found in ToColumn
+	Containment float64 `json:"containment"`
+
+	// ToColumn is the inferred primary key or a primary key candidate of its file
+	ReferencesKey bool `json:"references_key"`
+}
+
+// FindFKCandidates compares every column with every column of the other
+// stored analyses and returns the pairs whose containment |A∩B| / |A| is at
+// least threshold, best first. Values come from the sample and top values of
+// each column profile, so columns with many distinct values are compared on
+// a sample. Constant and boolean columns are skipped since their few values
+// are contained almost anywhere.
+func (s *SimilarityService) FindFKCandidates(threshold float64) []FKCandidate {
+	analyses := s.ContextService.GetAllAnalyses()
+	indexes := make([]int, 0, len(analyses))
+	for idx := range analyses {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	sets := make(map[int]map[string]map[string]struct{}, len(analyses))
+	for _, idx := range indexes {
+		sets[idx] = make(map[string]map[string]struct{})
+		for i := range analyses[idx].ColumnProfiles {
+			col := &analyses[idx].ColumnProfiles[i]
+			if col.IsConstant || col.DistinctCount <= 1 || col.InferredType == models.ColumnTypeBoolean {
+				continue
+			}
+			if set := profileValueSet(col); len(set) > 0 {
+				sets[idx][col.Name] = set
+			}
+		}
+	}
+
+	candidates := []FKCandidate{}
+	for _, from := range indexes {
+		for _, to := range indexes {
+			if from == to {
+				continue
+			}
+			for _, fromCol := range analyses[from].ColumnNames {
+				fromSet, ok := sets[from][fromCol]
+				if !ok {
+					continue
+				}
+				for _, toCol := range analyses[to].ColumnNames {
+					toSet, ok := sets[to][toCol]
+					if !ok {
+						continue
+					}
+					containment := float64(intersectionSize(fromSet, toSet)) / float64(len(fromSet))
+					if containment < threshold {
+						continue
+					}
+					candidates = append(candidates, FKCandidate{
+						FromFile:      from,
+						FromColumn:    fromCol,
+						ToFile:        to,
+						ToColumn:      toCol,
+						Containment:   containment,
+						ReferencesKey: analyses[to].IsKeyColumn(toCol),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Containment > candidates[j].Containment
+	})
+	return candidates
+}
+
+// profileValueSet returns the normalized distinct sample and top values of a
+// column profile
+func profileValueSet(col *models.ColumnAnalysis) map[string]struct{} {
+	values := append([]string{}, col.SampleValues...)
+	for _, tv := range col.TopValues {
+		values = append(values, tv.Value)
+	}
+	return normalizedValueSet(values)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"backend-go/internal/models"
+	"context"
+	"slices"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// DefaultGraphCacheSize is the number of similarity graphs a GraphCache keeps
+const DefaultGraphCacheSize = 50
+
+// GraphCacheKey identifies a cached similarity graph
+type GraphCacheKey struct {
+	Namespace string
+	File1     int
+	File2     int
+	Threshold float64
+	Algorithm SimilarityAlgorithm
+}
+
+// graphCacheEntry is a cached graph and the inputs it was built from
+type graphCacheEntry struct {
+	graph     *models.SimilarityGraph
+	analysis1 *models.DataAnalysisResult
+	analysis2 *models.DataAnalysisResult
+	context1  *models.Context
+	context2  *models.Context
+}
+
+// GraphCache keeps the most recently used similarity graphs. An entry is
+// only served while the store still holds the analyses and contexts it was
+// built from, so re-uploading a file, storing its context or deleting either
+// makes its graphs miss.
+type GraphCache struct {
+	lru *lru.Cache[GraphCacheKey, graphCacheEntry]
+}
+
+// NewGraphCache returns a cache holding up to size graphs; size <= 0 means
+// DefaultGraphCacheSize
+func NewGraphCache(size int) *GraphCache {
+	if size <= 0 {
+		size = DefaultGraphCacheSize
+	}
+	cache, _ := lru.New[GraphCacheKey, graphCacheEntry](size) // Only fails for size <= 0
+	return &GraphCache{lru: cache}
+}
+
+// Graph returns the graph for key, generating it with sim on a miss. hit
+// reports whether it came from the cache. The returned graph is shared with
+// other callers and must not be modified.
+func (c *GraphCache) Graph(ctx context.Context, sim *SimilarityService, key GraphCacheKey) (graph *models.SimilarityGraph, hit bool, err error) {
+	store := sim.ContextService
+	current := graphCacheEntry{
+		analysis1: store.GetAnalysis(key.File1),
+		analysis2: store.GetAnalysis(key.File2),
+		context1:  store.GetContext(key.File1),
+		context2:  store.GetContext(key.File2),
+	}
+
+	if entry, ok := c.lru.Get(key); ok {
+		if entry.analysis1 == current.analysis1 && entry.analysis2 == current.analysis2 &&
+			entry.context1 == current.context1 && entry.context2 == current.context2 {
+			return entry.graph, true, nil
+		}
+		c.lru.Remove(key)
+	}
+
+	graph, err = sim.GenerateGraphContext(ctx, key.File1, key.File2, key.Threshold, key.Algorithm)
+	if err != nil {
+		return nil, false, err
+	}
+	current.graph = graph
+	c.lru.Add(key, current)
+	return graph, false, nil
+}
+
+// Invalidate removes the graphs of namespace that involve any of the given
+// file indices, or every graph of namespace when none are given. It returns
+// the number of graphs removed.
+func (c *GraphCache) Invalidate(namespace string, fileIndices ...int) int {
+	removed := 0
+	for _, key := range c.lru.Keys() {
+		if key.Namespace != namespace {
+			continue
+		}
+		if len(fileIndices) > 0 && !slices.Contains(fileIndices, key.File1) && !slices.Contains(fileIndices, key.File2) {
+			continue
+		}
+		if c.lru.Remove(key) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len returns the number of cached graphs
+func (c *GraphCache) Len() int {
+	return c.lru.Len()
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobStatus is the lifecycle state of a background job
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// Job is a snapshot of a background job
+type Job struct {
+	ID        string      `json:"job_id"`
+	Status    JobStatus   `json:"status"`
+	Result    interface{} `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Percent   int         `json:"percent"`         // 0-100
+	Stage     string      `json:"stage,omitempty"` // Latest stage reported by the job
+	UpdatedAt time.Time   `json:"updated_at"`
+
+	Namespace string `json:"-"` // Only requests from this namespace can see the job
+}
+
+// finished reports whether the job is done or failed
+func (j Job) finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed
+}
+
+// subscriberBuffer is how many updates a slow subscriber may fall behind
+// before intermediate ones are dropped
+const subscriberBuffer = 16
+
+// JobStore tracks background jobs in memory. Finished jobs are removed once
+// they have been untouched for longer than the TTL.
+type JobStore struct {
+	jobs sync.Map // id -> Job
+	ttl  time.Duration
+	stop chan struct{}
+	once sync.Once
+
+	mu   sync.Mutex
+	subs map[string][]chan Job // id -> channels of Subscribe callers
+}
+
+// NewJobStore creates a job store and starts its cleanup loop
+func NewJobStore(ttl time.Duration) *JobStore {
+	s := &JobStore{
+		ttl:  ttl,
+		stop: make(chan struct{}),
+		subs: make(map[string][]chan Job),
+	}
+	go s.cleanupLoop()
+	return s
+}
+
+// Create registers a new queued job owned by namespace and returns its ID
+func (s *JobStore) Create(namespace string) string {
+	id := uuid.NewString()
+	s.jobs.Store(id, Job{ID: id, Status: JobQueued, UpdatedAt: time.Now(), Namespace: namespace})
+	return id
+}
+
+// Get returns the current state of a job. Jobs of other namespaces are
+// reported as missing.
+func (s *JobStore) Get(namespace, id string) (Job, bool) {
+	job, ok := s.load(id)
+	if !ok || job.Namespace != namespace {
+		return Job{}, false
+	}
+	return job, true
+}
+
+// load returns a job whatever its namespace
+func (s *JobStore) load(id string) (Job, bool) {
+	val, ok := s.jobs.Load(id)
+	if !ok {
+		return Job{}, false
+	}
+	return val.(Job), true
+}
+
+// SetRunning marks a job as running
+func (s *JobStore) SetRunning(id string) {
+	s.update(Job{ID: id, Status: JobRunning, UpdatedAt: time.Now()})
+}
+
+// SetProgress records how far a running job has got
+func (s *JobStore) SetProgress(id string, percent int, stage string) {
+	s.update(Job{ID: id, Status: JobRunning, Percent: percent, Stage: stage, UpdatedAt: time.Now()})
+}
+
+// Complete marks a job as done with its result
+func (s *JobStore) Complete(id string, result interface{}) {
+	s.update(Job{ID: id, Status: JobDone, Result: result, Percent: 100, Stage: "done", UpdatedAt: time.Now()})
+}
+
+// Fail marks a job as failed
+func (s *JobStore) Fail(id string, err error) {
+	job, _ := s.load(id)
+	s.update(Job{ID: id, Status: JobFailed, Error: err.Error(), Percent: job.Percent, Stage: job.Stage, UpdatedAt: time.Now()})
+}
+
+// Subscribe returns the current state of a job of namespace and a channel that receives
+// each later update. The channel is closed once the job finishes, or straight
+// away if it already has; if the subscriber falls behind, intermediate
+// updates are dropped. cancel must be called when the caller stops reading.
+func (s *JobStore) Subscribe(namespace, id string) (current Job, updates <-chan Job, cancel func(), ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok = s.Get(namespace, id)
+	if !ok {
+		return Job{}, nil, nil, false
+	}
+
+	ch := make(chan Job, subscriberBuffer)
+	if current.finished() {
+		close(ch)
+		return current, ch, func() {}, true
+	}
+	s.subs[id] = append(s.subs[id], ch)
+
+	cancel = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		subs := s.subs[id]
+		for i, sub := range subs {
+			if sub == ch {
+				s.subs[id] = append(subs[:i:i], subs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+		if len(s.subs[id]) == 0 {
+			delete(s.subs, id)
+		}
+	}
+	return current, ch, cancel, true
+}
+
+// update stores job, keeping the namespace it was created with, and passes it
+// on to its subscribers, closing their channels once it has finished. Updates
+// to jobs removed by DeleteNamespace are dropped.
+func (s *JobStore) update(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.load(job.ID)
+	if !ok {
+		return
+	}
+	job.Namespace = prev.Namespace
+	s.jobs.Store(job.ID, job)
+	for _, ch := range s.subs[job.ID] {
+		select {
+		case ch <- job:
+		default: // Subscriber is behind; it will catch up on a later update
+		}
+		if job.finished() {
+			close(ch)
+		}
+	}
+	if job.finished() {
+		delete(s.subs, job.ID)
+	}
+}
+
+// DeleteNamespace removes every job of namespace, finished or not, and
+// returns how many there were. Jobs still running carry on, but their
+// progress and results are discarded.
+func (s *JobStore) DeleteNamespace(namespace string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	s.jobs.Range(func(key, val interface{}) bool {
+		if val.(Job).Namespace != namespace {
+			return true
+		}
+		s.jobs.Delete(key)
+		removed++
+		for _, ch := range s.subs[key.(string)] {
+			close(ch)
+		}
+		delete(s.subs, key.(string))
+		return true
+	})
+	return removed
+}
+
+// Close stops the cleanup loop
+func (s *JobStore) Close() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+func (s *JobStore) cleanupLoop() {
+	interval := s.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.removeExpired(now)
+		}
+	}
+}
+
+// removeExpired drops finished jobs older than the TTL; queued and running
+// jobs are kept until they finish
+func (s *JobStore) removeExpired(now time.Time) {
+	s.jobs.Range(func(key, val interface{}) bool {
+		job := val.(Job)
+		if (job.Status == JobDone || job.Status == JobFailed) && now.Sub(job.UpdatedAt) > s.ttl {
+			s.jobs.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQLDataSource implements DataSource for MySQL
+type MySQLDataSource struct {
+	db *sql.DB
+}
+
+func (m *MySQLDataSource) Connect(config DataSourceConfig) error {
+	// Builds the user:pass@tcp(host:port)/db DSN
+	cfg := mysql.NewConfig()
+	cfg.User = config.User
+	cfg.Passwd = config.Password
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:%d", config.Host, config.Port)
+	cfg.DBName = config.DBName
+	cfg.ParseTime = true // Scan DATE/DATETIME as time.Time so they are typed as dates
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return err
+	}
+	applyPoolConfig(db, config)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	m.db = db
+	return nil
+}
+
+func (m *MySQLDataSource) Close() error {
+	if m.db != nil {
+		return m.db.Close()
+	}
+	return nil
+}
+
+func (m *MySQLDataSource) Ping() error {
+	return pingDB(m.db, pingTimeout)
+}
+
+func (m *MySQLDataSource) ListTables() ([]string, error) {
+	tables, _, err := m.ListTablesPaged(0, 0)
+	return tables, err
+}
+
+// ListTablesPaged returns a page of table names plus the total table count.
+// A limit of 0 returns all tables from offset onwards.
+func (m *MySQLDataSource) ListTablesPaged(limit, offset int) ([]string, int, error) {
+	query := `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'
+		ORDER BY table_name
+	`
+	return pagedNames(m.db, query, limit, offset)
+}
+
+// ListTablesWithSchema returns every base table with the row estimate and
+// comment information_schema keeps for it
+func (m *MySQLDataSource) ListTablesWithSchema() ([]TableInfo, error) {
+	query := `
+		SELECT t.table_name,
+			COALESCE(t.table_rows, 0),
+			(SELECT COUNT(*) FROM information_schema.columns c
+				WHERE c.table_schema = t.table_schema AND c.table_name = t.table_name),
+			COALESCE(t.table_comment, '')
+		FROM information_schema.tables t
+		WHERE t.table_schema = DATABASE() AND t.table_type = 'BASE TABLE'
+		ORDER BY t.table_name
+	`
+	return scanTableInfos(m.db, query)
+}
+
+// ListViews returns the names of the views in the current database
+func (m *MySQLDataSource) ListViews() ([]string, error) {
+	query := `
+		SELECT table_name
+		FROM information_schema.views
+		WHERE table_schema = DATABASE()
+		ORDER BY table_name
+	`
+	views, _, err := pagedNames(m.db, query, 0, 0)
+	return views, err
+}
+
+func (m *MySQLDataSource) PreviewData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	query := fmt.Sprintf("SELECT * FROM %s LIMIT %d", dialectIdent(tableName, DialectMySQL), limit)
+
+	rows, err := m.db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows)
+}
+
+// SampleData returns limit rows chosen at random from the whole table
+func (m *MySQLDataSource) SampleData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	query := fmt.Sprintf("SELECT * FROM %s ORDER BY RAND() LIMIT %d", dialectIdent(tableName, DialectMySQL), limit)
+
+	rows, err := m.db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows)
+}
+
+func (m *MySQLDataSource) QueryRaw(query string, limit int) ([]string, []map[string]interface{}, error) {
+	return queryRaw(m.db, query, limit, true)
+}
+
+// DescribeSchema returns the columns and primary key of every base table.
+// Defaults are left out: MySQL reports them as bare values, not expressions.
+func (m *MySQLDataSource) DescribeSchema() (*DatabaseSchema, error) {
+	query := `
+		SELECT c.table_name, c.column_name, c.column_type, c.is_nullable, NULL
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = DATABASE() AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position
+	`
+	return scanSchema(m.db, "mysql", query, fmt.Sprintf(informationSchemaKeys, "tc.table_schema = DATABASE()"))
+}
+
+func (m *MySQLDataSource) ExportSchema() (string, error) {
+	return exportSchema(m)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// DefaultNamespace holds the analyses and contexts of requests that carry no
+// namespace, such as when API keys are disabled
+const DefaultNamespace = "default"
+
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateNamespace checks that a namespace is 1-64 letters, digits, '-' or
+// '_', so it is safe to use as a directory name
+func ValidateNamespace(namespace string) error {
+	if !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("invalid namespace %q: must be 1-64 letters, digits, '-' or '_'", namespace)
+	}
+	return nil
+}
+
+// NamespacedContextService keeps a separate analysis store per namespace, so
+// tenants sharing a server cannot see each other's analyses and contexts.
+// Stores are created by newStore on first use.
+type NamespacedContextService struct {
+	newStore func(namespace string) (*AnalysisCache, error)
+
+	mu     sync.Mutex
+	stores map[string]*AnalysisCache
+}
+
+// NewNamespacedContextService creates an empty set of namespaces
+func NewNamespacedContextService(newStore func(namespace string) (*AnalysisCache, error)) *NamespacedContextService {
+	return &NamespacedContextService{newStore: newStore, stores: make(map[string]*AnalysisCache)}
+}
+
+// For returns the store of namespace ("" means DefaultNamespace), creating it
+// if needed
+func (s *NamespacedContextService) For(namespace string) (*AnalysisCache, error) {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	if err := ValidateNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if store, ok := s.stores[namespace]; ok {
+		return store, nil
+	}
+	store, err := s.newStore(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+	}
+	s.stores[namespace] = store
+	return store, nil
+}
+
+// Namespaces returns the namespaces that have a store, sorted
+func (s *NamespacedContextService) Namespaces() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.stores))
+	for name := range s.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
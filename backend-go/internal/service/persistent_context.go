@@ -0,0 +1,298 @@
+package service
+
+import (
+	"backend-go/internal/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// ContextStore is the storage used by the API for analyses and contexts.
+// ContextService keeps them in memory; PersistentContextService also writes
+// them to disk.
+type ContextStore interface {
+	StoreContext(fileIndex int, ctx *models.Context) error
+	GetContext(fileIndex int) *models.Context
+	DeleteContext(fileIndex int) error
+	GetAllContexts() map[int]*models.Context
+
+	StoreAnalysis(fileIndex int, analysis *models.DataAnalysisResult) error
+	GetAnalysis(fileIndex int) *models.DataAnalysisResult
+	DeleteAnalysis(fileIndex int) error
+	GetAllAnalyses() map[int]*models.DataAnalysisResult
+	AnalysisIndices() []int
+	AnnotateColumn(fileIndex int, column, annotation string) error
+
+	StoreTemplate(name string, ctx *models.Context) error
+	GetTemplate(name string) *models.Context
+	TemplateNames() []string
+
+	// WipeAll removes every analysis, context, annotation and template
+	WipeAll() error
+}
+
+var _ ContextStore = (*ContextService)(nil)
+var _ ContextStore = (*PersistentContextService)(nil)
+
+// PersistentContextService wraps a ContextService, mirroring every write to
+// analysis_{index}.json, context_{index}.json and template_{name}.json in a
+// directory so that results survive restarts. Numeric samples, which the
+// analysis JSON leaves out, go to samples_{index}.json. Reads are served from
+// memory.
+type PersistentContextService struct {
+	*ContextService
+	dir string
+
+	// Serializes writes so the file on disk matches the last in-memory update
+	writeMu sync.Mutex
+}
+
+var storedFilePattern = regexp.MustCompile(`^(analysis|context|samples)_(\d+)\.json$`)
+
+var templateFilePattern = regexp.MustCompile(`^template_([A-Za-z0-9_-]{1,64})\.json$`)
+
+// wipedFilePattern also matches temp files left behind by an interrupted write
+var wipedFilePattern = regexp.MustCompile(`^((analysis|context|samples)_\d+|template_[A-Za-z0-9_-]{1,64})\.json(\.\d+\.tmp)?$`)
+
+// NewPersistentContextService creates dir if needed and loads every stored
+// analysis and context in it into inner
+func NewPersistentContextService(inner *ContextService, dir string) (*PersistentContextService, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating storage dir: %w", err)
+	}
+	s := &PersistentContextService{ContextService: inner, dir: dir}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the stored files into the in-memory service
+func (s *PersistentContextService) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("reading storage dir: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Attached once every analysis has been read
+	samples := map[int]map[string][]*float64{}
+	defer func() {
+		for fileIndex, columns := range samples {
+			if analysis, ok := s.analyses[fileIndex]; ok {
+				for i := range analysis.ColumnProfiles {
+					analysis.ColumnProfiles[i].NumericSample = columns[analysis.ColumnProfiles[i].Name]
+				}
+			}
+		}
+	}()
+
+	for _, entry := range entries {
+		if m := templateFilePattern.FindStringSubmatch(entry.Name()); m != nil && !entry.IsDir() {
+			data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", entry.Name(), err)
+			}
+			var ctx models.Context
+			if err := json.Unmarshal(data, &ctx); err != nil {
+				return fmt.Errorf("decoding %s: %w", entry.Name(), err)
+			}
+			s.Templates[m[1]] = &ctx
+			continue
+		}
+
+		m := storedFilePattern.FindStringSubmatch(entry.Name())
+		if m == nil || entry.IsDir() {
+			continue
+		}
+		fileIndex, _ := strconv.Atoi(m[2])
+		if ValidateFileIndex(fileIndex) != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", entry.Name(), err)
+		}
+		switch m[1] {
+		case "analysis":
+			var analysis models.DataAnalysisResult
+			if err := json.Unmarshal(data, &analysis); err != nil {
+				return fmt.Errorf("decoding %s: %w", entry.Name(), err)
+			}
+			s.analyses[fileIndex] = &analysis
+		case "context":
+			var ctx models.Context
+			if err := json.Unmarshal(data, &ctx); err != nil {
+				return fmt.Errorf("decoding %s: %w", entry.Name(), err)
+			}
+			s.contexts[fileIndex] = &ctx
+		case "samples":
+			var columns map[string][]*float64
+			if err := json.Unmarshal(data, &columns); err != nil {
+				return fmt.Errorf("decoding %s: %w", entry.Name(), err)
+			}
+			samples[fileIndex] = columns
+		}
+	}
+	return nil
+}
+
+// StoreContext merges ctx into the stored context and writes the result to disk
+func (s *PersistentContextService) StoreContext(fileIndex int, ctx *models.Context) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.ContextService.StoreContext(fileIndex, ctx); err != nil {
+		return err
+	}
+	return s.writeJSON(contextFile(fileIndex), s.ContextService.GetContext(fileIndex))
+}
+
+// DeleteContext removes the context from memory and disk
+func (s *PersistentContextService) DeleteContext(fileIndex int) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.ContextService.DeleteContext(fileIndex); err != nil {
+		return err
+	}
+	return s.remove(contextFile(fileIndex))
+}
+
+// StoreTemplate stores the template and writes it to disk
+func (s *PersistentContextService) StoreTemplate(name string, ctx *models.Context) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.ContextService.StoreTemplate(name, ctx); err != nil {
+		return err
+	}
+	return s.writeJSON(templateFile(name), ctx)
+}
+
+// StoreAnalysis stores the analysis and writes it and its numeric samples to disk
+func (s *PersistentContextService) StoreAnalysis(fileIndex int, analysis *models.DataAnalysisResult) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.ContextService.StoreAnalysis(fileIndex, analysis); err != nil {
+		return err
+	}
+	if err := s.writeJSON(analysisFile(fileIndex), analysis); err != nil {
+		return err
+	}
+
+	samples := map[string][]*float64{}
+	for _, col := range analysis.ColumnProfiles {
+		if len(col.NumericSample) > 0 {
+			samples[col.Name] = col.NumericSample
+		}
+	}
+	if len(samples) == 0 {
+		return s.remove(samplesFile(fileIndex))
+	}
+	return s.writeJSON(samplesFile(fileIndex), samples)
+}
+
+// DeleteAnalysis removes the analysis from memory and disk
+func (s *PersistentContextService) DeleteAnalysis(fileIndex int) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.ContextService.DeleteAnalysis(fileIndex); err != nil {
+		return err
+	}
+	if err := s.remove(analysisFile(fileIndex)); err != nil {
+		return err
+	}
+	return s.remove(samplesFile(fileIndex))
+}
+
+// AnnotateColumn annotates the stored analysis and rewrites it on disk
+func (s *PersistentContextService) AnnotateColumn(fileIndex int, column, annotation string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.ContextService.AnnotateColumn(fileIndex, column, annotation); err != nil {
+		return err
+	}
+	return s.writeJSON(analysisFile(fileIndex), s.ContextService.GetAnalysis(fileIndex))
+}
+
+// WipeAll clears memory and deletes every stored analysis, context and
+// template file. Other files in the storage dir, including other namespaces,
+// are left alone.
+func (s *PersistentContextService) WipeAll() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := s.ContextService.WipeAll(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("reading storage dir: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !wipedFilePattern.MatchString(entry.Name()) {
+			continue
+		}
+		if err := s.remove(entry.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func analysisFile(fileIndex int) string { return fmt.Sprintf("analysis_%d.json", fileIndex) }
+func contextFile(fileIndex int) string  { return fmt.Sprintf("context_%d.json", fileIndex) }
+func templateFile(name string) string   { return fmt.Sprintf("template_%s.json", name) }
+func samplesFile(fileIndex int) string  { return fmt.Sprintf("samples_%d.json", fileIndex) }
+
+// writeJSON atomically replaces name in the storage dir: the data is written
+// to a temp file in the same directory, synced, then renamed over name
+func (s *PersistentContextService) writeJSON(name string, v any) error {
+	s.mu.RLock()
+	data, err := json.Marshal(v)
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", name, err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *PersistentContextService) remove(name string) error {
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"backend-go/internal/models"
+	"fmt"
+	"strings"
+)
+
+// maxAnalyticalQuestions caps the analytical questions generated per difficulty
+const maxAnalyticalQuestions = 5
+
+// ParseDifficulty validates a difficulty level; "" means no analytical questions
+func ParseDifficulty(level string) (string, error) {
+	switch level = strings.ToLower(level); level {
+	case "", models.DifficultyEasy, models.DifficultyMedium, models.DifficultyHard:
+		return level, nil
+	default:
+		return "", fmt.Errorf("unknown difficulty %q: must be easy, medium or hard", level)
+	}
+}
+
+// analyticalQuestions suggests questions to answer from the data at the given
+// difficulty, each with a SQL hint against a table named fileN
+func (s *QuestionGenerator) analyticalQuestions(analysis models.DataAnalysisResult, fileIndex int, difficulty string) []models.Question {
+	table := fmt.Sprintf("file%d", fileIndex)
+	cols := columnRoles(analysis)
+
+	type candidate struct {
+		text, hint string
+		columns    []string
+	}
+	var candidates []candidate
+
+	switch difficulty {
+	case models.DifficultyEasy:
+		for _, m := range cols.measures {
+			candidates = append(candidates, candidate{
+				fmt.Sprintf("What is the total and average of %s?", m),
+				fmt.Sprintf("SELECT SUM(%[1]s), AVG(%[1]s) FROM %[2]s;", sqlIdent(m), table),
+				[]string{m},
+			})
+		}
+		for _, c := range cols.nullable {
+			candidates = append(candidates, candidate{
+				fmt.Sprintf("How many rows have no value for %s?", c),
+				fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s IS NULL;", table, sqlIdent(c)),
+				[]string{c},
+			})
+		}
+
+	case models.DifficultyMedium:
+		for _, g := range cols.categories {
+			for _, m := range cols.measures {
+				candidates = append(candidates, candidate{
+					fmt.Sprintf("What is the average %s for each %s?", m, g),
+					fmt.Sprintf("SELECT %[1]s, AVG(%[2]s) FROM %[3]s GROUP BY %[1]s ORDER BY 2 DESC;", sqlIdent(g), sqlIdent(m), table),
+					[]string{m, g},
+				})
+			}
+			candidates = append(candidates, candidate{
+				fmt.Sprintf("How many rows are there for each %s?", g),
+				fmt.Sprintf("SELECT %[1]s, COUNT(*) FROM %[2]s GROUP BY %[1]s ORDER BY 2 DESC;", sqlIdent(g), table),
+				[]string{g},
+			})
+		}
+		for _, m := range cols.measures {
+			if col := analysis.Column(m); col != nil && col.Mean != nil {
+				candidates = append(candidates, candidate{
+					fmt.Sprintf("How many rows have %s above its average of %.2f?", m, *col.Mean),
+					fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s > %g;", table, sqlIdent(m), *col.Mean),
+					[]string{m},
+				})
+			}
+		}
+
+	case models.DifficultyHard:
+		for _, k := range cols.keys {
+			candidates = append(candidates, candidate{
+				fmt.Sprintf("Which rows in File %d have a %s with no match in the related file?", fileIndex, k),
+				fmt.Sprintf("SELECT a.* FROM %[1]s a LEFT JOIN other_file b ON a.%[2]s = b.%[2]s WHERE b.%[2]s IS NULL;", table, sqlIdent(k)),
+				[]string{k},
+			})
+		}
+		for _, m := range cols.measures {
+			for _, g := range cols.categories {
+				candidates = append(candidates, candidate{
+					fmt.Sprintf("What are the top 3 rows by %s within each %s?", m, g),
+					fmt.Sprintf("SELECT * FROM (SELECT *, RANK() OVER (PARTITION BY %[1]s ORDER BY %[2]s DESC) AS rnk FROM %[3]s) t WHERE rnk <= 3;", sqlIdent(g), sqlIdent(m), table),
+					[]string{m, g},
+				})
+			}
+			for _, d := range cols.dates {
+				candidates = append(candidates, candidate{
+					fmt.Sprintf("What is the running total of %s over %s?", m, d),
+					fmt.Sprintf("SELECT %[1]s, SUM(%[2]s) OVER (ORDER BY %[1]s) AS running_total FROM %[3]s;", sqlIdent(d), sqlIdent(m), table),
+					[]string{m, d},
+				})
+			}
+		}
+	}
+
+	questions := []models.Question{}
+	for i, c := range candidates {
+		if i == maxAnalyticalQuestions {
+			break
+		}
+		questions = append(questions, models.Question{
+			ID:         fmt.Sprintf("f%d_%s_%d", fileIndex, difficulty, i+1),
+			Type:       models.QuestionTypeAnalytical,
+			Text:       c.text,
+			Options:    []string{},
+			Required:   false,
+			Difficulty: difficulty,
+			Metadata:   map[string]interface{}{"sql_hint": c.hint, "columns": c.columns},
+		})
+	}
+	return questions
+}
+
+// questionColumns groups a file's columns by the role they can play in a query
+type questionColumns struct {
+	measures   []string // Numeric, not keys
+	categories []string // Low cardinality, good for GROUP BY
+	dates      []string
+	keys       []string // Primary key or ID-like, good for joins
+	nullable   []string
+}
+
+func columnRoles(analysis models.DataAnalysisResult) questionColumns {
+	var cols questionColumns
+	ids := make(map[string]bool, len(analysis.PotentialIDs))
+	for _, id := range analysis.PotentialIDs {
+		ids[id] = true
+	}
+	if analysis.InferredPrimaryKey != "" {
+		ids[analysis.InferredPrimaryKey] = true
+	}
+
+	for _, col := range analysis.ColumnProfiles {
+		// A constant column has nothing to ask about
+		if col.IsConstant {
+			continue
+		}
+		switch {
+		case ids[col.Name]:
+			cols.keys = append(cols.keys, col.Name)
+		case col.Mean != nil:
+			cols.measures = append(cols.measures, col.Name)
+		case len(col.TopValues) > 0:
+			cols.categories = append(cols.categories, col.Name)
+		case col.InferredType == models.ColumnTypeDate:
+			cols.dates = append(cols.dates, col.Name)
+		}
+		if col.Nullable {
+			cols.nullable = append(cols.nullable, col.Name)
+		}
+	}
+	return cols
+}
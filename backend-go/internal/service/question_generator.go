@@ -6,16 +6,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 type QuestionGenerator struct {
 	llmService *llm.Service
+
+	// Questions whose normalized text is within this Levenshtein distance of
+	// an earlier question are dropped; 0 disables deduplication
+	DedupThreshold int
 }
 
+// DefaultDedupThreshold is the DedupThreshold set by NewQuestionGenerator
+const DefaultDedupThreshold = 10
+
 func NewQuestionGenerator(llmService *llm.Service) *QuestionGenerator {
 	return &QuestionGenerator{
-		llmService: llmService,
+		llmService:     llmService,
+		DedupThreshold: DefaultDedupThreshold,
 	}
 }
 
@@ -33,8 +43,9 @@ var DomainOptions = []string{
 	"Other",
 }
 
-// GenerateQuestions generates context questions for a dataset
-func (s *QuestionGenerator) GenerateQuestions(analysis models.DataAnalysisResult, fileIndex int) []models.Question {
+// GenerateQuestions generates context questions for a dataset. A non-empty
+// difficulty (easy, medium or hard) adds analytical questions at that level.
+func (s *QuestionGenerator) GenerateQuestions(analysis models.DataAnalysisResult, fileIndex int, difficulty string) []models.Question {
 	questions := []models.Question{}
 
 	// Q1: Dataset Purpose
@@ -88,7 +99,7 @@ func (s *QuestionGenerator) GenerateQuestions(analysis models.DataAnalysisResult
 			})
 		}
 
-		ambiguous := s.findAmbiguousColumns(analysis.ColumnNames)
+		ambiguous := s.findAmbiguousColumns(unannotatedColumns(analysis))
 		if len(ambiguous) > 0 {
 			colList := strings.Join(takeFirst(ambiguous, 5), ", ")
 			questions = append(questions, models.Question{
@@ -105,6 +116,14 @@ func (s *QuestionGenerator) GenerateQuestions(analysis models.DataAnalysisResult
 		}
 	}
 
+	// Numeric columns: confirm the observed averages are plausible
+	questions = append(questions, s.numericQuestions(analysis, fileIndex)...)
+	questions = append(questions, s.outlierQuestions(analysis, fileIndex)...)
+
+	if difficulty != "" {
+		questions = append(questions, s.analyticalQuestions(analysis, fileIndex, difficulty)...)
+	}
+
 	// Exclusions
 	questions = append(questions, models.Question{
 		ID:       fmt.Sprintf("f%d_exclusions", fileIndex),
@@ -118,7 +137,58 @@ func (s *QuestionGenerator) GenerateQuestions(analysis models.DataAnalysisResult
 		},
 	})
 
-	return questions
+	return s.dedupQuestions(questions)
+}
+
+// dedupQuestions drops questions that are near-duplicates of an earlier one
+// about the same columns: questions are grouped by type and the columns in
+// their metadata, and normalized text is compared by Levenshtein distance only
+// within a group. Required questions are always kept.
+func (s *QuestionGenerator) dedupQuestions(questions []models.Question) []models.Question {
+	if s.DedupThreshold <= 0 {
+		return questions
+	}
+
+	kept := make([]models.Question, 0, len(questions))
+	seen := make(map[string][]string)
+	for _, q := range questions {
+		group := q.Type + "\x00" + strings.Join(questionSubject(q), "\x00")
+		norm := normalizeQuestionText(q.Text)
+		duplicate := false
+		for _, prev := range seen[group] {
+			if levenshtein(norm, prev) < s.DedupThreshold {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate && !q.Required {
+			continue
+		}
+		kept = append(kept, q)
+		seen[group] = append(seen[group], norm)
+	}
+	return kept
+}
+
+// questionSubject returns the columns a question is about, from its "column"
+// or "columns" metadata
+func questionSubject(q models.Question) []string {
+	if column, ok := q.Metadata["column"].(string); ok {
+		return []string{column}
+	}
+	columns, _ := q.Metadata["columns"].([]string)
+	return columns
+}
+
+// normalizeQuestionText lower-cases text, strips punctuation and collapses whitespace
+func normalizeQuestionText(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
 }
 
 func (s *QuestionGenerator) generateAIQuestions(analysis models.DataAnalysisResult, fileIndex int) []models.Question {
@@ -130,6 +200,8 @@ Dataset Summary:
 - Row Count: %d
 - Date Columns: %s
 - ID Columns: %s
+- Categorical Columns: %s
+- Column Notes: %s
 
 Generate 3 questions that would help clarify:
 1. The specific business process this data represents
@@ -155,7 +227,7 @@ Example JSON:
 }
 
 Return ONLY the JSON.
-`, strings.Join(takeFirst(analysis.ColumnNames, 20), ", "), analysis.NumRows, strings.Join(analysis.PotentialDates, ", "), strings.Join(analysis.PotentialIDs, ", "))
+`, strings.Join(takeFirst(analysis.ColumnNames, 20), ", "), analysis.NumRows, strings.Join(analysis.PotentialDates, ", "), strings.Join(analysis.PotentialIDs, ", "), categoricalSummary(analysis), annotationSummary(analysis))
 
 	response, err := s.llmService.CallOllama(prompt)
 	if err != nil || response == "" {
@@ -209,6 +281,112 @@ Return ONLY the JSON.
 	return aiQuestions
 }
 
+// maxNumericQuestions caps how many numeric columns get an average question
+const maxNumericQuestions = 3
+
+// numericQuestions asks about the average of numeric columns, skipping key columns
+func (s *QuestionGenerator) numericQuestions(analysis models.DataAnalysisResult, fileIndex int) []models.Question {
+	questions := []models.Question{}
+	for _, col := range analysis.ColumnProfiles {
+		if len(questions) == maxNumericQuestions {
+			break
+		}
+		if col.Mean == nil || col.IsPrimaryKeyCandidate || col.IsConstant {
+			continue
+		}
+		questions = append(questions, models.Question{
+			ID:         fmt.Sprintf("f%d_average_%s", fileIndex, col.Name),
+			Type:       models.QuestionTypeColumnSemantic,
+			Text:       fmt.Sprintf("What is the average value of column %s? (Observed mean: %.2f, range %.2f to %.2f)", col.Name, *col.Mean, *col.Min, *col.Max),
+			Options:    []string{},
+			Required:   false,
+			Difficulty: models.DifficultyEasy,
+			Metadata: map[string]interface{}{
+				"column":  col.Name,
+				"mean":    *col.Mean,
+				"median":  *col.Median,
+				"std_dev": *col.StdDev,
+			},
+		})
+	}
+	return questions
+}
+
+// outlierQuestions asks whether the outliers found in each numeric column are
+// valid values or data errors
+func (s *QuestionGenerator) outlierQuestions(analysis models.DataAnalysisResult, fileIndex int) []models.Question {
+	questions := []models.Question{}
+	for _, col := range analysis.ColumnProfiles {
+		if col.OutlierCount == 0 || col.IsConstant {
+			continue
+		}
+		examples := make([]string, len(col.OutlierExamples))
+		for i, val := range col.OutlierExamples {
+			examples[i] = strconv.FormatFloat(val, 'g', -1, 64)
+		}
+		questions = append(questions, models.Question{
+			ID:       fmt.Sprintf("f%d_outliers_%s", fileIndex, col.Name),
+			Type:     models.QuestionTypeColumnSemantic,
+			Text:     fmt.Sprintf("Column %s has %d outlying values (e.g. %s). Are these valid, or data errors?", col.Name, col.OutlierCount, strings.Join(examples, ", ")),
+			Options:  []string{"Valid values", "Data errors", "Not sure"},
+			Required: false,
+			Metadata: map[string]interface{}{
+				"column":           col.Name,
+				"outlier_count":    col.OutlierCount,
+				"outlier_examples": col.OutlierExamples,
+			},
+		})
+	}
+	return questions
+}
+
+// categoricalSummary lists low-cardinality columns with their most common
+// values, e.g. "region (east, north, south)"
+func categoricalSummary(analysis models.DataAnalysisResult) string {
+	parts := []string{}
+	for _, col := range analysis.ColumnProfiles {
+		if len(col.TopValues) == 0 {
+			continue
+		}
+		values := []string{}
+		for _, tv := range col.TopValues {
+			values = append(values, tv.Value)
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", col.Name, strings.Join(takeFirst(values, 5), ", ")))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// annotationSummary lists the user's column annotations, e.g.
+// "revenue: Total net revenue in USD"
+func annotationSummary(analysis models.DataAnalysisResult) string {
+	parts := []string{}
+	for _, col := range analysis.ColumnProfiles {
+		if col.Annotation != "" {
+			parts = append(parts, fmt.Sprintf("%s: %s", col.Name, col.Annotation))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// unannotatedColumns returns the column names that have no annotation yet;
+// annotated and constant columns need no semantic question
+func unannotatedColumns(analysis models.DataAnalysisResult) []string {
+	cols := []string{}
+	for _, name := range analysis.ColumnNames {
+		if col := analysis.Column(name); col == nil || (col.Annotation == "" && !col.IsConstant) {
+			cols = append(cols, name)
+		}
+	}
+	return cols
+}
+
 func (s *QuestionGenerator) findAmbiguousColumns(cols []string) []string {
 	ambiguous := []string{}
 	for _, col := range cols {
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"backend-go/internal/models"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// analysisReportHeader names the columns of the per-column analysis report
+var analysisReportHeader = []string{
+	"name", "type", "null_count", "null_percent", "distinct_count", "min", "max", "mean", "pii_flags",
+}
+
+// reportSheet is the worksheet name used by GenerateAnalysisXLSX
+const reportSheet = "Analysis"
+
+// highNullPercent is the null_percent above which the spreadsheet highlights a column
+const highNullPercent = 50
+
+// analysisReport returns one row per column profile in analysisReportHeader
+// order. Missing statistics are nil so each format can render them itself.
+func analysisReport(analysis *models.DataAnalysisResult) [][]interface{} {
+	rows := make([][]interface{}, 0, len(analysis.ColumnProfiles))
+	for _, col := range analysis.ColumnProfiles {
+		colType := string(col.InferredType)
+		if colType == "" {
+			colType = col.Type
+		}
+		rows = append(rows, []interface{}{
+			col.Name,
+			colType,
+			col.NullCount,
+			col.NullPercent,
+			col.DistinctCount,
+			floatOrNil(col.Min),
+			floatOrNil(col.Max),
+			floatOrNil(col.Mean),
+			strings.Join(col.PIIFlags, ";"),
+		})
+	}
+	return rows
+}
+
+// floatOrNil dereferences an optional statistic, keeping nil as an untyped nil
+func floatOrNil(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// AnalysisReportCSV returns the per-column analysis as CSV records, header first
+func (s *ExportService) AnalysisReportCSV(analysis *models.DataAnalysisResult) [][]string {
+	records := [][]string{analysisReportHeader}
+	for _, row := range analysisReport(analysis) {
+		record := make([]string, len(row))
+		for i, val := range row {
+			switch v := val.(type) {
+			case nil:
+				record[i] = ""
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// GenerateAnalysisXLSX renders the per-column analysis as a spreadsheet with a
+// bold, frozen header row and null_percent cells over 50 highlighted in red
+func (s *ExportService) GenerateAnalysisXLSX(analysis *models.DataAnalysisResult) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName("Sheet1", reportSheet); err != nil {
+		return nil, err
+	}
+
+	header := make([]interface{}, len(analysisReportHeader))
+	for i, name := range analysisReportHeader {
+		header[i] = name
+	}
+	if err := f.SetSheetRow(reportSheet, "A1", &header); err != nil {
+		return nil, err
+	}
+
+	rows := analysisReport(analysis)
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(reportSheet, cell, &row); err != nil {
+			return nil, err
+		}
+	}
+
+	lastCol, _ := excelize.ColumnNumberToName(len(analysisReportHeader))
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+	if err := f.SetCellStyle(reportSheet, "A1", lastCol+"1", bold); err != nil {
+		return nil, err
+	}
+	if err := f.SetPanes(reportSheet, &excelize.Panes{
+		Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft",
+	}); err != nil {
+		return nil, err
+	}
+	if err := f.SetColWidth(reportSheet, "A", "A", 24); err != nil {
+		return nil, err
+	}
+
+	if len(rows) > 0 {
+		red, err := f.NewConditionalStyle(&excelize.Style{
+			Font: &excelize.Font{Color: "9C0006"},
+			Fill: excelize.Fill{Type: "pattern", Color: []string{"FFC7CE"}, Pattern: 1},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// null_percent is the fourth column
+		nullRange := fmt.Sprintf("D2:D%d", len(rows)+1)
+		if err := f.SetConditionalFormat(reportSheet, nullRange, []excelize.ConditionalFormatOptions{
+			{Type: "cell", Criteria: ">", Format: &red, Value: strconv.Itoa(highNullPercent)},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
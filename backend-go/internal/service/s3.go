@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ErrObjectTooLarge is returned when an S3 object exceeds the download limit
+var ErrObjectTooLarge = errors.New("object too large")
+
+// ParseS3URL splits an s3://bucket/key URL into bucket and key
+func ParseS3URL(raw string) (bucket, key string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid s3_url: %w", err)
+	}
+	key = strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return "", "", fmt.Errorf("invalid s3_url %q: must look like s3://bucket/key", raw)
+	}
+	return u.Host, key, nil
+}
+
+// DownloadS3Object copies an S3 object into dst, refusing objects larger than
+// maxBytes. Credentials and region come from the standard AWS environment
+// variables and shared config files.
+func DownloadS3Object(ctx context.Context, bucket, key string, dst io.Writer, maxBytes int64) error {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("loading AWS config: %w", err)
+	}
+
+	out, err := s3.NewFromConfig(cfg).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("getting s3://%s/%s: %w", bucket, key, err)
+	}
+	defer out.Body.Close()
+
+	if size := aws.ToInt64(out.ContentLength); size > maxBytes {
+		return fmt.Errorf("s3://%s/%s is %d bytes: %w", bucket, key, size, ErrObjectTooLarge)
+	}
+	// Guard against a missing or wrong Content-Length as well
+	n, err := io.Copy(dst, io.LimitReader(out.Body, maxBytes+1))
+	if err != nil {
+		return fmt.Errorf("downloading s3://%s/%s: %w", bucket, key, err)
+	}
+	if n > maxBytes {
+		return fmt.Errorf("s3://%s/%s: %w", bucket, key, ErrObjectTooLarge)
+	}
+	return nil
+}
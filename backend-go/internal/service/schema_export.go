@@ -0,0 +1,610 @@
+package service
+
+import (
+	"backend-go/internal/models"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// GenerateGORM emits Go source with one GORM model struct per file in the graph.
+// Column types come from the stored analyses keyed by file index; columns
+// without analysis data fall back to string.
+func (s *ExportService) GenerateGORM(graph *models.SimilarityGraph, analyses map[int]*models.DataAnalysisResult) string {
+	var body strings.Builder
+	usesTime := false
+
+	for _, t := range graphTables(graph) {
+		analysis := analyses[t.Index]
+
+		body.WriteString(fmt.Sprintf("// File%d is generated from %s\n", t.Index, t.Group))
+		body.WriteString(fmt.Sprintf("type File%d struct {\n", t.Index))
+
+		used := make(map[string]int)
+		for _, col := range t.Columns {
+			goType := "string"
+			nullable := false
+			if analysis != nil {
+				if profile := analysis.Column(col); profile != nil {
+					goType = gormType(profile)
+					nullable = profile.Nullable
+				}
+			}
+			if goType == "time.Time" {
+				usesTime = true
+			}
+			if nullable {
+				goType = "*" + goType
+			}
+
+			field := goFieldName(col)
+			if n := used[field]; n > 0 {
+				used[field] = n + 1
+				field = fmt.Sprintf("%s%d", field, n+1)
+			} else {
+				used[field] = 1
+			}
+
+			tag := "column:" + col
+			if analysis != nil && col == analysis.InferredPrimaryKey {
+				tag += ";primaryKey"
+			}
+			body.WriteString(fmt.Sprintf("\t%s %s `gorm:\"%s\"`\n", field, goType, tag))
+		}
+		body.WriteString("}\n\n")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// Code generated by Project Euler. DO NOT EDIT.\n\n")
+	sb.WriteString("package models\n\n")
+	if usesTime {
+		sb.WriteString("import \"time\"\n\n")
+	}
+	sb.WriteString(body.String())
+
+	// gofmt the output so it can be dropped straight into a project
+	formatted, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return sb.String()
+	}
+	return string(formatted)
+}
+
+// gormType maps a column's inferred type to a Go type, falling back to the
+// basic type for analyses made before type inference existed
+func gormType(profile *models.ColumnAnalysis) string {
+	switch profile.InferredType {
+	case models.ColumnTypeInteger:
+		return "int64"
+	case models.ColumnTypeFloat:
+		return "float64"
+	case models.ColumnTypeDate:
+		return "time.Time"
+	case models.ColumnTypeBoolean:
+		return "bool"
+	case models.ColumnTypeText:
+		return "string"
+	}
+
+	switch profile.Type {
+	case "int":
+		return "int64"
+	case "float":
+		return "float64"
+	case "date":
+		return "time.Time"
+	default:
+		return "string"
+	}
+}
+
+// sqlType maps a column's inferred type to an ANSI SQL column type
+func sqlType(profile *models.ColumnAnalysis) string {
+	switch gormType(profile) {
+	case "int64":
+		return "BIGINT"
+	case "float64":
+		return "DOUBLE PRECISION"
+	case "time.Time":
+		if isDateOnlyLayout(profile.DateFormat) {
+			return "DATE"
+		}
+		return "TIMESTAMP"
+	case "bool":
+		return "BOOLEAN"
+	default:
+		return "TEXT"
+	}
+}
+
+// isDateOnlyLayout reports whether a detected date layout has no time of day.
+// Every time-bearing layout in analysis.DetectDateFormat includes minutes (":04").
+func isDateOnlyLayout(layout string) bool {
+	return layout != "" && !strings.Contains(layout, ":04")
+}
+
+// sqlIdent double-quotes an identifier for SQL
+func sqlIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// goInitialisms are kept upper case in generated identifiers, as golint expects
+var goInitialisms = map[string]bool{
+	"ID": true, "URL": true, "UUID": true, "API": true, "IP": true, "HTTP": true, "JSON": true, "SQL": true,
+}
+
+// goFieldName converts a column name such as "emp_id" into an exported Go identifier ("EmpID")
+func goFieldName(col string) string {
+	words := strings.FieldsFunc(col, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var sb strings.Builder
+	for _, word := range words {
+		upper := strings.ToUpper(word)
+		if goInitialisms[upper] {
+			sb.WriteString(upper)
+			continue
+		}
+		runes := []rune(strings.ToLower(word))
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+
+	name := sb.String()
+	if name == "" {
+		return "Column"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "Col" + name
+	}
+	return name
+}
+
+// GenerateDBTYAML emits a dbt schema.yml with one source per file in the graph.
+// The inferred primary key of each file gets not_null and unique tests.
+func (s *ExportService) GenerateDBTYAML(graph *models.SimilarityGraph, analyses map[int]*models.DataAnalysisResult) string {
+	var sb strings.Builder
+	sb.WriteString("# Generated by Project Euler\n")
+	sb.WriteString("version: 2\n\n")
+	sb.WriteString("sources:\n")
+
+	for _, t := range graphTables(graph) {
+		analysis := analyses[t.Index]
+		tableName := fmt.Sprintf("file%d", t.Index)
+		if analysis != nil && analysis.FileName != "" {
+			tableName = strings.TrimSuffix(analysis.FileName, filepath.Ext(analysis.FileName))
+		}
+
+		sb.WriteString(fmt.Sprintf("  - name: file%d\n", t.Index))
+		sb.WriteString(fmt.Sprintf("    description: %s\n", strconv.Quote(t.Group)))
+		sb.WriteString("    tables:\n")
+		sb.WriteString(fmt.Sprintf("      - name: %s\n", strconv.Quote(tableName)))
+		sb.WriteString("        columns:\n")
+		for _, col := range t.Columns {
+			sb.WriteString(fmt.Sprintf("          - name: %s\n", strconv.Quote(col)))
+			sb.WriteString("            description: \"\"\n")
+			if analysis != nil && col == analysis.InferredPrimaryKey {
+				sb.WriteString("            tests: [not_null, unique]\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// GenerateTypeScript emits a TypeScript interface describing one row of the
+// analyzed file, named after the file. Nullable columns are unioned with null.
+func (s *ExportService) GenerateTypeScript(analysis *models.DataAnalysisResult) string {
+	name := "Row"
+	if analysis.FileName != "" {
+		name = goFieldName(strings.TrimSuffix(analysis.FileName, filepath.Ext(analysis.FileName)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// Generated by Project Euler\n\n")
+	sb.WriteString(fmt.Sprintf("export interface %s {\n", name))
+	for _, col := range analysis.ColumnProfiles {
+		tsType := typeScriptType(&col)
+		if col.Nullable {
+			tsType += " | null"
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %s;\n", strconv.Quote(col.Name), tsType))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// typeScriptType maps a column's inferred type to a TypeScript type; dates
+// stay strings since JSON carries them as text
+func typeScriptType(profile *models.ColumnAnalysis) string {
+	switch gormType(profile) {
+	case "int64", "float64":
+		return "number"
+	case "bool":
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// GenerateSQLAlchemy emits a Python module with one SQLAlchemy declarative
+// model per file in the graph. Column types come from the stored analyses;
+// the inferred primary key becomes primary_key=True.
+func (s *ExportService) GenerateSQLAlchemy(graph *models.SimilarityGraph, analyses map[int]*models.DataAnalysisResult) string {
+	var body strings.Builder
+	used := map[string]bool{"Column": true}
+
+	for _, t := range graphTables(graph) {
+		analysis := analyses[t.Index]
+		className := fmt.Sprintf("File%d", t.Index)
+		tableName := fmt.Sprintf("file%d", t.Index)
+		if analysis != nil && analysis.FileName != "" {
+			stem := strings.TrimSuffix(analysis.FileName, filepath.Ext(analysis.FileName))
+			className = goFieldName(stem)
+			tableName = stem
+		}
+
+		hasKey := analysis != nil && analysis.InferredPrimaryKey != ""
+
+		body.WriteString(fmt.Sprintf("\n\nclass %s(Base):\n", className))
+		body.WriteString(fmt.Sprintf("    __tablename__ = %s\n\n", strconv.Quote(tableName)))
+
+		attrs := make(map[string]int)
+		for i, col := range t.Columns {
+			saType := "Text"
+			nullable := true
+			if analysis != nil {
+				if profile := analysis.Column(col); profile != nil {
+					saType = sqlAlchemyType(profile)
+					nullable = profile.Nullable
+				}
+			}
+			used[saType] = true
+
+			attr := pythonIdent(col)
+			if n := attrs[attr]; n > 0 {
+				attrs[attr] = n + 1
+				attr = fmt.Sprintf("%s_%d", attr, n+1)
+			} else {
+				attrs[attr] = 1
+			}
+
+			args := []string{saType}
+			if attr != col {
+				args = append([]string{strconv.Quote(col)}, args...)
+			}
+			switch {
+			case hasKey && col == analysis.InferredPrimaryKey:
+				args = append(args, "primary_key=True")
+			case !hasKey && i == 0:
+				// SQLAlchemy cannot map a class without a primary key
+				body.WriteString("    # TODO: no primary key was inferred; check this column is unique\n")
+				args = append(args, "primary_key=True")
+			default:
+				args = append(args, fmt.Sprintf("nullable=%s", pythonBool(nullable)))
+			}
+			body.WriteString(fmt.Sprintf("    %s = Column(%s)\n", attr, strings.Join(args, ", ")))
+		}
+	}
+
+	imports := make([]string, 0, len(used))
+	for name := range used {
+		imports = append(imports, name)
+	}
+	sort.Strings(imports)
+
+	var sb strings.Builder
+	sb.WriteString("# Generated by Project Euler\n")
+	sb.WriteString(fmt.Sprintf("from sqlalchemy import %s\n", strings.Join(imports, ", ")))
+	sb.WriteString("from sqlalchemy.orm import declarative_base\n\n")
+	sb.WriteString("Base = declarative_base()\n")
+	sb.WriteString(body.String())
+	return sb.String()
+}
+
+// sqlAlchemyType maps a column's inferred type to a SQLAlchemy column type
+func sqlAlchemyType(profile *models.ColumnAnalysis) string {
+	switch gormType(profile) {
+	case "int64":
+		return "BigInteger"
+	case "float64":
+		return "Float"
+	case "time.Time":
+		if isDateOnlyLayout(profile.DateFormat) {
+			return "Date"
+		}
+		return "DateTime"
+	case "bool":
+		return "Boolean"
+	default:
+		return "Text"
+	}
+}
+
+// pythonKeywords cannot be used as attribute names
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true, "async": true,
+	"await": true, "break": true, "class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true, "global": true, "if": true,
+	"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+	"pass": true, "raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// pythonIdent converts a column name into a valid Python identifier, e.g.
+// "Order ID" becomes "order_id"; names that are already valid are kept as is
+func pythonIdent(col string) string {
+	var sb strings.Builder
+	for _, r := range col {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+
+	name := sb.String()
+	if name != col {
+		name = strings.ToLower(strings.Trim(name, "_"))
+	}
+	if name == "" {
+		name = "column"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "col_" + name
+	}
+	if pythonKeywords[name] {
+		name += "_"
+	}
+	return name
+}
+
+// pythonBool formats a bool as a Python literal
+func pythonBool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
+
+// GenerateProto emits a proto3 message describing one row of the analyzed
+// file, with fields numbered in column order. Nullable columns are marked
+// optional so absence can be told apart from the zero value.
+func (s *ExportService) GenerateProto(analysis *models.DataAnalysisResult) string {
+	name := "Row"
+	if analysis.FileName != "" {
+		name = avroName(goFieldName(strings.TrimSuffix(analysis.FileName, filepath.Ext(analysis.FileName))))
+	}
+
+	var fields strings.Builder
+	usesTimestamp := false
+	used := make(map[string]int)
+	for i, col := range analysis.ColumnProfiles {
+		protoType := protoScalarType(&col)
+		if protoType == "google.protobuf.Timestamp" {
+			usesTimestamp = true
+		}
+
+		fieldName := protoFieldName(col.Name)
+		if n := used[fieldName]; n > 0 {
+			used[fieldName] = n + 1
+			fieldName = fmt.Sprintf("%s_%d", fieldName, n+1)
+		} else {
+			used[fieldName] = 1
+		}
+
+		label := ""
+		if col.Nullable {
+			label = "optional "
+		}
+		comment := ""
+		if fieldName != col.Name {
+			comment = " // " + col.Name
+		}
+		fields.WriteString(fmt.Sprintf("  %s%s %s = %d;%s\n", label, protoType, fieldName, i+1, comment))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// Generated by Project Euler\n\n")
+	sb.WriteString("syntax = \"proto3\";\n\n")
+	if usesTimestamp {
+		sb.WriteString("import \"google/protobuf/timestamp.proto\";\n\n")
+	}
+	sb.WriteString(fmt.Sprintf("message %s {\n", name))
+	sb.WriteString(fields.String())
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// protoScalarType maps a column's inferred type to a protobuf field type
+func protoScalarType(profile *models.ColumnAnalysis) string {
+	switch gormType(profile) {
+	case "int64":
+		return "int64"
+	case "float64":
+		return "double"
+	case "time.Time":
+		return "google.protobuf.Timestamp"
+	case "bool":
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+// protoFieldName converts a column name into a lower_snake_case protobuf
+// field name, which must start with a letter
+func protoFieldName(col string) string {
+	name := strings.ToLower(strings.Trim(avroName(col), "_"))
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "col_" + name
+	}
+	return name
+}
+
+// jsonSchemaFormat returns the JSON Schema format of a date column, or "" if
+// its values are not in the RFC 3339 layout that "date" and "date-time" require
+func jsonSchemaFormat(profile *models.ColumnAnalysis) string {
+	if gormType(profile) != "time.Time" {
+		return ""
+	}
+	switch profile.DateFormat {
+	case time.RFC3339:
+		return "date-time"
+	case "2006-01-02":
+		return "date"
+	default:
+		return ""
+	}
+}
+
+// GenerateJSONSchema builds a JSON Schema (draft-07) document describing one row of the analyzed file
+func (s *ExportService) GenerateJSONSchema(analysis *models.DataAnalysisResult) ([]byte, error) {
+	properties := make(map[string]interface{}, len(analysis.ColumnProfiles))
+	required := []string{}
+
+	for _, col := range analysis.ColumnProfiles {
+		prop := map[string]interface{}{"type": jsonSchemaType(&col)}
+		if dateFormat := jsonSchemaFormat(&col); dateFormat != "" {
+			prop["format"] = dateFormat
+		}
+		if col.MaxLength > 0 {
+			prop["minLength"] = col.MinLength
+			prop["maxLength"] = col.MaxLength
+		}
+
+		if col.Nullable {
+			properties[col.Name] = map[string]interface{}{
+				"oneOf": []interface{}{prop, map[string]string{"type": "null"}},
+			}
+		} else {
+			properties[col.Name] = prop
+			required = append(required, col.Name)
+		}
+	}
+
+	title := analysis.FileName
+	if title == "" {
+		title = "Analyzed file"
+	}
+
+	schema := map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-07/schema#",
+		"title":      title,
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// GenerateAvro builds an Avro record schema describing one row of the analyzed
+// file. Nullable columns become ["null", type] unions defaulting to null. The
+// schema is checked with goavro before it is returned.
+func (s *ExportService) GenerateAvro(analysis *models.DataAnalysisResult) ([]byte, error) {
+	name := "Row"
+	if analysis.FileName != "" {
+		name = avroName(goFieldName(strings.TrimSuffix(analysis.FileName, filepath.Ext(analysis.FileName))))
+	}
+
+	fields := make([]map[string]interface{}, 0, len(analysis.ColumnProfiles))
+	used := make(map[string]int)
+	for _, col := range analysis.ColumnProfiles {
+		fieldName := avroName(col.Name)
+		if n := used[fieldName]; n > 0 {
+			used[fieldName] = n + 1
+			fieldName = fmt.Sprintf("%s_%d", fieldName, n+1)
+		} else {
+			used[fieldName] = 1
+		}
+
+		field := map[string]interface{}{"name": fieldName, "type": avroType(&col)}
+		if col.Nullable {
+			field["type"] = []interface{}{"null", field["type"]}
+			field["default"] = nil
+		}
+		if fieldName != col.Name {
+			field["doc"] = "Column " + col.Name
+		}
+		if col.Annotation != "" {
+			field["doc"] = col.Annotation
+		}
+		fields = append(fields, field)
+	}
+
+	schema, err := json.MarshalIndent(map[string]interface{}{
+		"type":   "record",
+		"name":   name,
+		"fields": fields,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := goavro.NewCodec(string(schema)); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+	return schema, nil
+}
+
+// avroType maps a column's inferred type to an Avro type; dates use the date
+// and timestamp-millis logical types
+func avroType(profile *models.ColumnAnalysis) interface{} {
+	switch gormType(profile) {
+	case "int64":
+		return "long"
+	case "float64":
+		return "double"
+	case "time.Time":
+		if isDateOnlyLayout(profile.DateFormat) {
+			return map[string]string{"type": "int", "logicalType": "date"}
+		}
+		return map[string]string{"type": "long", "logicalType": "timestamp-millis"}
+	case "bool":
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// avroName converts a name into an Avro name, which may only contain ASCII
+// letters, digits and underscores and may not start with a digit
+func avroName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || r == '_' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+
+	out := sb.String()
+	if out == "" || unicode.IsDigit(rune(out[0])) {
+		out = "_" + out
+	}
+	return out
+}
+
+// jsonSchemaType maps a column's inferred type to a JSON Schema type
+func jsonSchemaType(profile *models.ColumnAnalysis) string {
+	switch gormType(profile) {
+	case "int64":
+		return "integer"
+	case "float64":
+		return "number"
+	case "bool":
+		return "boolean"
+	default:
+		return "string"
+	}
+}
@@ -2,27 +2,83 @@ package service
 
 import (
 	"backend-go/internal/models"
+	"context"
 	"fmt"
+	"math"
 	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type SimilarityService struct {
-	ContextService *ContextService
+	ContextService ContextStore
 }
 
-func NewSimilarityService(ctxService *ContextService) *SimilarityService {
+func NewSimilarityService(ctxService ContextStore) *SimilarityService {
 	return &SimilarityService{
 		ContextService: ctxService,
 	}
 }
 
-// GenerateGraph creates the similarity graph
-func (s *SimilarityService) GenerateGraph(fileIndex1, fileIndex2 int) (*models.SimilarityGraph, error) {
+// WithStore returns a copy of the service that reads analyses and contexts
+// from store, such as the store of one namespace
+func (s *SimilarityService) WithStore(store ContextStore) *SimilarityService {
+	c := *s
+	c.ContextService = store
+	return &c
+}
+
+// SimilarityAlgorithm selects how column pairs are scored
+type SimilarityAlgorithm string
+
+const (
+	AlgorithmStructural SimilarityAlgorithm = "structural" // Column names and types
+	AlgorithmJaccard    SimilarityAlgorithm = "jaccard"    // Overlap of distinct values
+	AlgorithmCosine     SimilarityAlgorithm = "cosine"     // Set cosine (Otsuka-Ochiai) of distinct values
+)
+
+// ParseSimilarityAlgorithm validates an algorithm name; "" means structural
+func ParseSimilarityAlgorithm(name string) (SimilarityAlgorithm, error) {
+	switch algo := SimilarityAlgorithm(strings.ToLower(name)); algo {
+	case "":
+		return AlgorithmStructural, nil
+	case AlgorithmStructural, AlgorithmJaccard, AlgorithmCosine:
+		return algo, nil
+	default:
+		return "", fmt.Errorf("unknown similarity algorithm %q: must be structural, jaccard or cosine", name)
+	}
+}
+
+// DefaultSimilarityThreshold is the minimum score (0-1) for a column pair to
+// appear in the graph
+const DefaultSimilarityThreshold = 0.7
+
+// GenerateGraph creates the similarity graph, keeping only column pairs whose
+// score (0-1) is at least threshold
+func (s *SimilarityService) GenerateGraph(fileIndex1, fileIndex2 int, threshold float64) (*models.SimilarityGraph, error) {
+	return s.GenerateGraphContext(context.Background(), fileIndex1, fileIndex2, threshold, AlgorithmStructural)
+}
+
+// GenerateGraphContext is GenerateGraph with tracing from ctx and a choice of scoring algorithm
+func (s *SimilarityService) GenerateGraphContext(ctx context.Context, fileIndex1, fileIndex2 int, threshold float64, algorithm SimilarityAlgorithm) (*models.SimilarityGraph, error) {
+	_, span := otel.Tracer("service").Start(ctx, "SimilarityService.GenerateGraph")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("file1", fileIndex1),
+		attribute.Int("file2", fileIndex2),
+		attribute.Float64("threshold", threshold),
+		attribute.String("algorithm", string(algorithm)),
+	)
+
 	analysis1 := s.ContextService.GetAnalysis(fileIndex1)
 	analysis2 := s.ContextService.GetAnalysis(fileIndex2)
 
-	if analysis1 == nil || analysis2 == nil {
-		return nil, fmt.Errorf("analysis not found for one or both files")
+	if analysis1 == nil {
+		return nil, fmt.Errorf("analysis for File %d: %w", fileIndex1, ErrNotFound)
+	}
+	if analysis2 == nil {
+		return nil, fmt.Errorf("analysis for File %d: %w", fileIndex2, ErrNotFound)
 	}
 
 	ctx1 := s.ContextService.GetContext(fileIndex1)
@@ -35,25 +91,38 @@ func (s *SimilarityService) GenerateGraph(fileIndex1, fileIndex2 int) (*models.S
 		Correlations: []models.Correlation{}, // Numeric only
 	}
 
+	prefix1 := fmt.Sprintf("f%d_", fileIndex1)
+	prefix2 := fmt.Sprintf("f%d_", fileIndex2)
+	group1 := fmt.Sprintf("File %d", fileIndex1)
+	group2 := fmt.Sprintf("File %d", fileIndex2)
+
 	// Create Nodes
 	for _, col := range analysis1.ColumnNames {
-		graph.Nodes = append(graph.Nodes, models.Node{ID: "f1_" + col, Label: col, Group: "File 1"})
+		graph.Nodes = append(graph.Nodes, models.Node{ID: prefix1 + col, Label: col, Group: group1})
 	}
 	for _, col := range analysis2.ColumnNames {
-		graph.Nodes = append(graph.Nodes, models.Node{ID: "f2_" + col, Label: col, Group: "File 2"})
+		graph.Nodes = append(graph.Nodes, models.Node{ID: prefix2 + col, Label: col, Group: group2})
 	}
 
 	// Create Edges (Compare all vs all)
 	for _, col1 := range analysis1.ColumnNames {
 		for _, col2 := range analysis2.ColumnNames {
-			simScore, details := s.calculateDetailedSimilarity(col1, col2, analysis1.ColumnTypes[col1], analysis2.ColumnTypes[col2], ctx1, ctx2)
+			var simScore float64
+			var details simDetails
+			switch algorithm {
+			case AlgorithmJaccard, AlgorithmCosine:
+				simScore, details = s.calculateValueOverlap(col1, col2, analysis1.Column(col1), analysis2.Column(col2), algorithm)
+			default:
+				simScore, details = s.calculateDetailedSimilarity(col1, col2, analysis1.ColumnTypes[col1], analysis2.ColumnTypes[col2], ctx1, ctx2)
+			}
 
-			if simScore >= 30.0 { // Threshold
+			score := simScore / 100.0
+			if score >= threshold {
 				// Add Similarity
 				simEntry := models.Similarity{
 					File1Column:    col1,
 					File2Column:    col2,
-					Similarity:     simScore / 100.0,
+					Similarity:     score,
 					Confidence:     simScore,
 					Type:           details.Type,
 					NameSimilarity: details.NameSim,
@@ -64,11 +133,14 @@ func (s *SimilarityService) GenerateGraph(fileIndex1, fileIndex2 int) (*models.S
 
 				// Add Edge
 				edge := models.Edge{
-					Source:     "f1_" + col1,
-					Target:     "f2_" + col2,
+					Source:     prefix1 + col1,
+					Target:     prefix2 + col2,
 					Value:      simScore / 10.0, // Weight for graph vis
 					Similarity: simScore,
+					Score:      score,
 					Type:       details.Type,
+
+					MatchReasons: details.Reasons,
 				}
 				graph.Edges = append(graph.Edges, edge)
 			}
@@ -84,6 +156,7 @@ type simDetails struct {
 	NameSim float64
 	DataSim float64
 	Reason  string
+	Reasons []models.MatchReason // The factors scored, for Edge.MatchReasons
 }
 
 func (s *SimilarityService) calculateDetailedSimilarity(col1, col2, type1, type2 string, ctx1, ctx2 *models.Context) (float64, simDetails) {
@@ -111,18 +184,90 @@ func (s *SimilarityService) calculateDetailedSimilarity(col1, col2, type1, type2
 
 	// Weighted Score
 	totalScore := (nameSim * 0.6) + (dataSim * 0.4)
+	reasons := []models.MatchReason{
+		{Kind: models.MatchNameSimilarity, Score: nameSim / 100},
+		{Kind: models.MatchTypeMatch, Score: dataSim / 100},
+	}
 
 	// Context Overrides
 	if ctx1 != nil && ctx1.CustomMappings[col1] == col2 {
 		totalScore = 95.0
 		matchType = "custom_mapping"
+		reasons = append([]models.MatchReason{{Kind: models.MatchCustomMapping, Score: 0.95}}, reasons...)
 	}
 
 	return totalScore, simDetails{
 		Type:    matchType,
 		NameSim: nameSim,
 		DataSim: dataSim,
+		Reasons: reasons,
+	}
+}
+
+// calculateValueOverlap scores a column pair (0-100) by how many distinct
+// sample values they share
+func (s *SimilarityService) calculateValueOverlap(col1, col2 string, a1, a2 *models.ColumnAnalysis, algorithm SimilarityAlgorithm) (float64, simDetails) {
+	nameSim := LevenshteinRatio(col1, col2) * 100
+	if a1 == nil || a2 == nil {
+		return 0, simDetails{Type: "value_overlap", NameSim: nameSim, Reasons: []models.MatchReason{{Kind: models.MatchValueOverlap}}}
+	}
+
+	set1 := normalizedValueSet(a1.SampleValues)
+	set2 := normalizedValueSet(a2.SampleValues)
+
+	var overlap float64
+	if algorithm == AlgorithmCosine {
+		overlap = CosineSetSimilarity(set1, set2)
+	} else {
+		overlap = JaccardSimilarity(set1, set2)
+	}
+
+	dataSim := overlap * 100
+	return dataSim, simDetails{
+		Type:    "value_overlap",
+		NameSim: nameSim,
+		DataSim: dataSim,
+		Reason:  fmt.Sprintf("%.0f%% distinct value overlap (%s)", dataSim, algorithm),
+		Reasons: []models.MatchReason{{Kind: models.MatchValueOverlap, Score: overlap}},
+	}
+}
+
+func normalizedValueSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[strings.ToLower(strings.TrimSpace(v))] = struct{}{}
+	}
+	return set
+}
+
+// JaccardSimilarity returns |A∩B| / |A∪B| (0-1)
+func JaccardSimilarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := intersectionSize(a, b)
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+// CosineSetSimilarity returns |A∩B| / sqrt(|A|·|B|) (0-1)
+func CosineSetSimilarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	return float64(intersectionSize(a, b)) / math.Sqrt(float64(len(a))*float64(len(b)))
+}
+
+func intersectionSize(a, b map[string]struct{}) int {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	n := 0
+	for v := range a {
+		if _, ok := b[v]; ok {
+			n++
+		}
 	}
+	return n
 }
 
 // LevenshteinRatio calculates similarity ratio (0-1)
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/snowflakedb/gosnowflake"
+)
+
+// SnowflakeDataSource implements DataSource for Snowflake. Connections are
+// usually given as a gosnowflake DSN:
+//
+//	user:password@account/database/schema?warehouse=WH&role=ANALYST
+//
+// where account is the account identifier (e.g. myorg-myaccount). When DSN is
+// empty, Host is used as the account and DBName as the database, with the
+// PUBLIC schema.
+type SnowflakeDataSource struct {
+	db *sql.DB
+}
+
+func (s *SnowflakeDataSource) Connect(config DataSourceConfig) error {
+	dsn := config.DSN
+	if dsn == "" {
+		var err error
+		dsn, err = gosnowflake.DSN(&gosnowflake.Config{
+			Account:  config.Host,
+			User:     config.User,
+			Password: config.Password,
+			Database: config.DBName,
+			Schema:   "PUBLIC",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	db, err := sql.Open("snowflake", dsn)
+	if err != nil {
+		return err
+	}
+	applyPoolConfig(db, config)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+	return nil
+}
+
+func (s *SnowflakeDataSource) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+func (s *SnowflakeDataSource) Ping() error {
+	return pingDB(s.db, pingTimeout)
+}
+
+func (s *SnowflakeDataSource) ListTables() ([]string, error) {
+	return showNames(s.db, "SHOW TERSE TABLES IN SCHEMA")
+}
+
+// ListTablesPaged returns a page of table names plus the total table count.
+// SHOW output cannot be wrapped in a subquery, so paging is done here.
+func (s *SnowflakeDataSource) ListTablesPaged(limit, offset int) ([]string, int, error) {
+	tables, err := s.ListTables()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(tables)
+	tables = tables[min(offset, total):]
+	if limit > 0 && limit < len(tables) {
+		tables = tables[:limit]
+	}
+	return tables, total, nil
+}
+
+// ListTablesWithSchema returns every base table in the current schema with
+// the row count and comment Snowflake keeps for it
+func (s *SnowflakeDataSource) ListTablesWithSchema() ([]TableInfo, error) {
+	query := `
+		SELECT t.table_name,
+			COALESCE(t.row_count, 0),
+			(SELECT COUNT(*) FROM information_schema.columns c
+				WHERE c.table_schema = t.table_schema AND c.table_name = t.table_name),
+			COALESCE(t.comment, '')
+		FROM information_schema.tables t
+		WHERE t.table_schema = CURRENT_SCHEMA() AND t.table_type = 'BASE TABLE'
+		ORDER BY t.table_name
+	`
+	return scanTableInfos(s.db, query)
+}
+
+// ListViews returns the names of the views in the current schema
+func (s *SnowflakeDataSource) ListViews() ([]string, error) {
+	return showNames(s.db, "SHOW TERSE VIEWS IN SCHEMA")
+}
+
+// PreviewData samples rows with SAMPLE rather than LIMIT, so large tables
+// yield rows from throughout the table instead of the first micro-partitions
+func (s *SnowflakeDataSource) PreviewData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	query := fmt.Sprintf("SELECT * FROM %s SAMPLE (%d ROWS)", snowflakeIdent(tableName), limit)
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows)
+}
+
+// SampleData is PreviewData, which already samples the whole table
+func (s *SnowflakeDataSource) SampleData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	return s.PreviewData(tableName, limit)
+}
+
+// QueryRaw runs query in a transaction that is rolled back. Snowflake has no
+// read-only transactions, so write protection comes from the role in the DSN.
+func (s *SnowflakeDataSource) QueryRaw(query string, limit int) ([]string, []map[string]interface{}, error) {
+	return queryRaw(s.db, query, limit, false)
+}
+
+// snowflakeIdent quotes a possibly qualified name (db.schema.table) one part
+// at a time. Parts are matched case-sensitively, as SHOW reports them.
+func snowflakeIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = sqlIdent(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// showNames runs a Snowflake SHOW command and returns its "name" column, which
+// SHOW already sorts
+func showNames(db *sql.DB, show string) ([]string, error) {
+	rows, err := db.Query(show)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	_, result, err := scanRowMaps(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(result))
+	for _, row := range result {
+		names = append(names, fmt.Sprint(row["name"]))
+	}
+	return names, nil
+}
+
+// DescribeSchema returns the columns of every base table in the current
+// schema. Snowflake's information_schema has no key_column_usage, so primary
+// keys are not included.
+func (s *SnowflakeDataSource) DescribeSchema() (*DatabaseSchema, error) {
+	query := `
+		SELECT c.table_name, c.column_name,
+			CASE
+				WHEN c.data_type = 'TEXT' AND c.character_maximum_length IS NOT NULL
+					THEN 'VARCHAR(' || c.character_maximum_length || ')'
+				WHEN c.data_type = 'NUMBER' AND c.numeric_precision IS NOT NULL
+					THEN 'NUMBER(' || c.numeric_precision || ',' || c.numeric_scale || ')'
+				ELSE c.data_type
+			END,
+			c.is_nullable, c.column_default
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = CURRENT_SCHEMA() AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position
+	`
+	return scanSchema(s.db, "snowflake", query, "")
+}
+
+func (s *SnowflakeDataSource) ExportSchema() (string, error) {
+	return exportSchema(s)
+}
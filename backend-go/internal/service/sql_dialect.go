@@ -0,0 +1,148 @@
+package service
+
+import (
+	"backend-go/internal/models"
+	"fmt"
+	"strings"
+)
+
+// SQL dialects accepted by GenerateSQL
+const (
+	DialectPostgres  = "postgres"
+	DialectMySQL     = "mysql"
+	DialectBigQuery  = "bigquery"
+	DialectSnowflake = "snowflake"
+	DialectSQLite    = "sqlite"
+)
+
+// ParseDialect validates a SQL dialect name; "" means postgres
+func ParseDialect(name string) (string, error) {
+	switch name = strings.ToLower(name); name {
+	case "":
+		return DialectPostgres, nil
+	case DialectPostgres, DialectMySQL, DialectBigQuery, DialectSnowflake, DialectSQLite:
+		return name, nil
+	default:
+		return "", fmt.Errorf("unknown dialect %q: must be postgres, mysql, bigquery, snowflake or sqlite", name)
+	}
+}
+
+// SQLOptions controls how GenerateSQL creates tables
+type SQLOptions struct {
+	IfNotExists bool // Skip tables that already exist
+	Replace     bool // Drop and recreate tables that already exist; overrides IfNotExists
+}
+
+// DefaultSQLOptions creates tables only if they do not exist yet
+var DefaultSQLOptions = SQLOptions{IfNotExists: true}
+
+// createTable returns the statement head up to the column list for table,
+// preceded by a DROP TABLE statement when opts.Replace is set. BigQuery and
+// Snowflake replace in one statement with CREATE OR REPLACE TABLE.
+func createTable(table, dialect string, opts SQLOptions) string {
+	switch {
+	case opts.Replace && (dialect == DialectBigQuery || dialect == DialectSnowflake):
+		return "CREATE OR REPLACE TABLE " + table
+	case opts.Replace:
+		return fmt.Sprintf("DROP TABLE IF EXISTS %s;\nCREATE TABLE %s", table, table)
+	case opts.IfNotExists:
+		return "CREATE TABLE IF NOT EXISTS " + table
+	default:
+		return "CREATE TABLE " + table
+	}
+}
+
+// dialectType maps a column's inferred type to a column type in the dialect.
+// Postgres uses sqlType unchanged.
+func dialectType(profile *models.ColumnAnalysis, dialect string) string {
+	goType := gormType(profile)
+	dateOnly := isDateOnlyLayout(profile.DateFormat)
+
+	switch dialect {
+	case DialectMySQL:
+		switch goType {
+		case "int64":
+			return "BIGINT"
+		case "float64":
+			return "DOUBLE"
+		case "time.Time":
+			if dateOnly {
+				return "DATE"
+			}
+			return "DATETIME"
+		case "bool":
+			return "BOOLEAN"
+		default:
+			return "TEXT"
+		}
+	case DialectBigQuery:
+		switch goType {
+		case "int64":
+			return "INT64"
+		case "float64":
+			return "FLOAT64"
+		case "time.Time":
+			if dateOnly {
+				return "DATE"
+			}
+			return "TIMESTAMP"
+		case "bool":
+			return "BOOL"
+		default:
+			return "STRING"
+		}
+	case DialectSnowflake:
+		switch goType {
+		case "int64":
+			return "NUMBER(38,0)"
+		case "float64":
+			return "FLOAT"
+		case "time.Time":
+			if dateOnly {
+				return "DATE"
+			}
+			return "TIMESTAMP_NTZ"
+		case "bool":
+			return "BOOLEAN"
+		default:
+			return "VARCHAR"
+		}
+	case DialectSQLite:
+		// SQLite stores dates as ISO-8601 text and booleans as 0/1
+		switch goType {
+		case "int64", "bool":
+			return "INTEGER"
+		case "float64":
+			return "REAL"
+		default:
+			return "TEXT"
+		}
+	default:
+		return sqlType(profile)
+	}
+}
+
+// dialectTextType is the fallback type for columns without analysis data
+func dialectTextType(dialect string) string {
+	switch dialect {
+	case DialectBigQuery:
+		return "STRING"
+	case DialectSnowflake:
+		return "VARCHAR"
+	default:
+		return "TEXT"
+	}
+}
+
+// dialectIdent quotes an identifier for the dialect: backticks for MySQL and
+// BigQuery, double quotes elsewhere
+func dialectIdent(name, dialect string) string {
+	switch dialect {
+	case DialectMySQL:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	case DialectBigQuery:
+		return "`" + strings.ReplaceAll(name, "`", `\`+"`") + "`"
+	default:
+		return sqlIdent(name)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDataSource implements DataSource for SQLite database files
+type SQLiteDataSource struct {
+	db *sql.DB
+}
+
+func (s *SQLiteDataSource) Connect(config DataSourceConfig) error {
+	if config.DSN == "" {
+		return fmt.Errorf("sqlite requires a database file path in dsn")
+	}
+
+	db, err := sql.Open("sqlite", config.DSN)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+	return nil
+}
+
+func (s *SQLiteDataSource) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+func (s *SQLiteDataSource) Ping() error {
+	return pingDB(s.db, pingTimeout)
+}
+
+func (s *SQLiteDataSource) ListTables() ([]string, error) {
+	tables, _, err := s.ListTablesPaged(0, 0)
+	return tables, err
+}
+
+// ListTablesPaged returns a page of table names plus the total table count.
+// A limit of 0 returns all tables from offset onwards.
+func (s *SQLiteDataSource) ListTablesPaged(limit, offset int) ([]string, int, error) {
+	query := `
+		SELECT name
+		FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name
+	`
+	return pagedNames(s.db, query, limit, offset)
+}
+
+// ListTablesWithSchema returns every table with its exact row count. SQLite
+// keeps no row statistics or table comments, so the rows are counted.
+func (s *SQLiteDataSource) ListTablesWithSchema() ([]TableInfo, error) {
+	query := `
+		SELECT m.name, 0, (SELECT COUNT(*) FROM pragma_table_info(m.name)), ''
+		FROM sqlite_master m
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name
+	`
+	tables, err := scanTableInfos(s.db, query)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tables {
+		count := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(tables[i].Name, `"`, `""`))
+		if err := s.db.QueryRow(count).Scan(&tables[i].RowCount); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// ListViews returns the names of the views in the database file
+func (s *SQLiteDataSource) ListViews() ([]string, error) {
+	query := `
+		SELECT name
+		FROM sqlite_master
+		WHERE type = 'view'
+		ORDER BY name
+	`
+	views, _, err := pagedNames(s.db, query, 0, 0)
+	return views, err
+}
+
+func (s *SQLiteDataSource) PreviewData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	query := fmt.Sprintf(`SELECT * FROM "%s" LIMIT %d`, strings.ReplaceAll(tableName, `"`, `""`), limit)
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows)
+}
+
+// SampleData returns limit rows chosen at random from the whole table
+func (s *SQLiteDataSource) SampleData(tableName string, limit int) ([]string, []map[string]interface{}, error) {
+	query := fmt.Sprintf(`SELECT * FROM "%s" ORDER BY random() LIMIT %d`, strings.ReplaceAll(tableName, `"`, `""`), limit)
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows)
+}
+
+// QueryRaw runs query with PRAGMA query_only set on a dedicated connection.
+// The driver accepts read-only transactions but does not enforce them.
+func (s *SQLiteDataSource) QueryRaw(query string, limit int) ([]string, []map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rawQueryTimeout)
+	defer cancel()
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return nil, nil, err
+	}
+	// The pragma sticks to the pooled connection, so clear it on the way out
+	defer conn.ExecContext(context.Background(), "PRAGMA query_only = OFF")
+
+	return queryRaw(conn, query, limit, true)
+}
+
+// DescribeSchema returns the columns and primary key of every table, from
+// pragma_table_info
+func (s *SQLiteDataSource) DescribeSchema() (*DatabaseSchema, error) {
+	columns := `
+		SELECT m.name, p.name, p.type, CASE WHEN p."notnull" = 1 THEN 'NO' ELSE 'YES' END, p.dflt_value
+		FROM sqlite_master m
+		JOIN pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name, p.cid
+	`
+	keys := `
+		SELECT m.name, p.name
+		FROM sqlite_master m
+		JOIN pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%' AND p.pk > 0
+		ORDER BY m.name, p.pk
+	`
+	return scanSchema(s.db, "sqlite", columns, keys)
+}
+
+func (s *SQLiteDataSource) ExportSchema() (string, error) {
+	return exportSchema(s)
+}
@@ -101,6 +101,28 @@ func (s *AppState) ClearContext(fileIndex *int) {
 	}
 }
 
+// Wipe removes the loaded dataframes and contexts, keeping the Ollama config.
+// It returns the paths of the uploaded files the dataframes were read from,
+// which the caller must delete, and how many contexts there were.
+func (s *AppState) Wipe() (filePaths []string, contexts int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, df := range []*DataFrame{s.DF1, s.DF2} {
+		if df != nil && df.FilePath != "" {
+			filePaths = append(filePaths, df.FilePath)
+		}
+	}
+	for _, ctx := range []*models.Context{s.File1Context, s.File2Context} {
+		if ctx != nil {
+			contexts++
+		}
+	}
+	s.DF1, s.DF2 = nil, nil
+	s.File1Context, s.File2Context = nil, nil
+	return filePaths, contexts
+}
+
 // GetNumericColumnIndices returns indices of numeric columns
 func (df *DataFrame) GetNumericColumnIndices() map[int]bool {
 	if len(df.Rows) == 0 {